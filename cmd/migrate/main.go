@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/config"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/migrations"
+)
+
+// Usage:
+//
+//	go run ./cmd/migrate up        apply all pending migrations
+//	go run ./cmd/migrate down [n]  roll back the last n migrations (default 1)
+//	go run ./cmd/migrate status    list migrations and whether they are applied
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate up | down [n] | status")
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config.EnsureDataDir() // the default SQLite file lives under data/
+	conn, d := config.InitDB()
+	defer conn.Close()
+
+	migrator, err := migrations.NewEmbedded(conn, d)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch flag.Arg(0) {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("migrate up failed: %v", err)
+		}
+		log.Printf("applied %d migration(s)", n)
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps <= 0 {
+				log.Fatalf("invalid step count %q", flag.Arg(1))
+			}
+		}
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("migrate down failed: %v", err)
+		}
+		log.Printf("rolled back %d migration(s)", n)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("migrate status failed: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
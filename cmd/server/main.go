@@ -1,20 +1,29 @@
 package main
 
 import (
+	"context"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"runtime/trace"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/chat"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/config"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
-	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/db"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/handlers"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/loginguard"
+	appmw "github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/migrations"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/routes"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/token"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/wikiindex"
 
 	_ "net/http/pprof"
 
@@ -44,14 +53,15 @@ func main() {
 	config.EnsureDataDir()
 
 	// --- Initialize DB, sessions, cache ---
-	conn := config.InitDB()
+	conn, dialect := config.InitDB()
 	defer func() {
 		conn.Close()
 		log.Println("database connection closed")
 	}()
-	config.InitSession()
-	data.InitDBConnection(conn)
+	sessionRepo := data.NewSQLSessionRepo(conn, dialect)
+	config.InitSession(sessionRepo)
 	data.InitCache()
+	data.PasswordPolicy = config.InitPasswordPolicy()
 	authRepo := data.NewAuthRepo(conn)
 	handlers.Init(config.Store, authRepo)
 
@@ -60,11 +70,43 @@ func main() {
 		log.Fatalf("failed to load wiki templates: %v", err)
 	}
 
-	// --- Execute schema ---
-	if err := db.ExecuteSchema(conn, "schema.sql"); err != nil {
-		log.Fatalf("schema execution failed: %v", err)
+	// --- Apply pending migrations ---
+	migrator, err := migrations.NewEmbedded(conn, dialect)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Fatalf("migration failed: %v", err)
+	}
+	log.Printf("database migrations applied: %d pending migration(s) run", applied)
+
+	// --- Wiki storage and search index (after migrations, for WIKI_STORE=db) ---
+	pageStore := config.InitPageStore(conn)
+	wikiIdx, err := wikiindex.Build(context.Background(), pageStore)
+	if err != nil {
+		log.Fatalf("failed to index wiki pages: %v", err)
 	}
-	log.Println("database schema executed successfully")
+
+	// --- Bootstrap the first admin (ADMIN_USERNAME) ---
+	if name := os.Getenv("ADMIN_USERNAME"); name != "" {
+		if ok, err := authRepo.GrantRole(name, models.RoleAdmin); err != nil {
+			log.Fatalf("failed to grant admin role: %v", err)
+		} else if !ok {
+			log.Printf("ADMIN_USERNAME %q does not exist yet; register it and restart to make it admin", name)
+		}
+	}
+
+	// --- Prune expired sessions ---
+	go func() {
+		for range time.Tick(time.Hour) {
+			if n, err := sessionRepo.DeleteExpired(context.Background(), time.Now()); err != nil {
+				log.Printf("session cleanup failed: %v", err)
+			} else if n > 0 {
+				log.Printf("session cleanup: removed %d expired session(s)", n)
+			}
+		}
+	}()
 
 	// --- Initialize Echo ---
 	e := echo.New()
@@ -73,6 +115,10 @@ func main() {
 	e.HideBanner = true
 	e.HidePort = true
 
+	// Use the TCP peer as the client IP (login throttling, sessions); X-Forwarded-For is
+	// client-controlled. Behind a trusted proxy, switch to echo.ExtractIPFromXFFHeader.
+	e.IPExtractor = echo.ExtractIPDirect()
+
 	// Disable Echo default logger completely
 	e.Logger.SetOutput(io.Discard)
 
@@ -92,8 +138,49 @@ func main() {
 		}
 	})
 
+	// --- Bearer tokens ---
+	tokenCfg := config.InitTokens()
+	keys, err := token.ParseKeys(tokenCfg.Keys)
+	if err != nil {
+		log.Fatalf("invalid JWT_KEYS: %v", err)
+	}
+	tokens, err := token.NewService(keys, tokenCfg.AccessTTL, tokenCfg.RefreshTTL, data.NewSQLGrantRepo(conn))
+	if err != nil {
+		log.Fatalf("failed to set up bearer tokens: %v", err)
+	}
+
+	// --- Events (published by the album and order repositories, streamed by /ws/events) ---
+	bus := events.NewBus()
+	albums, orders := data.NewSQLAlbumRepo(conn), data.NewSQLOrderRepo(conn)
+	albums.Events, orders.Events = bus, bus
+
 	// --- Register routes ---
-	routes.Register(e)
+	h := &handlers.Handler{
+		Albums:    albums,
+		Orders:    orders,
+		Users:     data.NewSQLUserRepo(conn),
+		Customers: data.NewSQLCustomerRepo(conn, dialect),
+		Books:     data.NewSQLBookRepo(conn),
+		Tokens:    tokens,
+		Sessions:  sessionRepo,
+		Guard:     loginguard.New(data.NewSQLLoginAttemptRepo(conn)),
+		TwoFactor: data.NewSQLTwoFactorRepo(conn),
+
+		UserTokens: data.NewSQLUserTokenRepo(conn),
+		Mailer:     config.InitMailer(),
+		BaseURL:    config.BaseURL(),
+
+		Pages:     pageStore,
+		WikiIndex: wikiIdx,
+
+		Chat:   chat.NewHub(),
+		Events: bus,
+	}
+	routes.Register(e, h,
+		appmw.Session(config.Store, h.Users),
+		appmw.Bearer(h.Tokens, h.Users),
+		appmw.CSRF(config.Store, "/auth/token", "/auth/refresh"), // these take credentials in the body, not the cookie
+	)
 
 	// --- Start pprof server in background ---
 	go func() {
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/config"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/migrations"
+)
+
+// Usage:
+//
+//	go run ./cmd/wikiimport [-dir data]
+//
+// copies the wiki pages in dir (<Title>.txt and <Title>.history files) into the
+// database configured by DB_DRIVER, with their history. Pages the database
+// already has are skipped, so it is safe to run again. Set WIKI_STORE=db
+// afterwards to serve the wiki from the database.
+func main() {
+	config.InitEnv() // WIKI_DIR may come from .env
+	dir := flag.String("dir", config.WikiDir(), "directory holding the wiki's .txt files")
+	flag.Parse()
+
+	config.EnsureDataDir() // the default SQLite file lives under data/
+	conn, d := config.InitDB()
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator, err := migrations.NewEmbedded(conn, d)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		log.Fatalf("migration failed: %v", err)
+	}
+
+	imported, err := data.ImportPages(ctx, data.NewSQLPageStore(conn), data.NewFSPageStore(*dir))
+	for _, title := range imported {
+		log.Printf("imported %s", title)
+	}
+	if err != nil {
+		log.Fatalf("import failed: %v", err)
+	}
+	log.Printf("imported %d page(s) from %s", len(imported), *dir)
+}
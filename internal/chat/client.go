@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Connection timing and limits.
+const (
+	writeWait      = 10 * time.Second   // to write one frame
+	pongWait       = 60 * time.Second   // for the next pong (or any frame) from the client
+	pingPeriod     = pongWait * 9 / 10  // between pings; must be less than pongWait
+	maxMessageSize = 4*MaxBodyLen + 512 // bytes per incoming frame: the body, escaped, plus the envelope
+)
+
+// Serve runs a chat session for conn as a client called name until the
+// connection closes or the client is dropped for falling behind.
+func (h *Hub) Serve(conn *websocket.Conn, name string) {
+	c := NewClient(name)
+	h.Register(c)
+	go c.writePump(conn)
+	h.readPump(c, conn)
+}
+
+// readPump hands the client's messages to the hub until the connection fails,
+// then unregisters the client, which stops writePump.
+func (h *Hub) readPump(c *Client, conn *websocket.Conn) {
+	defer h.Unregister(c)
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("chat: %s: %v", c.Name, err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var m Message
+		if err := json.Unmarshal(frame, &m); err != nil {
+			m = Message{} // answered with an error like an unknown type
+		}
+		h.Handle(c, m)
+	}
+}
+
+// writePump writes the client's queued frames and pings to conn. When the hub
+// closes c.Send it sends a close frame and closes the connection, which also
+// ends readPump if the client is being dropped.
+func (c *Client) writePump(conn *websocket.Conn) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case frame, ok := <-c.Send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				closing := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+				if c.Dropped() {
+					closing = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow")
+				}
+				conn.WriteMessage(websocket.CloseMessage, closing)
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package chat is a WebSocket chat hub with named rooms. Clients join and leave
+// rooms and broadcast to everyone in a room; every frame is a JSON Message.
+// Each connection has a bounded send buffer, and a client that falls that far
+// behind is disconnected rather than slowing the room down.
+package chat
+
+import (
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Message types. Clients send join, leave and message; the hub sends those to
+// room members and error to the client whose request failed.
+const (
+	TypeJoin    = "join"
+	TypeLeave   = "leave"
+	TypeMessage = "message"
+	TypeError   = "error"
+)
+
+// Message is the JSON envelope of every frame. From and Timestamp are set by the hub.
+type Message struct {
+	Type      string    `json:"type"`
+	Room      string    `json:"room,omitempty"`
+	From      string    `json:"from,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Limits on what clients may do.
+const (
+	MaxBodyLen  = 2000 // characters per message
+	MaxRooms    = 10   // rooms a client can be in at once
+	SendBuffer  = 64   // queued outgoing frames per client before it counts as too slow
+	roomPattern = `^[A-Za-z0-9_-]{1,32}$`
+)
+
+var validRoom = regexp.MustCompile(roomPattern)
+
+// Hub tracks clients and the rooms they are in. It is safe for concurrent use.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*Client]bool
+	rooms   map[string]map[*Client]bool
+	now     func() time.Time
+}
+
+// NewHub returns an empty hub.
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[*Client]bool),
+		rooms:   make(map[string]map[*Client]bool),
+		now:     time.Now,
+	}
+}
+
+// Client is one connection's membership in the hub. Frames for it are queued
+// on Send, which the hub closes when the client is unregistered or dropped.
+type Client struct {
+	Name string
+	Send chan []byte
+
+	rooms   map[string]bool
+	dropped bool // Send was closed because it was full; set before the close
+}
+
+// NewClient returns a client called name with a send buffer of SendBuffer frames.
+func NewClient(name string) *Client {
+	return &Client{Name: name, Send: make(chan []byte, SendBuffer), rooms: make(map[string]bool)}
+}
+
+// delivery is a message waiting to go out to a room.
+type delivery struct {
+	room string
+	msg  Message
+}
+
+// Register adds c to the hub.
+func (h *Hub) Register(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = true
+}
+
+// Unregister removes c from every room, telling the other members, and closes c.Send.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deliver(h.disconnect(c, false)...)
+}
+
+// Dropped reports whether c was disconnected for falling behind. It is only
+// meaningful once c.Send has been closed.
+func (c *Client) Dropped() bool { return c.dropped }
+
+// Handle carries out a message received from c.
+func (h *Hub) Handle(c *Client, m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.clients[c] {
+		return
+	}
+
+	switch {
+	case m.Type != TypeJoin && m.Type != TypeLeave && m.Type != TypeMessage:
+		h.reply(c, "expected a JSON message of type join, leave or message")
+	case !validRoom.MatchString(m.Room):
+		h.reply(c, "room names are 1-32 letters, digits, '-' or '_'")
+	case m.Type == TypeJoin:
+		if c.rooms[m.Room] {
+			return
+		}
+		if len(c.rooms) >= MaxRooms {
+			h.reply(c, "too many rooms")
+			return
+		}
+		c.rooms[m.Room] = true
+		if h.rooms[m.Room] == nil {
+			h.rooms[m.Room] = make(map[*Client]bool)
+		}
+		h.rooms[m.Room][c] = true
+		h.deliver(delivery{m.Room, Message{Type: TypeJoin, Room: m.Room, From: c.Name}})
+	case !c.rooms[m.Room]:
+		h.reply(c, "not in room "+m.Room)
+	case m.Type == TypeLeave:
+		h.deliver(delivery{m.Room, Message{Type: TypeLeave, Room: m.Room, From: c.Name}})
+		h.leave(c, m.Room)
+	case m.Body == "":
+		h.reply(c, "empty message")
+	case len([]rune(m.Body)) > MaxBodyLen:
+		h.reply(c, "message too long")
+	default:
+		h.deliver(delivery{m.Room, Message{Type: TypeMessage, Room: m.Room, From: c.Name, Body: m.Body}})
+	}
+}
+
+// Rooms returns the number of members of each room.
+func (h *Hub) Rooms() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := make(map[string]int, len(h.rooms))
+	for room, members := range h.rooms {
+		counts[room] = len(members)
+	}
+	return counts
+}
+
+// reply sends an error to c alone.
+func (h *Hub) reply(c *Client, reason string) {
+	if !h.send(c, Message{Type: TypeError, Body: reason, Timestamp: h.now().UTC()}) {
+		h.deliver(h.disconnect(c, true)...)
+	}
+}
+
+// deliver sends messages to the members of their rooms. Members whose buffer is
+// full are disconnected, and the leave messages that causes are delivered too.
+func (h *Hub) deliver(queue ...delivery) {
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		d.msg.Timestamp = h.now().UTC()
+		for c := range h.rooms[d.room] {
+			if !h.send(c, d.msg) {
+				queue = append(queue, h.disconnect(c, true)...)
+			}
+		}
+	}
+}
+
+// send queues m for c without blocking; it reports false if c's buffer is full.
+func (h *Hub) send(c *Client, m Message) bool {
+	frame, err := json.Marshal(m)
+	if err != nil {
+		return true // can't happen for Message; dropping it is all we could do
+	}
+	select {
+	case c.Send <- frame:
+		return true
+	default:
+		return false
+	}
+}
+
+// disconnect removes c from the hub and closes c.Send, noting whether c is
+// dropped for being too slow. It returns the leave messages for c's rooms.
+func (h *Hub) disconnect(c *Client, dropped bool) []delivery {
+	if !h.clients[c] {
+		return nil
+	}
+	delete(h.clients, c)
+	var leaves []delivery
+	for room := range c.rooms {
+		h.leave(c, room)
+		leaves = append(leaves, delivery{room, Message{Type: TypeLeave, Room: room, From: c.Name}})
+	}
+	c.dropped = dropped
+	close(c.Send)
+	return leaves
+}
+
+// leave removes c from room, dropping the room once it is empty.
+func (h *Hub) leave(c *Client, room string) {
+	delete(c.rooms, room)
+	delete(h.rooms[room], c)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+}
@@ -0,0 +1,192 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// received drains the frames queued for c.
+func received(t *testing.T, c *Client) []Message {
+	t.Helper()
+	var msgs []Message
+	for {
+		select {
+		case frame, ok := <-c.Send:
+			if !ok {
+				return msgs
+			}
+			var m Message
+			if err := json.Unmarshal(frame, &m); err != nil {
+				t.Fatalf("bad frame %q: %v", frame, err)
+			}
+			msgs = append(msgs, m)
+		default:
+			return msgs
+		}
+	}
+}
+
+func summary(msgs []Message) string {
+	var parts []string
+	for _, m := range msgs {
+		parts = append(parts, strings.TrimSpace(m.Type+" "+m.Room+" "+m.From+" "+m.Body))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestRooms(t *testing.T) {
+	h := NewHub()
+	alice, bob, carol := NewClient("alice"), NewClient("bob"), NewClient("carol")
+	for _, c := range []*Client{alice, bob, carol} {
+		h.Register(c)
+	}
+
+	h.Handle(alice, Message{Type: TypeJoin, Room: "lobby"})
+	h.Handle(bob, Message{Type: TypeJoin, Room: "lobby"})
+	h.Handle(carol, Message{Type: TypeJoin, Room: "other"})
+	h.Handle(bob, Message{Type: TypeMessage, Room: "lobby", Body: "hi", From: "mallory"})
+	h.Handle(bob, Message{Type: TypeLeave, Room: "lobby"})
+	h.Handle(alice, Message{Type: TypeMessage, Room: "lobby", Body: "bye"})
+
+	if got, want := summary(received(t, alice)), "join lobby alice; join lobby bob; message lobby bob hi; leave lobby bob; message lobby alice bye"; got != want {
+		t.Errorf("alice got %q, want %q", got, want)
+	}
+	if got, want := summary(received(t, bob)), "join lobby bob; message lobby bob hi; leave lobby bob"; got != want {
+		t.Errorf("bob got %q, want %q", got, want)
+	}
+	if got, want := summary(received(t, carol)), "join other carol"; got != want {
+		t.Errorf("carol got %q, want %q (other rooms' traffic leaked)", got, want)
+	}
+
+	h.Unregister(alice)
+	if _, ok := <-alice.Send; ok || alice.Dropped() {
+		t.Errorf("Unregister: Send open or client marked dropped")
+	}
+	if rooms := h.Rooms(); len(rooms) != 1 || rooms["other"] != 1 {
+		t.Errorf("Rooms() = %v, want only other with 1 member", rooms)
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	h := NewHub()
+	c := NewClient("alice")
+	h.Register(c)
+
+	for _, m := range []Message{
+		{Type: "shout", Room: "lobby"},
+		{Type: TypeJoin, Room: "no spaces"},
+		{Type: TypeJoin, Room: ""},
+		{Type: TypeMessage, Room: "lobby", Body: "not joined"},
+		{Type: TypeLeave, Room: "lobby"},
+	} {
+		h.Handle(c, m)
+		if msgs := received(t, c); len(msgs) != 1 || msgs[0].Type != TypeError {
+			t.Errorf("Handle(%+v) sent %q, want one error", m, summary(msgs))
+		}
+	}
+
+	h.Handle(c, Message{Type: TypeJoin, Room: "lobby"})
+	received(t, c)
+	for _, body := range []string{"", strings.Repeat("é", MaxBodyLen+1)} {
+		h.Handle(c, Message{Type: TypeMessage, Room: "lobby", Body: body})
+		if msgs := received(t, c); len(msgs) != 1 || msgs[0].Type != TypeError {
+			t.Errorf("message of %d characters: sent %q, want one error", len([]rune(body)), summary(msgs))
+		}
+	}
+}
+
+func TestSlowConsumerDropped(t *testing.T) {
+	h := NewHub()
+	fast, slow := NewClient("fast"), NewClient("slow")
+	h.Register(fast)
+	h.Register(slow)
+	h.Handle(slow, Message{Type: TypeJoin, Room: "lobby"})
+	h.Handle(fast, Message{Type: TypeJoin, Room: "lobby"})
+
+	// fast keeps up; slow never reads, so its buffer fills and it is dropped.
+	for i := 0; i < SendBuffer*2; i++ {
+		h.Handle(fast, Message{Type: TypeMessage, Room: "lobby", Body: "spam"})
+		received(t, fast)
+	}
+
+	n := 0
+	for range slow.Send { // ends because the hub closed it
+		n++
+	}
+	if n != SendBuffer || !slow.Dropped() {
+		t.Errorf("slow got %d frames (dropped %v), want %d and dropped", n, slow.Dropped(), SendBuffer)
+	}
+	if rooms := h.Rooms(); rooms["lobby"] != 1 {
+		t.Errorf("lobby has %d members after the drop, want 1", rooms["lobby"])
+	}
+
+	// The others were told, and the dropped client no longer counts.
+	h.Handle(fast, Message{Type: TypeMessage, Room: "lobby", Body: "still here"})
+	if got, want := summary(received(t, fast)), "message lobby fast still here"; got != want {
+		t.Errorf("fast got %q, want %q", got, want)
+	}
+	h.Handle(slow, Message{Type: TypeMessage, Room: "lobby", Body: "ignored"})
+	h.Unregister(slow) // already gone; must not close Send twice
+}
+
+func TestServe(t *testing.T) {
+	h := NewHub()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		h.Serve(conn, r.URL.Query().Get("name"))
+	}))
+	defer srv.Close()
+
+	dial := func(name string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?name="+name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	read := func(conn *websocket.Conn) Message {
+		t.Helper()
+		var m Message
+		if err := conn.ReadJSON(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	alice, bob := dial("alice"), dial("bob")
+	defer bob.Close()
+	alice.WriteJSON(Message{Type: TypeJoin, Room: "lobby"})
+	read(alice) // her own join: the hub has her in the room
+	bob.WriteJSON(Message{Type: TypeJoin, Room: "lobby"})
+	read(bob)
+	if m := read(alice); m.Type != TypeJoin || m.From != "bob" {
+		t.Fatalf("alice got %+v, want bob's join", m)
+	}
+
+	bob.WriteJSON(Message{Type: TypeMessage, Room: "lobby", Body: "hello"})
+	m := read(alice)
+	if m.Type != TypeMessage || m.From != "bob" || m.Body != "hello" || m.Timestamp.IsZero() {
+		t.Errorf("alice got %+v, want bob's hello with a timestamp", m)
+	}
+	read(bob) // his own hello
+
+	bob.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if m := read(bob); m.Type != TypeError {
+		t.Errorf("bob got %+v for a non-JSON frame, want an error", m)
+	}
+
+	alice.Close()
+	if m := read(bob); m.Type != TypeLeave || m.From != "alice" {
+		t.Errorf("bob got %+v after alice disconnected, want her leave", m)
+	}
+}
@@ -1,18 +1,30 @@
 package config
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql" // ensure mysql driver is imported
 	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
+	_ "modernc.org/sqlite" // ensure sqlite driver is imported
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/dialect"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/mail"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/passhash"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/sessionstore"
 )
 
 var (
-	Store *sessions.CookieStore
+	Store *sessionstore.Store
 )
 
 // InitEnv loads .env file so os.Getenv() works
@@ -23,10 +35,44 @@ func InitEnv() {
 	}
 }
 
-// InitDB initializes and returns a MySQL database connection.
-func InitDB() *sql.DB {
+// InitDB initializes and returns a database connection for the driver selected by DB_DRIVER
+// (mysql by default, or sqlite for a local file with no external services).
+func InitDB() (*sql.DB, dialect.Dialect) {
 	InitEnv() // Load env variables
 
+	d, err := dialect.Lookup(os.Getenv("DB_DRIVER"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var dsn string
+	switch d {
+	case dialect.SQLite:
+		dsn = sqliteDSN()
+	default:
+		dsn = mysqlDSN()
+	}
+
+	db, err := sql.Open(d.DriverName(), dsn)
+	if err != nil {
+		log.Fatal("failed to open DB: ", err)
+	}
+
+	if d == dialect.SQLite {
+		// SQLite allows a single writer; serialising through one connection avoids SQLITE_BUSY.
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Fatal("failed to connect to DB: ", err)
+	}
+
+	log.Printf("Connected to DB (%s) ✅", d.Name())
+	return db, d
+}
+
+// mysqlDSN builds the MySQL DSN from DBUSER/DBPASS/DBHOST/DBPORT/DBNAME.
+func mysqlDSN() string {
 	// Config (Local DB)
 	// -----------------
 	// user := os.Getenv("DBUSER")
@@ -47,23 +93,21 @@ func InitDB() *sql.DB {
 
 	// DSN (freesqldatabase.com)
 	// -------------------------
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&multiStatements=true", user, pass, host, port, name)
-
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		log.Fatal("failed to open DB: ", err)
-	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&multiStatements=true", user, pass, host, port, name)
+}
 
-	if err := db.Ping(); err != nil {
-		log.Fatal("failed to connect to DB: ", err)
+// sqliteDSN builds the SQLite DSN from DB_PATH (default data/app.db) with foreign keys enabled.
+func sqliteDSN() string {
+	path := os.Getenv("DB_PATH")
+	if path == "" {
+		path = "data/app.db"
 	}
-
-	log.Println("Connected to DB ✅")
-	return db
+	return "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
 }
 
-// InitSession initializes the global session store using secure cookies.
-func InitSession() {
+// InitSession initializes the global session store. Session values are kept
+// server-side in repo; the signed cookie only names the session.
+func InitSession(repo data.SessionRepository) {
 	InitEnv() // ensure .env is loaded
 
 	// Get session key from env
@@ -72,7 +116,7 @@ func InitSession() {
 		log.Fatal("SESSION_KEY is not set in environment")
 	}
 
-	Store = sessions.NewCookieStore([]byte(sessionKey))
+	Store = sessionstore.NewStore(repo, []byte(sessionKey))
 	Store.Options = &sessions.Options{
 		Path:     "/",
 		MaxAge:   3600 * 8, // 8 hours
@@ -81,6 +125,157 @@ func InitSession() {
 	}
 }
 
+// TokenConfig holds the JWT bearer-token settings read by InitTokens.
+type TokenConfig struct {
+	Keys       string        // JWT_KEYS: "kid:secret,kid:secret", first key signs
+	AccessTTL  time.Duration // JWT_ACCESS_TTL, default 15m
+	RefreshTTL time.Duration // JWT_REFRESH_TTL, default 7 days
+}
+
+// InitTokens reads the JWT settings. To rotate keys, put the new key first in
+// JWT_KEYS and keep the old one after it until its refresh tokens have expired.
+// Without JWT_KEYS a random key is generated, so tokens stop working on restart.
+func InitTokens() TokenConfig {
+	InitEnv() // ensure .env is loaded
+
+	cfg := TokenConfig{
+		Keys:       os.Getenv("JWT_KEYS"),
+		AccessTTL:  durationEnv("JWT_ACCESS_TTL", 15*time.Minute),
+		RefreshTTL: durationEnv("JWT_REFRESH_TTL", 7*24*time.Hour),
+	}
+	if cfg.Keys == "" {
+		log.Println("⚠️ JWT_KEYS is not set; using a random key, bearer tokens won't survive a restart")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("failed to generate JWT key: %v", err)
+		}
+		cfg.Keys = "ephemeral:" + hex.EncodeToString(secret)
+	}
+	return cfg
+}
+
+// durationEnv parses a time.Duration environment variable, falling back to def when unset.
+func durationEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("invalid %s %q: want a positive duration such as 15m", name, v)
+	}
+	return d
+}
+
+// InitMailer selects the mailer for account emails with MAIL_DRIVER:
+// "smtp" (SMTP_ADDR, SMTP_USERNAME, SMTP_PASSWORD) or "file" (the default),
+// which writes messages to MAIL_DIR (default data/mail) instead of sending them.
+func InitMailer() mail.Mailer {
+	InitEnv() // ensure .env is loaded
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			log.Fatal("MAIL_DRIVER=smtp needs SMTP_ADDR (host:port)")
+		}
+		return mail.SMTPMailer{Addr: addr, From: from, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD")}
+	case "", "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "data/mail"
+		}
+		log.Printf("mail: writing emails to %s (set MAIL_DRIVER=smtp to send them)", dir)
+		return mail.FileMailer{Dir: dir, From: from}
+	default:
+		log.Fatalf("unsupported MAIL_DRIVER %q (want smtp or file)", driver)
+		return nil
+	}
+}
+
+// BaseURL is the public address used in emailed links (BASE_URL, default http://localhost:$PORT).
+// It is configured rather than taken from the Host header, which the client controls.
+func BaseURL() string {
+	if u := os.Getenv("BASE_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port
+}
+
+// InitPasswordPolicy reads the password hashing policy: PASSWORD_HASH selects
+// "bcrypt" (the default; cost BCRYPT_COST) or "argon2id" (ARGON2_TIME,
+// ARGON2_MEMORY in KiB, ARGON2_THREADS). Unset values keep passhash.DefaultPolicy's.
+// Existing hashes keep working and are upgraded at the next login when weaker.
+func InitPasswordPolicy() passhash.Policy {
+	InitEnv() // ensure .env is loaded
+
+	p := passhash.DefaultPolicy
+	if alg := os.Getenv("PASSWORD_HASH"); alg != "" {
+		p.Algorithm = alg
+	}
+	p.BcryptCost = intEnv("BCRYPT_COST", p.BcryptCost)
+	p.Argon2.Time = uint32(intEnv("ARGON2_TIME", int(p.Argon2.Time)))
+	p.Argon2.Memory = uint32(intEnv("ARGON2_MEMORY", int(p.Argon2.Memory)))
+	threads := intEnv("ARGON2_THREADS", int(p.Argon2.Threads))
+	if threads > 255 {
+		log.Fatalf("invalid ARGON2_THREADS %d: at most 255", threads)
+	}
+	p.Argon2.Threads = uint8(threads)
+	if err := p.Validate(); err != nil {
+		log.Fatalf("invalid password hashing policy: %v", err)
+	}
+	return p
+}
+
+// intEnv reads a positive integer environment variable, or returns def when it is unset.
+func intEnv(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > 1<<22 {
+		log.Fatalf("invalid %s %q: want a positive integer", name, v)
+	}
+	return n
+}
+
+// InitPageStore selects where wiki pages are kept with WIKI_STORE: "file" (the default)
+// for text files in WIKI_DIR (default data), or "db" for the wiki_page and wiki_revision
+// tables, which survive a redeploy. Move existing files into the database with
+// go run ./cmd/wikiimport.
+func InitPageStore(db *sql.DB) data.PageStore {
+	InitEnv() // ensure .env is loaded
+
+	switch s := os.Getenv("WIKI_STORE"); s {
+	case "db":
+		log.Println("wiki: pages are stored in the database")
+		return data.NewSQLPageStore(db)
+	case "", "file":
+		return data.NewFSPageStore(WikiDir())
+	default:
+		log.Fatalf("unsupported WIKI_STORE %q (want file or db)", s)
+		return nil
+	}
+}
+
+// WikiDir is the directory of the file wiki store (WIKI_DIR, default data).
+func WikiDir() string {
+	if dir := os.Getenv("WIKI_DIR"); dir != "" {
+		return dir
+	}
+	return "data"
+}
+
 // EnsureDataDir creates the data directory with restricted permissions (owner-only).
 func EnsureDataDir() {
 	if err := os.MkdirAll("data", 0700); err != nil {
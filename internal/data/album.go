@@ -3,71 +3,144 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"time"
+	"slices"
+	"strings"
 
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
 )
 
-var db *sql.DB
+// AlbumRepository provides access to the album catalogue.
+type AlbumRepository interface {
+	All(ctx context.Context) ([]models.Album, error)
+	// List returns one page of albums matching q plus the total number of matches.
+	List(ctx context.Context, q AlbumQuery) ([]models.Album, int, error)
+	ByArtist(ctx context.Context, name string) ([]models.Album, error)
+	ByID(ctx context.Context, id int64) (models.Album, error)
+	Add(ctx context.Context, alb models.Album) (int64, error)
+	// Update replaces title, artist, price and quantity of an existing album and bumps its version.
+	// A non-zero ifVersion makes the update conditional (ErrVersionConflict when it doesn't match).
+	Update(ctx context.Context, alb models.Album, ifVersion int64) (models.Album, error)
+	// Delete removes an album; it fails with ErrAlbumHasOrders while orders reference it.
+	// A non-zero ifVersion makes the delete conditional like Update.
+	Delete(ctx context.Context, id, ifVersion int64) error
+	// AdjustStock atomically adds delta (which may be negative) to the album's quantity.
+	AdjustStock(ctx context.Context, id, delta int64) (models.Album, error)
+	CanPurchase(ctx context.Context, id, quantity int64) (bool, error)
+}
+
+// AlbumSortFields are the columns an album listing may be sorted by.
+var AlbumSortFields = []string{"id", "title", "artist", "price", "quantity"}
+
+// AlbumQuery filters, sorts and pages an album listing.
+type AlbumQuery struct {
+	Limit    int
+	Offset   int
+	SortBy   string // one of AlbumSortFields; empty means id
+	Desc     bool
+	MinPrice *float32
+	MaxPrice *float32
+	InStock  bool   // only albums with quantity > 0
+	Artist   string // case-insensitive substring match
+}
 
-// InitDBConnection sets the package-level DB variable for reuse across data access functions.
-func InitDBConnection(conn *sql.DB) {
-	db = conn
+// SQLAlbumRepo implements AlbumRepository using a SQL database.
+type SQLAlbumRepo struct {
+	DB     *sql.DB
+	Events *events.Bus // told about stock changes; may be nil
 }
 
-// AllAlbums returns all albums in the database.
-func AllAlbums() ([]models.Album, error) {
-	rows, err := db.Query("SELECT id, title, artist, price, quantity FROM album")
+// NewSQLAlbumRepo creates a new SQLAlbumRepo with a given DB connection.
+func NewSQLAlbumRepo(db *sql.DB) *SQLAlbumRepo {
+	return &SQLAlbumRepo{DB: db}
+}
+
+// All returns all albums in the database.
+func (repo *SQLAlbumRepo) All(ctx context.Context) ([]models.Album, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT "+albumColumns+" FROM album")
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanAlbums(rows)
+}
 
-	var albums []models.Album
-	for rows.Next() {
-		var a models.Album
-		if err := rows.Scan(&a.ID, &a.Title, &a.Artist, &a.Price, &a.Quantity); err != nil {
-			return nil, err
-		}
-		albums = append(albums, a)
+// List returns one page of albums matching q plus the total number of matches.
+func (repo *SQLAlbumRepo) List(ctx context.Context, q AlbumQuery) ([]models.Album, int, error) {
+	var where []string
+	var args []any
+	if q.MinPrice != nil {
+		where = append(where, "price >= ?")
+		args = append(args, *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		where = append(where, "price <= ?")
+		args = append(args, *q.MaxPrice)
+	}
+	if q.InStock {
+		where = append(where, "quantity > 0")
+	}
+	if q.Artist != "" {
+		// '!' is the escape character: it behaves the same on MySQL and SQLite, unlike '\'.
+		where = append(where, "LOWER(artist) LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(q.Artist))+"%")
 	}
-	return albums, rows.Err()
-}
 
-// AlbumsByArtist returns albums filtered by the artist's name.
-func AlbumsByArtist(name string) ([]models.Album, error) {
-	rows, err := db.Query("SELECT id, title, artist, price, quantity FROM album WHERE artist = ?", name)
-	if err != nil {
-		return nil, err
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = " WHERE " + strings.Join(where, " AND ")
 	}
-	defer rows.Close()
 
-	var albums []models.Album
-	for rows.Next() {
-		var alb models.Album
-		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Quantity); err != nil {
-			return nil, err
-		}
-		albums = append(albums, alb)
+	var total int
+	if err := repo.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM album"+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, err
 	}
-	return albums, rows.Err()
+
+	// SortBy is checked against AlbumSortFields, so it is safe to interpolate.
+	sortBy := "id"
+	if slices.Contains(AlbumSortFields, q.SortBy) {
+		sortBy = q.SortBy
+	}
+	dir := "ASC"
+	if q.Desc {
+		dir = "DESC"
+	}
+	query := fmt.Sprintf("SELECT %s FROM album%s ORDER BY %s %s, id ASC LIMIT ? OFFSET ?",
+		albumColumns, whereSQL, sortBy, dir)
+
+	rows, err := repo.DB.QueryContext(ctx, query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	albums, err := scanAlbums(rows)
+	return albums, total, err
 }
 
-// AlbumByID retrieves a single album by its ID.
-func AlbumByID(id int64) (models.Album, error) {
-	var album models.Album
-	err := db.QueryRow("SELECT id, title, artist, price, quantity FROM album WHERE id = ?", id).
-		Scan(&album.ID, &album.Title, &album.Artist, &album.Price, &album.Quantity)
+// likeEscaper escapes LIKE wildcards using '!' as the escape character.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// ByArtist returns albums filtered by the artist's name.
+func (repo *SQLAlbumRepo) ByArtist(ctx context.Context, name string) ([]models.Album, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT "+albumColumns+" FROM album WHERE artist = ?", name)
 	if err != nil {
-		return album, err
+		return nil, err
 	}
-	return album, nil
+	return scanAlbums(rows)
+}
+
+// ByID retrieves a single album by its ID.
+func (repo *SQLAlbumRepo) ByID(ctx context.Context, id int64) (models.Album, error) {
+	album, err := scanAlbum(repo.DB.QueryRowContext(ctx, "SELECT "+albumColumns+" FROM album WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return album, ErrNotFound
+	}
+	return album, err
 }
 
-// AddAlbum inserts a new album and returns its inserted ID.
-func AddAlbum(alb models.Album) (int64, error) {
-	result, err := db.Exec("INSERT INTO album (title, artist, price, quantity) VALUES (?, ?, ?, ?)",
+// Add inserts a new album and returns its inserted ID.
+func (repo *SQLAlbumRepo) Add(ctx context.Context, alb models.Album) (int64, error) {
+	result, err := repo.DB.ExecContext(ctx, "INSERT INTO album (title, artist, price, quantity) VALUES (?, ?, ?, ?)",
 		alb.Title, alb.Artist, alb.Price, alb.Quantity)
 	if err != nil {
 		return 0, err
@@ -75,150 +148,135 @@ func AddAlbum(alb models.Album) (int64, error) {
 	return result.LastInsertId()
 }
 
-// CanPurchase checks if the requested quantity is available for a given album.
-func CanPurchase(id int64, quantity int64) (bool, error) {
-	var enough bool
-	err := db.QueryRow("SELECT (quantity >= ?) FROM album WHERE id = ?", quantity, id).Scan(&enough)
+// Update replaces title, artist, price and quantity of an existing album and bumps its version.
+func (repo *SQLAlbumRepo) Update(ctx context.Context, alb models.Album, ifVersion int64) (models.Album, error) {
+	res, err := repo.DB.ExecContext(ctx, `UPDATE album SET title = ?, artist = ?, price = ?, quantity = ?, version = version + 1
+		WHERE id = ? AND (? = 0 OR version = ?)`,
+		alb.Title, alb.Artist, alb.Price, alb.Quantity, alb.ID, ifVersion, ifVersion)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, fmt.Errorf("unknown album ID %d", id)
-		}
-		return false, err
+		return models.Album{}, err
 	}
-	return enough, nil
+	if err := checkVersioned(ctx, repo.DB, res, "album", alb.ID); err != nil {
+		return models.Album{}, err
+	}
+	updated, err := repo.ByID(ctx, alb.ID)
+	if err == nil {
+		publishStock(repo.Events, events.StockChanged{AlbumID: updated.ID, Quantity: updated.Quantity})
+	}
+	return updated, err
 }
 
-// GetOrdersByUser returns the last 10 orders for a customer.
-func GetOrdersByUser(userID int64) ([]models.GetOrder, error) {
-	time.Sleep(2 * time.Second) // Artificial delay for testing only
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
-	rows, err := db.Query(`
-		SELECT id, album_id, cust_id, quantity, date
-		FROM album_order
-		WHERE cust_id = ?
-		ORDER BY date DESC
-		LIMIT 10
-	`, userID)
-	if err != nil {
-		return nil, err
+// checkVersioned turns "no rows affected" by a version-conditioned statement into
+// ErrNotFound or ErrVersionConflict. The version bump means a matching row always counts as affected.
+// q must be the transaction the statement ran in, if any, so the lookup doesn't need a second connection.
+// table is always a constant from this package.
+func checkVersioned(ctx context.Context, q rowQuerier, res sql.Result, table string, id int64) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
 	}
-	defer rows.Close()
-
-	var orders []models.GetOrder
-	for rows.Next() {
-		var o models.GetOrder
-		if err := rows.Scan(&o.ID, &o.AlbumID, &o.Customer, &o.Quantity, &o.Date); err != nil {
-			return nil, err
-		}
-		orders = append(orders, o)
+	var exists int
+	err = q.QueryRowContext(ctx, "SELECT 1 FROM "+table+" WHERE id = ?", id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
 	}
-	return orders, rows.Err()
+	if err != nil {
+		return err
+	}
+	return ErrVersionConflict
 }
 
-// CreateOrderByUser creates an order for a user within a transaction (all-or-nothing).
-func CreateOrderByUser(ctx context.Context, albumID, quantity, custID int64) (int64, error) {
-	tx, err := db.BeginTx(ctx, nil)
+// Delete removes an album unless order lines still reference it.
+func (repo *SQLAlbumRepo) Delete(ctx context.Context, id, ifVersion int64) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	defer tx.Rollback()
 
-	var enough bool
-	if err := tx.QueryRowContext(ctx, "SELECT (quantity >= ?) FROM album WHERE id = ?", quantity, albumID).Scan(&enough); err != nil {
-		return 0, err
+	var orders int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM order_item WHERE album_id = ?", id).Scan(&orders); err != nil {
+		return err
 	}
-	if !enough {
-		return 0, fmt.Errorf("not enough inventory")
+	if orders > 0 {
+		return ErrAlbumHasOrders
 	}
 
-	if _, err := tx.ExecContext(ctx, "UPDATE album SET quantity = quantity - ? WHERE id = ?", quantity, albumID); err != nil {
-		return 0, err
+	// The order_item foreign key still guards against an order slipping in before commit.
+	res, err := tx.ExecContext(ctx, "DELETE FROM album WHERE id = ? AND (? = 0 OR version = ?)", id, ifVersion, ifVersion)
+	if err != nil {
+		return err
+	}
+	if err := checkVersioned(ctx, tx, res, "album", id); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
 
-	res, err := tx.ExecContext(ctx, "INSERT INTO album_order (album_id, cust_id, quantity, date) VALUES (?, ?, ?, ?)",
-		albumID, custID, quantity, time.Now())
+// AdjustStock atomically adds delta to the album's quantity.
+// The single conditional UPDATE can't interleave badly with concurrent order transactions.
+func (repo *SQLAlbumRepo) AdjustStock(ctx context.Context, id, delta int64) (models.Album, error) {
+	if delta == 0 {
+		return repo.ByID(ctx, id)
+	}
+	res, err := repo.DB.ExecContext(ctx, "UPDATE album SET quantity = quantity + ?, version = version + 1 WHERE id = ? AND quantity + ? >= 0",
+		delta, id, delta)
 	if err != nil {
-		return 0, err
+		return models.Album{}, err
 	}
-
-	orderID, err := res.LastInsertId()
+	n, err := res.RowsAffected()
 	if err != nil {
-		return 0, err
+		return models.Album{}, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return 0, err
+	album, err := repo.ByID(ctx, id)
+	if err != nil {
+		return album, err
 	}
-	return orderID, nil
-}
-
-// GetCustomerName retrieves a customer's full name by ID.
-func GetCustomerName(id int64) (string, error) {
-	var name string
-	if err := db.QueryRow("SELECT full_name FROM customer WHERE id = ?", id).Scan(&name); err != nil {
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("customer not found")
-		}
-		return "", err
+	if n == 0 {
+		return album, ErrInsufficientStock
 	}
-	return name, nil
+	publishStock(repo.Events, events.StockChanged{AlbumID: album.ID, Quantity: album.Quantity})
+	return album, nil
 }
 
-// GetAlbumsAndCustomers returns albums and customers in a combined map using multiple result sets.
-func GetAlbumsAndCustomers() (map[string]any, error) {
-	rows, err := db.Query("SELECT * FROM album; SELECT * FROM customer;")
+// CanPurchase checks if the requested quantity is available for a given album.
+func (repo *SQLAlbumRepo) CanPurchase(ctx context.Context, id, quantity int64) (bool, error) {
+	var enough bool
+	err := repo.DB.QueryRowContext(ctx, "SELECT (quantity >= ?) FROM album WHERE id = ?", quantity, id).Scan(&enough)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var albums []models.Album
-	for rows.Next() {
-		var a models.Album
-		if err := rows.Scan(&a.ID, &a.Title, &a.Artist, &a.Price, &a.Quantity); err != nil {
-			return nil, err
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("unknown album ID %d", id)
 		}
-		albums = append(albums, a)
+		return false, err
 	}
+	return enough, nil
+}
 
-	var customers []map[string]any
-	if rows.NextResultSet() {
-		for rows.Next() {
-			var id int64
-			var fullName, address, phone string
-			if err := rows.Scan(&id, &fullName, &address, &phone); err != nil {
-				return nil, err
-			}
-			customers = append(customers, map[string]any{
-				"id":       id,
-				"fullName": fullName,
-				"address":  address,
-				"phone":    phone,
-			})
-		}
-	}
+// albumColumns is the column list scanAlbum expects, in order.
+const albumColumns = "id, title, artist, price, quantity, version"
 
-	return map[string]any{
-		"albums":    albums,
-		"customers": customers,
-	}, nil
+// scanAlbum reads one albumColumns row from a *sql.Row or *sql.Rows.
+func scanAlbum(row interface{ Scan(...any) error }) (models.Album, error) {
+	var a models.Album
+	err := row.Scan(&a.ID, &a.Title, &a.Artist, &a.Price, &a.Quantity, &a.Version)
+	return a, err
 }
 
-// QueryAlbumsWithTimeout queries albums with a context timeout.
-func QueryAlbumsWithTimeout(ctx context.Context) ([]models.Album, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	rows, err := db.QueryContext(ctx, "SELECT id, title, artist, price, quantity FROM album")
-	if err != nil {
-		return nil, err
-	}
+// scanAlbums reads albumColumns rows into albums and closes rows.
+func scanAlbums(rows *sql.Rows) ([]models.Album, error) {
 	defer rows.Close()
 
 	var albums []models.Album
 	for rows.Next() {
-		var a models.Album
-		if err := rows.Scan(&a.ID, &a.Title, &a.Artist, &a.Price, &a.Quantity); err != nil {
+		a, err := scanAlbum(rows)
+		if err != nil {
 			return nil, err
 		}
 		albums = append(albums, a)
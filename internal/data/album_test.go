@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -12,7 +13,6 @@ func setupMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
 	if err != nil {
 		t.Fatalf("failed to open sqlmock: %v", err)
 	}
-	InitDBConnection(db)
 	return db, mock
 }
 
@@ -25,7 +25,7 @@ func TestCanPurchaseWithMock(t *testing.T) {
 		WithArgs(int64(3), int64(1)).
 		WillReturnRows(sqlmock.NewRows([]string{"enough"}).AddRow(true))
 
-	ok, err := CanPurchase(1, 3)
+	ok, err := NewSQLAlbumRepo(db).CanPurchase(context.Background(), 1, 3)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -2,8 +2,10 @@ package data
 
 import (
 	"database/sql"
+	"errors"
+	"log"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/passhash"
 )
 
 // AuthRepo provides authentication methods using a SQL database.
@@ -17,19 +19,41 @@ func NewAuthRepo(db *sql.DB) *AuthRepo {
 }
 
 // VerifyUser checks if the username/password combination is valid.
+// On success it rehashes the password if its stored hash is weaker than PasswordPolicy;
+// a failed upgrade is logged and retried at the next login.
 func (repo *AuthRepo) VerifyUser(username, password string) (bool, error) {
 	var hash string
 	err := repo.DB.QueryRow("SELECT password FROM users WHERE username = ?", username).Scan(&hash)
 	if err != nil {
 		return false, err
 	}
-	return CheckPasswordHash(password, hash), nil
+	if !CheckPasswordHash(password, hash) {
+		return false, nil
+	}
+
+	if PasswordPolicy.NeedsRehash(hash) {
+		if err := repo.upgradeHash(username, hash, password); err != nil {
+			log.Printf("upgrading password hash of %q: %v", username, err)
+		}
+	}
+	return true, nil
+}
+
+// upgradeHash replaces oldHash with a hash under PasswordPolicy. The UPDATE only
+// matches if the hash is unchanged, so a concurrent password change wins.
+func (repo *AuthRepo) upgradeHash(username, oldHash, password string) error {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = repo.DB.Exec("UPDATE users SET password = ? WHERE username = ? AND password = ?", hashed, username, oldHash)
+	return err
 }
 
-// CheckPasswordHash compares a plain password with a bcrypt hash.
+// CheckPasswordHash compares a plain password with a stored bcrypt or argon2id hash.
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	ok, err := passhash.Verify(password, hash)
+	return ok && err == nil
 }
 
 // GetUserID retrieves the ID of a user by username.
@@ -41,3 +65,16 @@ func (repo *AuthRepo) GetUserID(username string) (int64, error) {
 	}
 	return id, nil
 }
+
+// GrantRole sets the role of the user with the given username; it reports whether such a user exists.
+func (repo *AuthRepo) GrantRole(username, role string) (bool, error) {
+	var id int64
+	if err := repo.DB.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	_, err := repo.DB.Exec("UPDATE users SET role = ? WHERE id = ?", role, id)
+	return err == nil, err
+}
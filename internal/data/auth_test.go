@@ -0,0 +1,51 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/passhash"
+)
+
+func TestVerifyUserUpgradesWeakHash(t *testing.T) {
+	old := PasswordPolicy
+	defer func() { PasswordPolicy = old }()
+
+	weak, _ := passhash.Policy{Algorithm: passhash.Bcrypt, BcryptCost: 4}.Hash("purple-otter-42")
+	PasswordPolicy = passhash.Policy{Algorithm: passhash.Bcrypt, BcryptCost: 5}
+
+	db, mock := setupMockDB(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT password FROM users WHERE username = \\?").
+		WithArgs("ann").
+		WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(weak))
+	mock.ExpectExec("UPDATE users SET password = \\? WHERE username = \\? AND password = \\?").
+		WithArgs(sqlmock.AnyArg(), "ann", weak).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ok, err := NewAuthRepo(db).VerifyUser("ann", "purple-otter-42")
+	if !ok || err != nil {
+		t.Fatalf("VerifyUser = %v, %v; want true, nil", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("weak hash was not upgraded: %v", err)
+	}
+}
+
+func TestVerifyUserWrongPasswordKeepsHash(t *testing.T) {
+	weak, _ := passhash.Policy{Algorithm: passhash.Bcrypt, BcryptCost: 4}.Hash("purple-otter-42")
+
+	db, mock := setupMockDB(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT password FROM users WHERE username = \\?").
+		WithArgs("ann").
+		WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(weak))
+
+	if ok, err := NewAuthRepo(db).VerifyUser("ann", "wrong"); ok || err != nil {
+		t.Fatalf("VerifyUser = %v, %v; want false, nil", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unexpected queries: %v", err)
+	}
+}
@@ -1,73 +1,106 @@
 package data
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
 )
 
-// In-memory store for books
-var books = []models.Book{
-	{ID: 1, Title: "Blue Train", Author: "John Coltrane", Price: 56.99},
-	{ID: 2, Title: "Jeru", Author: "Gerry Mulligan", Price: 17.99},
-	{ID: 3, Title: "Sarah Vaughan and Clifford Brown", Author: "Sarah Vaughan", Price: 39.99},
+// BookRepository provides access to books.
+type BookRepository interface {
+	// All returns all books ordered by ID.
+	All(ctx context.Context) ([]models.Book, error)
+	ByID(ctx context.Context, id int) (models.Book, error)
+	// Add stores a new book and returns it with its ID and version set.
+	Add(ctx context.Context, b models.Book) (models.Book, error)
+	// Update applies the non-empty fields of updated and bumps the version.
+	// A non-zero ifVersion must match the current version, otherwise ErrVersionConflict is returned.
+	Update(ctx context.Context, id int, updated models.Book, ifVersion int) (models.Book, error)
+	// Delete removes a book; ifVersion is checked like in Update.
+	Delete(ctx context.Context, id int, ifVersion int) error
 }
 
-// GetAllBooks returns all books
-func GetAllBooks() []models.Book {
-	return books
+// SQLBookRepo implements BookRepository using the book table.
+type SQLBookRepo struct {
+	DB *sql.DB
 }
 
-// GetBookByID returns a book by ID
-func GetBookByID(id int) (*models.Book, error) {
-	for _, b := range books {
-		if b.ID == id {
-			return &b, nil
+// NewSQLBookRepo creates a new SQLBookRepo with a given DB connection.
+func NewSQLBookRepo(db *sql.DB) *SQLBookRepo {
+	return &SQLBookRepo{DB: db}
+}
+
+// All returns all books ordered by ID.
+func (repo *SQLBookRepo) All(ctx context.Context) ([]models.Book, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT id, title, author, price, version FROM book ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		var b models.Book
+		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.Price, &b.Version); err != nil {
+			return nil, err
 		}
+		books = append(books, b)
 	}
-	return nil, errors.New("book not found")
+	return books, rows.Err()
 }
 
-// AddBook adds a new book
-func AddBook(b models.Book) models.Book {
-	// Generate a new ID
-	var maxID int // it gets the zero value of its type by default.
-	for _, book := range books {
-		if book.ID > maxID {
-			maxID = book.ID
-		}
+// ByID returns a book by ID.
+func (repo *SQLBookRepo) ByID(ctx context.Context, id int) (models.Book, error) {
+	var b models.Book
+	err := repo.DB.QueryRowContext(ctx, "SELECT id, title, author, price, version FROM book WHERE id = ?", id).
+		Scan(&b.ID, &b.Title, &b.Author, &b.Price, &b.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return b, ErrNotFound
 	}
-	b.ID = maxID + 1
-	books = append(books, b)
-	return b
+	return b, err
 }
 
-// UpdateBook updates a book by ID
-func UpdateBook(id int, updated models.Book) (*models.Book, error) {
-	for i, b := range books {
-		if b.ID == id {
-			if updated.Title != "" {
-				books[i].Title = updated.Title
-			}
-			if updated.Author != "" {
-				books[i].Author = updated.Author
-			}
-			if updated.Price != 0 {
-				books[i].Price = updated.Price
-			}
-			return &books[i], nil
-		}
+// Add inserts a new book.
+func (repo *SQLBookRepo) Add(ctx context.Context, b models.Book) (models.Book, error) {
+	res, err := repo.DB.ExecContext(ctx, "INSERT INTO book (title, author, price) VALUES (?, ?, ?)", b.Title, b.Author, b.Price)
+	if err != nil {
+		return b, err
 	}
-	return nil, errors.New("book not found")
+	id, err := res.LastInsertId()
+	if err != nil {
+		return b, err
+	}
+	b.ID = int(id)
+	b.Version = 1
+	return b, nil
 }
 
-// DeleteBook deletes a book by ID
-func DeleteBook(id int) error {
-	for i, b := range books {
-		if b.ID == id { // ... means: expand this slice into individual elements
-			books = append(books[:i], books[i+1:]...)
-			return nil
-		}
+// Update applies the non-empty fields of updated in a single conditional statement.
+func (repo *SQLBookRepo) Update(ctx context.Context, id int, updated models.Book, ifVersion int) (models.Book, error) {
+	res, err := repo.DB.ExecContext(ctx, `UPDATE book SET
+		title = CASE WHEN ? = '' THEN title ELSE ? END,
+		author = CASE WHEN ? = '' THEN author ELSE ? END,
+		price = CASE WHEN ? = 0 THEN price ELSE ? END,
+		version = version + 1
+		WHERE id = ? AND (? = 0 OR version = ?)`,
+		updated.Title, updated.Title, updated.Author, updated.Author, updated.Price, updated.Price,
+		id, ifVersion, ifVersion)
+	if err != nil {
+		return models.Book{}, err
+	}
+	if err := checkVersioned(ctx, repo.DB, res, "book", int64(id)); err != nil {
+		return models.Book{}, err
+	}
+	return repo.ByID(ctx, id)
+}
+
+// Delete removes a book by ID.
+func (repo *SQLBookRepo) Delete(ctx context.Context, id int, ifVersion int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM book WHERE id = ? AND (? = 0 OR version = ?)", id, ifVersion, ifVersion)
+	if err != nil {
+		return err
 	}
-	return errors.New("book not found")
+	return checkVersioned(ctx, repo.DB, res, "book", int64(id))
 }
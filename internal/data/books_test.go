@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+func TestMemoryBookRepoConcurrentAdd(t *testing.T) {
+	repo := NewMemoryBookRepo(models.Book{ID: 1, Title: "Blue Train", Author: "John Coltrane", Price: 56.99})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.Add(ctx, models.Book{Title: "t", Author: "a"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	books, _ := repo.All(ctx)
+	if len(books) != 51 {
+		t.Fatalf("expected 51 books, got %d", len(books))
+	}
+	for i, b := range books {
+		if b.ID != i+1 {
+			t.Fatalf("expected unique sequential IDs, got %d at position %d", b.ID, i)
+		}
+	}
+}
+
+func TestMemoryBookRepoVersionConflict(t *testing.T) {
+	repo := NewMemoryBookRepo(models.Book{ID: 1, Title: "Jeru", Author: "Gerry Mulligan", Price: 17.99})
+	ctx := context.Background()
+
+	b, err := repo.Update(ctx, 1, models.Book{Price: 19.99}, 1)
+	if err != nil || b.Version != 2 || b.Title != "Jeru" {
+		t.Fatalf("unexpected update result %+v, %v", b, err)
+	}
+	if _, err := repo.Update(ctx, 1, models.Book{Title: "x"}, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if err := repo.Delete(ctx, 2, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
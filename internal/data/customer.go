@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/dialect"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// CustomerRepository provides access to customer records.
+type CustomerRepository interface {
+	Name(ctx context.Context, id int64) (string, error)
+	// ByUser returns the customer record linked to a login, or ErrNotFound.
+	ByUser(ctx context.Context, userID int64) (models.Customer, error)
+	// CreateForUser stores a customer record linked to userID; ErrProfileExists if there already is one.
+	CreateForUser(ctx context.Context, userID int64, cust models.Customer) (models.Customer, error)
+	// UpdateForUser replaces name, address and phone of the record linked to userID.
+	UpdateForUser(ctx context.Context, userID int64, cust models.Customer) (models.Customer, error)
+	// AllWithAlbums returns every customer together with the album catalogue.
+	AllWithAlbums(ctx context.Context) ([]models.Album, []models.Customer, error)
+}
+
+// SQLCustomerRepo implements CustomerRepository using a SQL database.
+type SQLCustomerRepo struct {
+	DB      *sql.DB
+	Dialect dialect.Dialect
+}
+
+// NewSQLCustomerRepo creates a new SQLCustomerRepo with a given DB connection and dialect.
+func NewSQLCustomerRepo(db *sql.DB, d dialect.Dialect) *SQLCustomerRepo {
+	return &SQLCustomerRepo{DB: db, Dialect: d}
+}
+
+// Name retrieves a customer's full name by ID.
+func (repo *SQLCustomerRepo) Name(ctx context.Context, id int64) (string, error) {
+	var name string
+	if err := repo.DB.QueryRowContext(ctx, "SELECT full_name FROM customer WHERE id = ?", id).Scan(&name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("customer %w", ErrNotFound)
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+// ByUser returns the customer record linked to a login.
+func (repo *SQLCustomerRepo) ByUser(ctx context.Context, userID int64) (models.Customer, error) {
+	var cust models.Customer
+	err := repo.DB.QueryRowContext(ctx, "SELECT id, full_name, address, phone, user_id FROM customer WHERE user_id = ?", userID).
+		Scan(&cust.ID, &cust.FullName, &cust.Address, &cust.Phone, &cust.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cust, ErrNotFound
+	}
+	return cust, err
+}
+
+// CreateForUser inserts a customer record linked to userID.
+func (repo *SQLCustomerRepo) CreateForUser(ctx context.Context, userID int64, cust models.Customer) (models.Customer, error) {
+	// The unique index on user_id is the real guard; this lookup just gives a clean error in the common case.
+	if _, err := repo.ByUser(ctx, userID); err == nil {
+		return cust, ErrProfileExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return cust, err
+	}
+
+	res, err := repo.DB.ExecContext(ctx, "INSERT INTO customer (full_name, address, phone, user_id) VALUES (?, ?, ?, ?)",
+		cust.FullName, cust.Address, cust.Phone, userID)
+	if err != nil {
+		return cust, err
+	}
+	if cust.ID, err = res.LastInsertId(); err != nil {
+		return cust, err
+	}
+	cust.UserID = userID
+	return cust, nil
+}
+
+// UpdateForUser replaces name, address and phone of the record linked to userID.
+func (repo *SQLCustomerRepo) UpdateForUser(ctx context.Context, userID int64, cust models.Customer) (models.Customer, error) {
+	if _, err := repo.DB.ExecContext(ctx, "UPDATE customer SET full_name = ?, address = ?, phone = ? WHERE user_id = ?",
+		cust.FullName, cust.Address, cust.Phone, userID); err != nil {
+		return cust, err
+	}
+	// RowsAffected is 0 on MySQL when nothing changed, so read the row back instead.
+	return repo.ByUser(ctx, userID)
+}
+
+// AllWithAlbums returns albums and customers using multiple result sets.
+// Dialects without multi-result-set support (SQLite) fall back to one query per table.
+func (repo *SQLCustomerRepo) AllWithAlbums(ctx context.Context) ([]models.Album, []models.Customer, error) {
+	const albumsQuery = "SELECT " + albumColumns + " FROM album"
+	const customersQuery = "SELECT id, full_name, address, phone FROM customer"
+
+	query := albumsQuery + "; " + customersQuery + ";"
+	if !repo.Dialect.SupportsMultiResultSets() {
+		query = albumsQuery
+	}
+
+	rows, err := repo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var albums []models.Album
+	for rows.Next() {
+		a, err := scanAlbum(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		albums = append(albums, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if !repo.Dialect.SupportsMultiResultSets() {
+		rows.Close()
+		if rows, err = repo.DB.QueryContext(ctx, customersQuery); err != nil {
+			return nil, nil, err
+		}
+		defer rows.Close()
+	} else if !rows.NextResultSet() {
+		return nil, nil, fmt.Errorf("expected a second result set: %w", rows.Err())
+	}
+
+	var customers []models.Customer
+	for rows.Next() {
+		var cust models.Customer
+		if err := rows.Scan(&cust.ID, &cust.FullName, &cust.Address, &cust.Phone); err != nil {
+			return nil, nil, err
+		}
+		customers = append(customers, cust)
+	}
+	return albums, customers, rows.Err()
+}
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+func TestMemoryCustomerRepoProfiles(t *testing.T) {
+	repo := NewMemoryCustomerRepo(NewMemoryAlbumRepo(), models.Customer{ID: 1, FullName: "John Doe"})
+	ctx := context.Background()
+
+	if _, err := repo.ByUser(ctx, 5); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before a profile exists, got %v", err)
+	}
+
+	cust, err := repo.CreateForUser(ctx, 5, models.Customer{FullName: "Jane", Address: "Dhaka", Phone: "1"})
+	if err != nil || cust.ID != 2 || cust.UserID != 5 {
+		t.Fatalf("unexpected create result %+v, %v", cust, err)
+	}
+	if _, err := repo.CreateForUser(ctx, 5, cust); !errors.Is(err, ErrProfileExists) {
+		t.Fatalf("expected ErrProfileExists, got %v", err)
+	}
+
+	cust, err = repo.UpdateForUser(ctx, 5, models.Customer{FullName: "Jane Smith", Address: "Banani", Phone: "2"})
+	if err != nil || cust.ID != 2 || cust.FullName != "Jane Smith" {
+		t.Fatalf("unexpected update result %+v, %v", cust, err)
+	}
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned by repositories when the requested row does not exist.
+// SQL implementations translate sql.ErrNoRows into it so callers don't depend on database/sql.
+var ErrNotFound = errors.New("not found")
+
+// ErrInsufficientStock is returned when an order or stock adjustment would take quantity below zero.
+var ErrInsufficientStock = errors.New("not enough inventory")
+
+// ErrAlbumHasOrders is returned when deleting an album that album_order rows still reference.
+var ErrAlbumHasOrders = errors.New("album is referenced by existing orders")
+
+// ErrVersionConflict is returned when a conditional write names a version that is no longer current.
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrProfileExists is returned when creating a customer profile for a user who already has one.
+var ErrProfileExists = errors.New("customer profile already exists")
+
+// ErrUserExists is returned when registering a username or email that is already taken.
+var ErrUserExists = errors.New("username or email already registered")
+
+// ErrTwoFactorEnabled is returned when starting a 2FA enrollment for a user who already has 2FA on.
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+// ErrInvalidTitle is returned for a wiki page title that NormalizeTitle rejects.
+var ErrInvalidTitle = errors.New("invalid page title")
+
+// ErrOrderNotCancellable is returned when cancelling an order that has already shipped or been cancelled.
+var ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+
+// ErrEmptyOrder is returned when checking out an order without lines.
+var ErrEmptyOrder = errors.New("order has no lines")
+
+// ErrInvalidQuantity is returned for an order line whose quantity is not positive.
+var ErrInvalidQuantity = errors.New("quantity must be positive")
+
+// LineError explains why one order line could not be filled.
+// Err is ErrInsufficientStock, ErrInvalidQuantity, or wraps ErrNotFound for an unknown album.
+type LineError struct {
+	AlbumID   int64
+	Requested int64
+	Available int64
+	Err       error
+}
+
+func (e LineError) Error() string {
+	return e.Err.Error()
+}
+
+// StockError is returned when one or more lines of an order can't be filled.
+// It unwraps to every line's Err, so errors.Is(err, ErrInsufficientStock) still works.
+type StockError struct {
+	Lines []LineError
+}
+
+func (e *StockError) Error() string {
+	msgs := make([]string, len(e.Lines))
+	for i, l := range e.Lines {
+		msgs[i] = l.Error()
+		if len(e.Lines) > 1 {
+			msgs[i] = fmt.Sprintf("album %d: %s", l.AlbumID, msgs[i])
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *StockError) Unwrap() []error {
+	errs := make([]error, len(e.Lines))
+	for i, l := range e.Lines {
+		errs[i] = l.Err
+	}
+	return errs
+}
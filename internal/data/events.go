@@ -0,0 +1,25 @@
+package data
+
+import (
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// publishStock announces new album quantities on bus, which may be nil.
+// Call it only once the change is committed.
+func publishStock(bus *events.Bus, changes ...events.StockChanged) {
+	for _, ch := range changes {
+		bus.Publish(events.Event{Topic: events.TopicStockChanged, Data: ch})
+	}
+}
+
+// publishOrder announces a new order to its customer on bus, which may be nil.
+func publishOrder(bus *events.Bus, orderID, custID int64, items []models.OrderItem) {
+	var units int64
+	for _, it := range items {
+		units += it.Quantity
+	}
+	bus.Publish(events.Event{Topic: events.TopicOrderCreated, Customer: custID, Data: events.OrderCreated{
+		OrderID: orderID, CustomerID: custID, Quantity: units, Items: items,
+	}})
+}
@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// GrantRepository stores bearer-token grants (see models.Grant).
+type GrantRepository interface {
+	Create(ctx context.Context, g models.Grant) error
+	ByID(ctx context.Context, id string) (models.Grant, error)
+	// Rotate replaces the grant's refresh token ID only if it is still oldJTI.
+	// false means oldJTI was already used, which points at a stolen refresh token.
+	Rotate(ctx context.Context, id, oldJTI, newJTI string) (bool, error)
+	Revoke(ctx context.Context, id string) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+}
+
+// SQLGrantRepo implements GrantRepository using the auth_grant table.
+type SQLGrantRepo struct {
+	DB *sql.DB
+}
+
+// NewSQLGrantRepo creates a new SQLGrantRepo with a given DB connection.
+func NewSQLGrantRepo(db *sql.DB) *SQLGrantRepo {
+	return &SQLGrantRepo{DB: db}
+}
+
+// Create inserts a new grant.
+func (repo *SQLGrantRepo) Create(ctx context.Context, g models.Grant) error {
+	_, err := repo.DB.ExecContext(ctx, "INSERT INTO auth_grant (id, user_id, refresh_jti, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		g.ID, g.UserID, g.RefreshJTI, g.CreatedAt, g.ExpiresAt)
+	return err
+}
+
+// ByID returns a grant by ID.
+func (repo *SQLGrantRepo) ByID(ctx context.Context, id string) (models.Grant, error) {
+	var g models.Grant
+	var revoked sql.NullTime
+	err := repo.DB.QueryRowContext(ctx, "SELECT id, user_id, refresh_jti, created_at, expires_at, revoked_at FROM auth_grant WHERE id = ?", id).
+		Scan(&g.ID, &g.UserID, &g.RefreshJTI, &g.CreatedAt, &g.ExpiresAt, &revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return g, ErrNotFound
+	}
+	if revoked.Valid {
+		g.RevokedAt = &revoked.Time
+	}
+	return g, err
+}
+
+// Rotate swaps the refresh token ID with a compare-and-set UPDATE.
+func (repo *SQLGrantRepo) Rotate(ctx context.Context, id, oldJTI, newJTI string) (bool, error) {
+	res, err := repo.DB.ExecContext(ctx, "UPDATE auth_grant SET refresh_jti = ? WHERE id = ? AND refresh_jti = ? AND revoked_at IS NULL",
+		newJTI, id, oldJTI)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Revoke marks a grant as revoked.
+func (repo *SQLGrantRepo) Revoke(ctx context.Context, id string) error {
+	_, err := repo.DB.ExecContext(ctx, "UPDATE auth_grant SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	return err
+}
+
+// RevokeAllForUser revokes every grant of a user.
+func (repo *SQLGrantRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	_, err := repo.DB.ExecContext(ctx, "UPDATE auth_grant SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now(), userID)
+	return err
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// LoginAttemptQuery filters the login audit trail; zero fields match everything.
+type LoginAttemptQuery struct {
+	Username string
+	IP       string
+	Limit    int // newest first; 0 means 100
+}
+
+// LoginAttemptRepository stores the login audit trail.
+type LoginAttemptRepository interface {
+	Record(ctx context.Context, a models.LoginAttempt) error
+	// UserFailures returns the times of failed logins for username after since, newest first,
+	// ignoring failures before the user's last successful login or admin unlock.
+	UserFailures(ctx context.Context, username string, since time.Time, limit int) ([]time.Time, error)
+	// IPFailures returns the times of failed logins from ip after since, newest first.
+	IPFailures(ctx context.Context, ip string, since time.Time, limit int) ([]time.Time, error)
+	List(ctx context.Context, q LoginAttemptQuery) ([]models.LoginAttempt, error)
+}
+
+// SQLLoginAttemptRepo implements LoginAttemptRepository using the login_attempt table.
+type SQLLoginAttemptRepo struct {
+	DB *sql.DB
+}
+
+// NewSQLLoginAttemptRepo creates a new SQLLoginAttemptRepo with a given DB connection.
+func NewSQLLoginAttemptRepo(db *sql.DB) *SQLLoginAttemptRepo {
+	return &SQLLoginAttemptRepo{DB: db}
+}
+
+// Record appends an attempt to the audit trail.
+func (repo *SQLLoginAttemptRepo) Record(ctx context.Context, a models.LoginAttempt) error {
+	_, err := repo.DB.ExecContext(ctx, "INSERT INTO login_attempt (username, ip, outcome, created_at) VALUES (?, ?, ?, ?)",
+		a.Username, a.IP, a.Outcome, a.CreatedAt.UTC())
+	return err
+}
+
+// UserFailures returns recent failure times for username since its last reset.
+func (repo *SQLLoginAttemptRepo) UserFailures(ctx context.Context, username string, since time.Time, limit int) ([]time.Time, error) {
+	var reset time.Time
+	err := repo.DB.QueryRowContext(ctx, "SELECT created_at FROM login_attempt WHERE username = ? AND outcome IN (?, ?) ORDER BY created_at DESC LIMIT 1",
+		username, models.LoginSuccess, models.LoginUnlock).Scan(&reset)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if reset.After(since) {
+		since = reset
+	}
+	return repo.failureTimes(ctx, "username", username, since, limit)
+}
+
+// IPFailures returns recent failure times for ip.
+func (repo *SQLLoginAttemptRepo) IPFailures(ctx context.Context, ip string, since time.Time, limit int) ([]time.Time, error) {
+	return repo.failureTimes(ctx, "ip", ip, since, limit)
+}
+
+// failureTimes selects failure times where column = value; column is always a constant.
+func (repo *SQLLoginAttemptRepo) failureTimes(ctx context.Context, column, value string, since time.Time, limit int) ([]time.Time, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT created_at FROM login_attempt WHERE "+column+" = ? AND outcome = ? AND created_at > ? ORDER BY created_at DESC LIMIT ?",
+		value, models.LoginFailure, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
+// List returns matching attempts, newest first.
+func (repo *SQLLoginAttemptRepo) List(ctx context.Context, q LoginAttemptQuery) ([]models.LoginAttempt, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	rows, err := repo.DB.QueryContext(ctx, "SELECT id, username, ip, outcome, created_at FROM login_attempt "+
+		"WHERE (? = '' OR username = ?) AND (? = '' OR ip = ?) ORDER BY created_at DESC, id DESC LIMIT ?",
+		q.Username, q.Username, q.IP, q.IP, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		var a models.LoginAttempt
+		if err := rows.Scan(&a.ID, &a.Username, &a.IP, &a.Outcome, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
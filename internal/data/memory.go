@@ -0,0 +1,1125 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// In-memory repositories for tests and DB-less runs.
+// Each one is goroutine-safe and independent, so parallel tests don't share state.
+
+// MemoryAlbumRepo implements AlbumRepository in memory.
+type MemoryAlbumRepo struct {
+	Events *events.Bus // told about stock changes; may be nil
+
+	mu     sync.Mutex
+	albums map[int64]models.Album
+	orders map[int64]int // album ID -> number of orders referencing it
+	nextID int64
+}
+
+// NewMemoryAlbumRepo creates a MemoryAlbumRepo seeded with the given albums.
+// Albums without an ID are assigned one.
+func NewMemoryAlbumRepo(seed ...models.Album) *MemoryAlbumRepo {
+	repo := &MemoryAlbumRepo{albums: make(map[int64]models.Album), orders: make(map[int64]int)}
+	for _, a := range seed {
+		repo.put(a)
+	}
+	return repo
+}
+
+// put stores a, assigning the next ID when it has none. Callers must hold mu (or be constructing).
+func (repo *MemoryAlbumRepo) put(a models.Album) int64 {
+	if a.ID == 0 {
+		a.ID = repo.nextID + 1
+	}
+	if a.Version == 0 {
+		a.Version = 1
+	}
+	if a.ID > repo.nextID {
+		repo.nextID = a.ID
+	}
+	repo.albums[a.ID] = a
+	return a.ID
+}
+
+// sorted returns the albums matching keep, ordered by ID. Callers must hold mu.
+func (repo *MemoryAlbumRepo) sorted(keep func(models.Album) bool) []models.Album {
+	var albums []models.Album
+	for _, a := range repo.albums {
+		if keep(a) {
+			albums = append(albums, a)
+		}
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i].ID < albums[j].ID })
+	return albums
+}
+
+// All returns all albums ordered by ID.
+func (repo *MemoryAlbumRepo) All(ctx context.Context) ([]models.Album, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.sorted(func(models.Album) bool { return true }), nil
+}
+
+// List returns one page of albums matching q plus the total number of matches.
+func (repo *MemoryAlbumRepo) List(ctx context.Context, q AlbumQuery) ([]models.Album, int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	artist := strings.ToLower(q.Artist)
+	albums := repo.sorted(func(a models.Album) bool {
+		return (q.MinPrice == nil || a.Price >= *q.MinPrice) &&
+			(q.MaxPrice == nil || a.Price <= *q.MaxPrice) &&
+			(!q.InStock || a.Quantity > 0) &&
+			strings.Contains(strings.ToLower(a.Artist), artist)
+	})
+
+	sort.SliceStable(albums, func(i, j int) bool {
+		a, b := albums[i], albums[j]
+		if q.Desc {
+			a, b = b, a
+		}
+		switch q.SortBy {
+		case "title":
+			return a.Title < b.Title
+		case "artist":
+			return a.Artist < b.Artist
+		case "price":
+			return a.Price < b.Price
+		case "quantity":
+			return a.Quantity < b.Quantity
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(albums)
+	start := min(q.Offset, total)
+	end := min(start+q.Limit, total)
+	return albums[start:end], total, nil
+}
+
+// ByArtist returns albums whose artist matches name exactly.
+func (repo *MemoryAlbumRepo) ByArtist(ctx context.Context, name string) ([]models.Album, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.sorted(func(a models.Album) bool { return a.Artist == name }), nil
+}
+
+// ByID returns the album with the given ID.
+func (repo *MemoryAlbumRepo) ByID(ctx context.Context, id int64) (models.Album, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	a, ok := repo.albums[id]
+	if !ok {
+		return models.Album{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// Add stores a new album and returns its ID.
+func (repo *MemoryAlbumRepo) Add(ctx context.Context, alb models.Album) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	alb.ID, alb.Version = 0, 0
+	return repo.put(alb), nil
+}
+
+// Update replaces an existing album and bumps its version.
+func (repo *MemoryAlbumRepo) Update(ctx context.Context, alb models.Album, ifVersion int64) (models.Album, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	current, ok := repo.albums[alb.ID]
+	if !ok {
+		return models.Album{}, ErrNotFound
+	}
+	if ifVersion != 0 && current.Version != ifVersion {
+		return models.Album{}, ErrVersionConflict
+	}
+	alb.Version = current.Version + 1
+	repo.albums[alb.ID] = alb
+	publishStock(repo.Events, events.StockChanged{AlbumID: alb.ID, Quantity: alb.Quantity})
+	return alb, nil
+}
+
+// Delete removes an album unless orders reference it.
+func (repo *MemoryAlbumRepo) Delete(ctx context.Context, id, ifVersion int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	current, ok := repo.albums[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if ifVersion != 0 && current.Version != ifVersion {
+		return ErrVersionConflict
+	}
+	if repo.orders[id] > 0 {
+		return ErrAlbumHasOrders
+	}
+	delete(repo.albums, id)
+	return nil
+}
+
+// AdjustStock adds delta to the album's quantity unless it would drop below zero.
+func (repo *MemoryAlbumRepo) AdjustStock(ctx context.Context, id, delta int64) (models.Album, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	a, ok := repo.albums[id]
+	if !ok {
+		return a, ErrNotFound
+	}
+	if a.Quantity+delta < 0 {
+		return a, ErrInsufficientStock
+	}
+	if delta != 0 {
+		a.Quantity += delta
+		a.Version++
+		publishStock(repo.Events, events.StockChanged{AlbumID: id, Quantity: a.Quantity})
+	}
+	repo.albums[id] = a
+	return a, nil
+}
+
+// CanPurchase checks if the requested quantity is available for a given album.
+func (repo *MemoryAlbumRepo) CanPurchase(ctx context.Context, id, quantity int64) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	a, ok := repo.albums[id]
+	if !ok {
+		return false, fmt.Errorf("unknown album ID %d", id)
+	}
+	return a.Quantity >= quantity, nil
+}
+
+// MemoryBookRepo implements BookRepository in memory.
+type MemoryBookRepo struct {
+	mu     sync.Mutex
+	books  map[int]models.Book
+	nextID int
+}
+
+// NewMemoryBookRepo creates a MemoryBookRepo seeded with the given books.
+// Books without an ID are assigned one.
+func NewMemoryBookRepo(seed ...models.Book) *MemoryBookRepo {
+	repo := &MemoryBookRepo{books: make(map[int]models.Book)}
+	for _, b := range seed {
+		if b.ID == 0 {
+			b.ID = repo.nextID + 1
+		}
+		if b.Version == 0 {
+			b.Version = 1
+		}
+		repo.nextID = max(repo.nextID, b.ID)
+		repo.books[b.ID] = b
+	}
+	return repo
+}
+
+// All returns all books ordered by ID.
+func (repo *MemoryBookRepo) All(ctx context.Context) ([]models.Book, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	books := make([]models.Book, 0, len(repo.books))
+	for _, b := range repo.books {
+		books = append(books, b)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+	return books, nil
+}
+
+// ByID returns a copy of the book with the given ID.
+func (repo *MemoryBookRepo) ByID(ctx context.Context, id int) (models.Book, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	b, ok := repo.books[id]
+	if !ok {
+		return b, ErrNotFound
+	}
+	return b, nil
+}
+
+// Add stores a new book with the next free ID.
+func (repo *MemoryBookRepo) Add(ctx context.Context, b models.Book) (models.Book, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.nextID++
+	b.ID = repo.nextID
+	b.Version = 1
+	repo.books[b.ID] = b
+	return b, nil
+}
+
+// Update applies the non-empty fields of updated and bumps the version.
+func (repo *MemoryBookRepo) Update(ctx context.Context, id int, updated models.Book, ifVersion int) (models.Book, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	b, ok := repo.books[id]
+	if !ok {
+		return b, ErrNotFound
+	}
+	if ifVersion != 0 && b.Version != ifVersion {
+		return b, ErrVersionConflict
+	}
+	if updated.Title != "" {
+		b.Title = updated.Title
+	}
+	if updated.Author != "" {
+		b.Author = updated.Author
+	}
+	if updated.Price != 0 {
+		b.Price = updated.Price
+	}
+	b.Version++
+	repo.books[id] = b
+	return b, nil
+}
+
+// Delete removes a book by ID.
+func (repo *MemoryBookRepo) Delete(ctx context.Context, id int, ifVersion int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	b, ok := repo.books[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if ifVersion != 0 && b.Version != ifVersion {
+		return ErrVersionConflict
+	}
+	delete(repo.books, id)
+	return nil
+}
+
+// MemoryOrderRepo implements OrderRepository in memory, drawing stock from a MemoryAlbumRepo.
+type MemoryOrderRepo struct {
+	Events *events.Bus // told about new orders and the stock they take or return; may be nil
+
+	mu     sync.Mutex
+	albums *MemoryAlbumRepo
+	orders []models.GetOrder
+}
+
+// NewMemoryOrderRepo creates a MemoryOrderRepo that decrements stock in albums.
+func NewMemoryOrderRepo(albums *MemoryAlbumRepo) *MemoryOrderRepo {
+	return &MemoryOrderRepo{albums: albums}
+}
+
+// ByCustomer returns the last 10 orders for a customer, newest first.
+func (repo *MemoryOrderRepo) ByCustomer(ctx context.Context, custID int64) ([]models.GetOrder, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var orders []models.GetOrder
+	for i := len(repo.orders) - 1; i >= 0 && len(orders) < 10; i-- {
+		if o := repo.orders[i]; o.Customer == custID {
+			o.Items = nil // listings don't carry lines, like the SQL repo
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+// Create records a single-line order and decrements the album's stock atomically.
+func (repo *MemoryOrderRepo) Create(ctx context.Context, albumID, quantity, custID int64) (int64, error) {
+	return repo.Checkout(ctx, custID, []models.CartLine{{AlbumID: albumID, Quantity: quantity}})
+}
+
+// Checkout records an order with one item per line, decrementing stock for all lines or none.
+func (repo *MemoryOrderRepo) Checkout(ctx context.Context, custID int64, lines []models.CartLine) (int64, error) {
+	if len(lines) == 0 {
+		return 0, ErrEmptyOrder
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.albums.mu.Lock()
+	defer repo.albums.mu.Unlock()
+
+	// Check every line first so nothing needs undoing when one fails.
+	need := make(map[int64]int64)
+	var failed []LineError
+	for _, l := range lines {
+		a, ok := repo.albums.albums[l.AlbumID]
+		switch {
+		case l.Quantity <= 0:
+			failed = append(failed, LineError{AlbumID: l.AlbumID, Requested: l.Quantity, Err: ErrInvalidQuantity})
+		case !ok:
+			failed = append(failed, LineError{AlbumID: l.AlbumID, Requested: l.Quantity,
+				Err: fmt.Errorf("unknown album ID %d: %w", l.AlbumID, ErrNotFound)})
+		case l.Quantity > a.Quantity-need[l.AlbumID]: // not need+l.Quantity, which could overflow
+			failed = append(failed, LineError{AlbumID: l.AlbumID, Requested: l.Quantity,
+				Available: a.Quantity - need[l.AlbumID], Err: ErrInsufficientStock})
+		default:
+			need[l.AlbumID] += l.Quantity
+		}
+	}
+	if len(failed) > 0 {
+		return 0, &StockError{Lines: failed}
+	}
+
+	order := models.GetOrder{
+		ID:       int64(len(repo.orders) + 1),
+		AlbumID:  lines[0].AlbumID,
+		Customer: custID,
+		Date:     time.Now(),
+		Status:   models.OrderPending,
+	}
+	for _, l := range lines {
+		a := repo.albums.albums[l.AlbumID]
+		a.Quantity -= l.Quantity
+		a.Version++
+		repo.albums.albums[l.AlbumID] = a
+		repo.albums.orders[l.AlbumID]++
+
+		order.Quantity += l.Quantity
+		order.Items = append(order.Items, models.OrderItem{AlbumID: l.AlbumID, Quantity: l.Quantity, Price: a.Price})
+		publishStock(repo.Events, events.StockChanged{AlbumID: l.AlbumID, Quantity: a.Quantity})
+	}
+	repo.orders = append(repo.orders, order)
+	publishOrder(repo.Events, order.ID, custID, order.Items)
+	return order.ID, nil
+}
+
+// ByID returns a single order by its ID, including its lines.
+func (repo *MemoryOrderRepo) ByID(ctx context.Context, id int64) (models.GetOrder, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if id < 1 || id > int64(len(repo.orders)) {
+		return models.GetOrder{}, ErrNotFound
+	}
+	return repo.orders[id-1], nil
+}
+
+// Cancel marks a pending or paid order as cancelled and returns its items to stock.
+func (repo *MemoryOrderRepo) Cancel(ctx context.Context, id, custID int64) (models.GetOrder, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if id < 1 || id > int64(len(repo.orders)) || repo.orders[id-1].Customer != custID {
+		return models.GetOrder{}, ErrNotFound
+	}
+	o := &repo.orders[id-1]
+	if o.Status != models.OrderPending && o.Status != models.OrderPaid {
+		return *o, ErrOrderNotCancellable
+	}
+
+	repo.albums.mu.Lock()
+	defer repo.albums.mu.Unlock()
+	for _, it := range o.Items {
+		if a, ok := repo.albums.albums[it.AlbumID]; ok {
+			a.Quantity += it.Quantity
+			a.Version++
+			repo.albums.albums[it.AlbumID] = a
+			publishStock(repo.Events, events.StockChanged{AlbumID: it.AlbumID, Quantity: a.Quantity})
+		}
+	}
+	o.Status = models.OrderCancelled
+	return *o, nil
+}
+
+// MemoryCustomerRepo implements CustomerRepository in memory.
+type MemoryCustomerRepo struct {
+	mu        sync.Mutex
+	albums    *MemoryAlbumRepo
+	customers []models.Customer
+}
+
+// NewMemoryCustomerRepo creates a MemoryCustomerRepo seeded with customers; albums backs AllWithAlbums.
+func NewMemoryCustomerRepo(albums *MemoryAlbumRepo, seed ...models.Customer) *MemoryCustomerRepo {
+	return &MemoryCustomerRepo{albums: albums, customers: seed}
+}
+
+// Name retrieves a customer's full name by ID.
+func (repo *MemoryCustomerRepo) Name(ctx context.Context, id int64) (string, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, cust := range repo.customers {
+		if cust.ID == id {
+			return cust.FullName, nil
+		}
+	}
+	return "", fmt.Errorf("customer %w", ErrNotFound)
+}
+
+// ByUser returns the customer record linked to a login.
+func (repo *MemoryCustomerRepo) ByUser(ctx context.Context, userID int64) (models.Customer, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if i := repo.indexByUser(userID); i >= 0 {
+		return repo.customers[i], nil
+	}
+	return models.Customer{}, ErrNotFound
+}
+
+// indexByUser returns the position of userID's record, or -1. Callers must hold mu.
+func (repo *MemoryCustomerRepo) indexByUser(userID int64) int {
+	for i, cust := range repo.customers {
+		if userID != 0 && cust.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateForUser stores a customer record linked to userID.
+func (repo *MemoryCustomerRepo) CreateForUser(ctx context.Context, userID int64, cust models.Customer) (models.Customer, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.indexByUser(userID) >= 0 {
+		return cust, ErrProfileExists
+	}
+	cust.ID = 1
+	for _, c := range repo.customers {
+		cust.ID = max(cust.ID, c.ID+1)
+	}
+	cust.UserID = userID
+	repo.customers = append(repo.customers, cust)
+	return cust, nil
+}
+
+// UpdateForUser replaces name, address and phone of the record linked to userID.
+func (repo *MemoryCustomerRepo) UpdateForUser(ctx context.Context, userID int64, cust models.Customer) (models.Customer, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	i := repo.indexByUser(userID)
+	if i < 0 {
+		return cust, ErrNotFound
+	}
+	current := &repo.customers[i]
+	current.FullName, current.Address, current.Phone = cust.FullName, cust.Address, cust.Phone
+	return *current, nil
+}
+
+// AllWithAlbums returns every customer together with the album catalogue.
+func (repo *MemoryCustomerRepo) AllWithAlbums(ctx context.Context) ([]models.Album, []models.Customer, error) {
+	albums, err := repo.albums.All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return albums, append([]models.Customer(nil), repo.customers...), nil
+}
+
+// MemoryUserRepo implements UserRepository in memory.
+type MemoryUserRepo struct {
+	mu     sync.Mutex
+	users  map[int]models.User
+	nextID int
+}
+
+// NewMemoryUserRepo creates an empty MemoryUserRepo.
+func NewMemoryUserRepo() *MemoryUserRepo {
+	return &MemoryUserRepo{users: make(map[int]models.User)}
+}
+
+// All returns all users ordered by ID.
+func (repo *MemoryUserRepo) All(ctx context.Context) ([]models.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	users := make([]models.User, 0, len(repo.users))
+	for _, u := range repo.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+// ByID returns the user with the given ID.
+func (repo *MemoryUserRepo) ByID(ctx context.Context, id int) (*models.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	u, ok := repo.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+// Create stores a new user with a hashed password and returns the new ID.
+func (repo *MemoryUserRepo) Create(ctx context.Context, username, password string) (int64, error) {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, u := range repo.users {
+		if u.Username == username {
+			return 0, fmt.Errorf("username %q already exists", username)
+		}
+	}
+	repo.nextID++
+	repo.users[repo.nextID] = models.User{ID: repo.nextID, Username: username, Password: hashed, Role: models.RoleCustomer, CreatedAt: time.Now()}
+	return int64(repo.nextID), nil
+}
+
+// Update changes username and/or password for a given user ID.
+func (repo *MemoryUserRepo) Update(ctx context.Context, id int, username, password string) error {
+	var hashed string
+	if password != "" {
+		var err error
+		if hashed, err = HashPassword(password); err != nil {
+			return err
+		}
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	u, ok := repo.users[id]
+	if !ok {
+		return nil // matches UPDATE ... WHERE id = ? affecting no rows
+	}
+	if username != "" {
+		u.Username = username
+	}
+	if hashed != "" {
+		u.Password = hashed
+	}
+	repo.users[id] = u
+	return nil
+}
+
+// Delete removes a user by ID.
+func (repo *MemoryUserRepo) Delete(ctx context.Context, id int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.users, id)
+	return nil
+}
+
+// SetRole changes a user's role.
+func (repo *MemoryUserRepo) SetRole(ctx context.Context, id int, role string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	u, ok := repo.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Role = role
+	repo.users[id] = u
+	return nil
+}
+
+// Register stores a customer with an unverified email.
+func (repo *MemoryUserRepo) Register(ctx context.Context, username, email, password string) (int64, error) {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, u := range repo.users {
+		if u.Username == username || u.Email == email {
+			return 0, ErrUserExists
+		}
+	}
+	repo.nextID++
+	repo.users[repo.nextID] = models.User{ID: repo.nextID, Username: username, Password: hashed, Role: models.RoleCustomer,
+		CreatedAt: time.Now(), Email: email}
+	return int64(repo.nextID), nil
+}
+
+// ByEmail returns the user with the given email.
+func (repo *MemoryUserRepo) ByEmail(ctx context.Context, email string) (*models.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, u := range repo.users {
+		if email != "" && u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// MarkEmailVerified sets EmailVerifiedAt if it isn't set yet.
+func (repo *MemoryUserRepo) MarkEmailVerified(ctx context.Context, id int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if u, ok := repo.users[id]; ok && u.EmailVerifiedAt == nil {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+		repo.users[id] = u
+	}
+	return nil
+}
+
+// MemoryGrantRepo implements GrantRepository in memory.
+type MemoryGrantRepo struct {
+	mu     sync.Mutex
+	grants map[string]models.Grant
+}
+
+// NewMemoryGrantRepo creates an empty MemoryGrantRepo.
+func NewMemoryGrantRepo() *MemoryGrantRepo {
+	return &MemoryGrantRepo{grants: make(map[string]models.Grant)}
+}
+
+// Create stores a new grant.
+func (repo *MemoryGrantRepo) Create(ctx context.Context, g models.Grant) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.grants[g.ID] = g
+	return nil
+}
+
+// ByID returns a grant by ID.
+func (repo *MemoryGrantRepo) ByID(ctx context.Context, id string) (models.Grant, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	g, ok := repo.grants[id]
+	if !ok {
+		return g, ErrNotFound
+	}
+	return g, nil
+}
+
+// Rotate replaces the refresh token ID if it is still oldJTI.
+func (repo *MemoryGrantRepo) Rotate(ctx context.Context, id, oldJTI, newJTI string) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	g, ok := repo.grants[id]
+	if !ok || g.RevokedAt != nil || g.RefreshJTI != oldJTI {
+		return false, nil
+	}
+	g.RefreshJTI = newJTI
+	repo.grants[id] = g
+	return true, nil
+}
+
+// Revoke marks a grant as revoked.
+func (repo *MemoryGrantRepo) Revoke(ctx context.Context, id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if g, ok := repo.grants[id]; ok && g.RevokedAt == nil {
+		now := time.Now()
+		g.RevokedAt = &now
+		repo.grants[id] = g
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every grant of a user.
+func (repo *MemoryGrantRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	now := time.Now()
+	for id, g := range repo.grants {
+		if g.UserID == userID && g.RevokedAt == nil {
+			g.RevokedAt = &now
+			repo.grants[id] = g
+		}
+	}
+	return nil
+}
+
+// MemorySessionRepo implements SessionRepository in memory.
+type MemorySessionRepo struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+}
+
+// NewMemorySessionRepo creates an empty MemorySessionRepo.
+func NewMemorySessionRepo() *MemorySessionRepo {
+	return &MemorySessionRepo{sessions: make(map[string]models.Session)}
+}
+
+// ByID returns an unexpired session by ID.
+func (repo *MemorySessionRepo) ByID(ctx context.Context, id string) (models.Session, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	s, ok := repo.sessions[id]
+	if !ok || !time.Now().Before(s.ExpiresAt) {
+		return models.Session{}, ErrNotFound
+	}
+	return s, nil
+}
+
+// Create stores a new session.
+func (repo *MemorySessionRepo) Create(ctx context.Context, s models.Session) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.sessions[s.ID] = s
+	return nil
+}
+
+// Update replaces an existing session, keeping CreatedAt; deleted sessions are ErrNotFound.
+func (repo *MemorySessionRepo) Update(ctx context.Context, s models.Session) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	old, ok := repo.sessions[s.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	s.CreatedAt = old.CreatedAt
+	repo.sessions[s.ID] = s
+	return nil
+}
+
+// Touch updates LastSeenAt, IP and UserAgent.
+func (repo *MemorySessionRepo) Touch(ctx context.Context, id string, seen time.Time, ip, userAgent string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if s, ok := repo.sessions[id]; ok {
+		s.LastSeenAt, s.IP, s.UserAgent = seen, ip, userAgent
+		repo.sessions[id] = s
+	}
+	return nil
+}
+
+// ByUser returns a user's unexpired sessions, most recently seen first.
+func (repo *MemorySessionRepo) ByUser(ctx context.Context, userID int64) ([]models.Session, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	now := time.Now()
+	var sessions []models.Session
+	for _, s := range repo.sessions {
+		if s.UserID == userID && now.Before(s.ExpiresAt) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+// Delete removes a session.
+func (repo *MemorySessionRepo) Delete(ctx context.Context, id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.sessions, id)
+	return nil
+}
+
+// DeleteForUser removes one session belonging to userID.
+func (repo *MemorySessionRepo) DeleteForUser(ctx context.Context, userID int64, id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if s, ok := repo.sessions[id]; !ok || s.UserID != userID {
+		return ErrNotFound
+	}
+	delete(repo.sessions, id)
+	return nil
+}
+
+// DeleteAllForUser removes every session of userID.
+func (repo *MemorySessionRepo) DeleteAllForUser(ctx context.Context, userID int64) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var n int64
+	for id, s := range repo.sessions {
+		if s.UserID == userID {
+			delete(repo.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteExpired removes sessions that expired before now.
+func (repo *MemorySessionRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var n int64
+	for id, s := range repo.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(repo.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// MemoryLoginAttemptRepo implements LoginAttemptRepository in memory.
+type MemoryLoginAttemptRepo struct {
+	mu       sync.Mutex
+	attempts []models.LoginAttempt // in insertion (time) order
+}
+
+// NewMemoryLoginAttemptRepo creates an empty MemoryLoginAttemptRepo.
+func NewMemoryLoginAttemptRepo() *MemoryLoginAttemptRepo {
+	return &MemoryLoginAttemptRepo{}
+}
+
+// Record appends an attempt.
+func (repo *MemoryLoginAttemptRepo) Record(ctx context.Context, a models.LoginAttempt) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	a.ID = int64(len(repo.attempts) + 1)
+	repo.attempts = append(repo.attempts, a)
+	return nil
+}
+
+// UserFailures returns recent failure times for username since its last reset.
+func (repo *MemoryLoginAttemptRepo) UserFailures(ctx context.Context, username string, since time.Time, limit int) ([]time.Time, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var times []time.Time
+	for i := len(repo.attempts) - 1; i >= 0 && len(times) < limit; i-- {
+		a := repo.attempts[i]
+		if a.Username != username {
+			continue
+		}
+		if !a.CreatedAt.After(since) || a.Outcome == models.LoginSuccess || a.Outcome == models.LoginUnlock {
+			break
+		}
+		if a.Outcome == models.LoginFailure {
+			times = append(times, a.CreatedAt)
+		}
+	}
+	return times, nil
+}
+
+// IPFailures returns recent failure times for ip.
+func (repo *MemoryLoginAttemptRepo) IPFailures(ctx context.Context, ip string, since time.Time, limit int) ([]time.Time, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var times []time.Time
+	for i := len(repo.attempts) - 1; i >= 0 && len(times) < limit; i-- {
+		a := repo.attempts[i]
+		if !a.CreatedAt.After(since) {
+			break
+		}
+		if a.IP == ip && a.Outcome == models.LoginFailure {
+			times = append(times, a.CreatedAt)
+		}
+	}
+	return times, nil
+}
+
+// List returns matching attempts, newest first.
+func (repo *MemoryLoginAttemptRepo) List(ctx context.Context, q LoginAttemptQuery) ([]models.LoginAttempt, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	var attempts []models.LoginAttempt
+	for i := len(repo.attempts) - 1; i >= 0 && len(attempts) < q.Limit; i-- {
+		a := repo.attempts[i]
+		if (q.Username == "" || a.Username == q.Username) && (q.IP == "" || a.IP == q.IP) {
+			attempts = append(attempts, a)
+		}
+	}
+	return attempts, nil
+}
+
+// MemoryUserTokenRepo implements UserTokenRepository in memory.
+// It shares users with a MemoryUserRepo so ResetPassword can change passwords.
+type MemoryUserTokenRepo struct {
+	mu     sync.Mutex
+	tokens map[string]memoryUserToken // by userTokenID
+	users  *MemoryUserRepo
+}
+
+type memoryUserToken struct {
+	userID    int64
+	purpose   string
+	expiresAt time.Time
+	used      bool
+}
+
+// NewMemoryUserTokenRepo creates an empty MemoryUserTokenRepo for the users in users.
+func NewMemoryUserTokenRepo(users *MemoryUserRepo) *MemoryUserTokenRepo {
+	return &MemoryUserTokenRepo{tokens: make(map[string]memoryUserToken), users: users}
+}
+
+// Issue stores a new token.
+func (repo *MemoryUserTokenRepo) Issue(ctx context.Context, userID int64, purpose string, ttl time.Duration) (string, error) {
+	token, id := newUserToken()
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.tokens[id] = memoryUserToken{userID: userID, purpose: purpose, expiresAt: time.Now().Add(ttl)}
+	return token, nil
+}
+
+// Consume marks a token used and returns its user.
+func (repo *MemoryUserTokenRepo) Consume(ctx context.Context, token, purpose string) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	id := userTokenID(token)
+	t, ok := repo.usable(id, purpose)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	t.used = true
+	repo.tokens[id] = t
+	return t.userID, nil
+}
+
+// Lookup returns the user of a usable token.
+func (repo *MemoryUserTokenRepo) Lookup(ctx context.Context, token, purpose string) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	t, ok := repo.usable(userTokenID(token), purpose)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return t.userID, nil
+}
+
+// ResetPassword sets the password of a usable reset token's user and marks the token used.
+func (repo *MemoryUserTokenRepo) ResetPassword(ctx context.Context, token, password string) (int64, error) {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	id := userTokenID(token)
+	t, ok := repo.usable(id, TokenResetPassword)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	repo.users.mu.Lock()
+	defer repo.users.mu.Unlock()
+	u, ok := repo.users.users[int(t.userID)]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	u.Password = hashed
+	repo.users.users[int(t.userID)] = u
+	t.used = true
+	repo.tokens[id] = t
+	return t.userID, nil
+}
+
+// usable returns the token stored as id if it is unused, unexpired and for purpose. Callers must hold mu.
+func (repo *MemoryUserTokenRepo) usable(id, purpose string) (memoryUserToken, bool) {
+	t, ok := repo.tokens[id]
+	return t, ok && !t.used && t.purpose == purpose && time.Now().Before(t.expiresAt)
+}
+
+// Invalidate marks a user's outstanding tokens for purpose as used.
+func (repo *MemoryUserTokenRepo) Invalidate(ctx context.Context, userID int64, purpose string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for id, t := range repo.tokens {
+		if t.userID == userID && t.purpose == purpose {
+			t.used = true
+			repo.tokens[id] = t
+		}
+	}
+	return nil
+}
+
+// MemoryTwoFactorRepo implements TwoFactorRepository in memory.
+type MemoryTwoFactorRepo struct {
+	mu    sync.Mutex
+	totp  map[int64]models.TwoFactor
+	codes map[int64]map[string]bool // user -> code hash -> used
+}
+
+// NewMemoryTwoFactorRepo creates an empty MemoryTwoFactorRepo.
+func NewMemoryTwoFactorRepo() *MemoryTwoFactorRepo {
+	return &MemoryTwoFactorRepo{totp: make(map[int64]models.TwoFactor), codes: make(map[int64]map[string]bool)}
+}
+
+// ByUser returns a copy of a user's enrollment.
+func (repo *MemoryTwoFactorRepo) ByUser(ctx context.Context, userID int64) (*models.TwoFactor, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	t, ok := repo.totp[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+// Begin replaces any unconfirmed enrollment with a new one.
+func (repo *MemoryTwoFactorRepo) Begin(ctx context.Context, userID int64, secret string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if t, ok := repo.totp[userID]; ok && t.Enabled() {
+		return ErrTwoFactorEnabled
+	}
+	repo.totp[userID] = models.TwoFactor{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+// Enable confirms an unconfirmed enrollment and stores its recovery codes.
+func (repo *MemoryTwoFactorRepo) Enable(ctx context.Context, userID, step int64, codeHashes []string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	t, ok := repo.totp[userID]
+	if !ok || t.Enabled() {
+		return ErrNotFound
+	}
+	now := time.Now()
+	t.EnabledAt, t.LastStep = &now, step
+	repo.totp[userID] = t
+	repo.replaceCodes(userID, codeHashes)
+	return nil
+}
+
+// UseStep advances the last accepted step.
+func (repo *MemoryTwoFactorRepo) UseStep(ctx context.Context, userID, step int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	t, ok := repo.totp[userID]
+	if !ok || !t.Enabled() || t.LastStep >= step {
+		return ErrNotFound
+	}
+	t.LastStep = step
+	repo.totp[userID] = t
+	return nil
+}
+
+// UseRecoveryCode marks a matching unused code used.
+func (repo *MemoryTwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	used, ok := repo.codes[userID][codeHash]
+	if !ok || used {
+		return ErrNotFound
+	}
+	repo.codes[userID][codeHash] = true
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes.
+func (repo *MemoryTwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.replaceCodes(userID, codeHashes)
+	return nil
+}
+
+func (repo *MemoryTwoFactorRepo) replaceCodes(userID int64, codeHashes []string) {
+	codes := make(map[string]bool, len(codeHashes))
+	for _, h := range codeHashes {
+		codes[h] = false
+	}
+	repo.codes[userID] = codes
+}
+
+// RecoveryCodesLeft counts unused recovery codes.
+func (repo *MemoryTwoFactorRepo) RecoveryCodesLeft(ctx context.Context, userID int64) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	n := 0
+	for _, used := range repo.codes[userID] {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Delete removes the enrollment and its recovery codes.
+func (repo *MemoryTwoFactorRepo) Delete(ctx context.Context, userID int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.totp, userID)
+	delete(repo.codes, userID)
+	return nil
+}
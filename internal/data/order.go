@@ -0,0 +1,243 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// OrderRepository provides access to customer orders.
+type OrderRepository interface {
+	ByCustomer(ctx context.Context, custID int64) ([]models.GetOrder, error)
+	// ByID returns a single order; ownership is checked by the caller.
+	ByID(ctx context.Context, id int64) (models.GetOrder, error)
+	Create(ctx context.Context, albumID, quantity, custID int64) (int64, error)
+	// Checkout creates one order from several lines, or fails with a *StockError listing every line that can't be filled.
+	Checkout(ctx context.Context, custID int64, lines []models.CartLine) (int64, error)
+	// Cancel marks a pending or paid order of custID as cancelled and returns its items to stock.
+	// Orders of other customers are reported as ErrNotFound.
+	Cancel(ctx context.Context, id, custID int64) (models.GetOrder, error)
+}
+
+// orderColumns is the column list scanOrder expects, in order.
+const orderColumns = "id, album_id, cust_id, quantity, date, status"
+
+// scanOrder reads one orderColumns row from a *sql.Row or *sql.Rows.
+func scanOrder(row interface{ Scan(...any) error }) (models.GetOrder, error) {
+	var o models.GetOrder
+	err := row.Scan(&o.ID, &o.AlbumID, &o.Customer, &o.Quantity, &o.Date, &o.Status)
+	return o, err
+}
+
+// SQLOrderRepo implements OrderRepository using a SQL database.
+type SQLOrderRepo struct {
+	DB     *sql.DB
+	Events *events.Bus // told about new orders and the stock they take or return; may be nil
+}
+
+// NewSQLOrderRepo creates a new SQLOrderRepo with a given DB connection.
+func NewSQLOrderRepo(db *sql.DB) *SQLOrderRepo {
+	return &SQLOrderRepo{DB: db}
+}
+
+// ByCustomer returns the last 10 orders for a customer.
+func (repo *SQLOrderRepo) ByCustomer(ctx context.Context, custID int64) ([]models.GetOrder, error) {
+	time.Sleep(2 * time.Second) // Artificial delay for testing only
+
+	rows, err := repo.DB.QueryContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM album_order
+		WHERE cust_id = ?
+		ORDER BY date DESC
+		LIMIT 10
+	`, custID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.GetOrder
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// ByID returns a single order by its ID, including its lines.
+func (repo *SQLOrderRepo) ByID(ctx context.Context, id int64) (models.GetOrder, error) {
+	o, err := scanOrder(repo.DB.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM album_order WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return o, ErrNotFound
+	}
+	if err != nil {
+		return o, err
+	}
+	o.Items, err = orderItems(ctx, repo.DB, id)
+	return o, err
+}
+
+// orderItems returns the lines of an order; q is the transaction, if any.
+func orderItems(ctx context.Context, q rowQuerier, orderID int64) ([]models.OrderItem, error) {
+	rows, err := q.QueryContext(ctx, "SELECT album_id, quantity, price FROM order_item WHERE order_id = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.OrderItem
+	for rows.Next() {
+		var it models.OrderItem
+		if err := rows.Scan(&it.AlbumID, &it.Quantity, &it.Price); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// Create creates a single-line order for a user within a transaction (all-or-nothing).
+func (repo *SQLOrderRepo) Create(ctx context.Context, albumID, quantity, custID int64) (int64, error) {
+	return repo.Checkout(ctx, custID, []models.CartLine{{AlbumID: albumID, Quantity: quantity}})
+}
+
+// Checkout creates one order with a line per cart line within a transaction (all-or-nothing).
+// Every line is tried so a *StockError can report all lines that can't be filled, not just the first.
+func (repo *SQLOrderRepo) Checkout(ctx context.Context, custID int64, lines []models.CartLine) (int64, error) {
+	if len(lines) == 0 {
+		return 0, ErrEmptyOrder
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	items := make([]models.OrderItem, 0, len(lines))
+	stock := make([]events.StockChanged, 0, len(lines))
+	var failed []LineError
+	var units int64
+	for _, l := range lines {
+		// The lines come from the session; a non-positive quantity would add stock.
+		if l.Quantity <= 0 {
+			failed = append(failed, LineError{AlbumID: l.AlbumID, Requested: l.Quantity, Err: ErrInvalidQuantity})
+			continue
+		}
+		// Check and decrement in one statement so concurrent orders can't both pass the check.
+		res, err := tx.ExecContext(ctx, "UPDATE album SET quantity = quantity - ?, version = version + 1 WHERE id = ? AND quantity >= ?",
+			l.Quantity, l.AlbumID, l.Quantity)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+
+		var available int64
+		var price float32
+		err = tx.QueryRowContext(ctx, "SELECT quantity, price FROM album WHERE id = ?", l.AlbumID).Scan(&available, &price)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			failed = append(failed, LineError{AlbumID: l.AlbumID, Requested: l.Quantity,
+				Err: fmt.Errorf("unknown album ID %d: %w", l.AlbumID, ErrNotFound)})
+		case err != nil:
+			return 0, err
+		case n == 0:
+			failed = append(failed, LineError{AlbumID: l.AlbumID, Requested: l.Quantity, Available: available, Err: ErrInsufficientStock})
+		default:
+			items = append(items, models.OrderItem{AlbumID: l.AlbumID, Quantity: l.Quantity, Price: price})
+			stock = append(stock, events.StockChanged{AlbumID: l.AlbumID, Quantity: available})
+			units += l.Quantity
+		}
+	}
+	if len(failed) > 0 {
+		return 0, &StockError{Lines: failed}
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO album_order (album_id, cust_id, quantity, date, status) VALUES (?, ?, ?, ?, ?)",
+		items[0].AlbumID, custID, units, time.Now(), models.OrderPending)
+	if err != nil {
+		return 0, err
+	}
+
+	orderID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, it := range items {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO order_item (order_id, album_id, quantity, price) VALUES (?, ?, ?, ?)",
+			orderID, it.AlbumID, it.Quantity, it.Price); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	publishStock(repo.Events, stock...)
+	publishOrder(repo.Events, orderID, custID, items)
+	return orderID, nil
+}
+
+// Cancel cancels an order and restocks its albums in one transaction.
+func (repo *SQLOrderRepo) Cancel(ctx context.Context, id, custID int64) (models.GetOrder, error) {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.GetOrder{}, err
+	}
+	defer tx.Rollback()
+
+	o, err := scanOrder(tx.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM album_order WHERE id = ? AND cust_id = ?", id, custID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return o, ErrNotFound
+	}
+	if err != nil {
+		return o, err
+	}
+
+	// The status condition keeps two concurrent cancels from restocking twice.
+	res, err := tx.ExecContext(ctx, "UPDATE album_order SET status = ? WHERE id = ? AND status IN (?, ?)",
+		models.OrderCancelled, id, models.OrderPending, models.OrderPaid)
+	if err != nil {
+		return o, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return o, err
+	} else if n == 0 {
+		return o, ErrOrderNotCancellable
+	}
+
+	if o.Items, err = orderItems(ctx, tx, id); err != nil {
+		return o, err
+	}
+	stock := make([]events.StockChanged, 0, len(o.Items))
+	for _, it := range o.Items {
+		if _, err := tx.ExecContext(ctx, "UPDATE album SET quantity = quantity + ?, version = version + 1 WHERE id = ?",
+			it.Quantity, it.AlbumID); err != nil {
+			return o, err
+		}
+		ch := events.StockChanged{AlbumID: it.AlbumID}
+		if err := tx.QueryRowContext(ctx, "SELECT quantity FROM album WHERE id = ?", it.AlbumID).Scan(&ch.Quantity); err != nil {
+			return o, err
+		}
+		stock = append(stock, ch)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return o, err
+	}
+	publishStock(repo.Events, stock...)
+	o.Status = models.OrderCancelled
+	return o, nil
+}
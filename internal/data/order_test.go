@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+func TestMemoryOrderRepoCancelRestocks(t *testing.T) {
+	albums := NewMemoryAlbumRepo(models.Album{Title: "Go Beats", Artist: "Gopher", Price: 9.99, Quantity: 5})
+	orders := NewMemoryOrderRepo(albums)
+	ctx := context.Background()
+
+	id, err := orders.Create(ctx, 1, 3, 7)
+	if err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	if _, err := orders.Cancel(ctx, id, 8); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for another customer's order, got %v", err)
+	}
+
+	o, err := orders.Cancel(ctx, id, 7)
+	if err != nil || o.Status != models.OrderCancelled {
+		t.Fatalf("unexpected cancel result %+v, %v", o, err)
+	}
+	if a, _ := albums.ByID(ctx, 1); a.Quantity != 5 {
+		t.Fatalf("expected stock restored to 5, got %d", a.Quantity)
+	}
+
+	if _, err := orders.Cancel(ctx, id, 7); !errors.Is(err, ErrOrderNotCancellable) {
+		t.Fatalf("expected ErrOrderNotCancellable on second cancel, got %v", err)
+	}
+}
+
+func TestMemoryOrderRepoCheckoutReportsEveryLine(t *testing.T) {
+	albums := NewMemoryAlbumRepo(
+		models.Album{Title: "Go Beats", Artist: "Gopher", Price: 9.99, Quantity: 5},
+		models.Album{Title: "Chan Songs", Artist: "Gopher", Price: 4.50, Quantity: 1},
+	)
+	orders := NewMemoryOrderRepo(albums)
+	ctx := context.Background()
+
+	_, err := orders.Checkout(ctx, 7, []models.CartLine{{AlbumID: 1, Quantity: 2}, {AlbumID: 2, Quantity: 3}, {AlbumID: 9, Quantity: 1}})
+	var stockErr *StockError
+	if !errors.As(err, &stockErr) || len(stockErr.Lines) != 2 {
+		t.Fatalf("expected a StockError with 2 lines, got %v", err)
+	}
+	if l := stockErr.Lines[0]; l.AlbumID != 2 || l.Available != 1 || !errors.Is(l.Err, ErrInsufficientStock) {
+		t.Errorf("unexpected first line %+v", l)
+	}
+	if l := stockErr.Lines[1]; l.AlbumID != 9 || !errors.Is(l.Err, ErrNotFound) {
+		t.Errorf("unexpected second line %+v", l)
+	}
+	if a, _ := albums.ByID(ctx, 1); a.Quantity != 5 {
+		t.Fatalf("failed checkout must not touch stock, got %d", a.Quantity)
+	}
+
+	id, err := orders.Checkout(ctx, 7, []models.CartLine{{AlbumID: 1, Quantity: 2}, {AlbumID: 2, Quantity: 1}})
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	o, _ := orders.ByID(ctx, id)
+	if len(o.Items) != 2 || o.Quantity != 3 || o.Items[1].Price != 4.50 {
+		t.Fatalf("unexpected order %+v", o)
+	}
+}
+
+// drain returns the events queued on sub.
+func drain(sub *events.Subscription) []events.Event {
+	var got []events.Event
+	for {
+		select {
+		case e := <-sub.C:
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
+
+func TestSQLOrderRepoPublishesEvents(t *testing.T) {
+	db := openSQLiteDB(t)
+	bus := events.NewBus()
+	orders := &SQLOrderRepo{DB: db, Events: bus}
+	mine := bus.Subscribe(2, events.TopicStockChanged, events.TopicOrderCreated)
+	theirs := bus.Subscribe(3, events.TopicOrderCreated)
+	ctx := context.Background()
+
+	// Seeded stock: album 1 has 10, album 3 has 12.
+	id, err := orders.Checkout(ctx, 2, []models.CartLine{{AlbumID: 1, Quantity: 2}, {AlbumID: 3, Quantity: 1}})
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	got := drain(mine)
+	if len(got) != 3 ||
+		got[0].Data != (events.StockChanged{AlbumID: 1, Quantity: 8}) ||
+		got[1].Data != (events.StockChanged{AlbumID: 3, Quantity: 11}) {
+		t.Fatalf("checkout published %+v, want two stock changes and the order", got)
+	}
+	if o, ok := got[2].Data.(events.OrderCreated); !ok || o.OrderID != id || o.CustomerID != 2 || o.Quantity != 3 || len(o.Items) != 2 {
+		t.Errorf("order event %+v", got[2].Data)
+	}
+	if got := drain(theirs); len(got) != 0 {
+		t.Errorf("another customer received %+v", got)
+	}
+
+	if _, err := orders.Checkout(ctx, 2, []models.CartLine{{AlbumID: 1, Quantity: 100}}); err == nil {
+		t.Fatal("checkout beyond stock succeeded")
+	}
+	if got := drain(mine); len(got) != 0 {
+		t.Errorf("failed checkout published %+v", got)
+	}
+
+	if _, err := orders.Cancel(ctx, id, 2); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if got := drain(mine); len(got) != 2 || got[0].Data != (events.StockChanged{AlbumID: 1, Quantity: 10}) {
+		t.Errorf("cancel published %+v, want the restocked quantities", got)
+	}
+}
+
+func TestCheckoutRejectsNonPositiveQuantity(t *testing.T) {
+	ctx := context.Background()
+	for name, orders := range map[string]OrderRepository{
+		"memory": NewMemoryOrderRepo(NewMemoryAlbumRepo(models.Album{Title: "Go Beats", Artist: "Gopher", Price: 9.99, Quantity: 10})),
+		"sql":    &SQLOrderRepo{DB: openSQLiteDB(t)},
+	} {
+		for _, q := range []int64{0, -5, math.MinInt64} {
+			_, err := orders.Checkout(ctx, 2, []models.CartLine{{AlbumID: 1, Quantity: q}})
+			var stockErr *StockError
+			if !errors.As(err, &stockErr) || len(stockErr.Lines) != 1 || !errors.Is(err, ErrInvalidQuantity) {
+				t.Errorf("%s: checkout of %d got %v, want ErrInvalidQuantity", name, q, err)
+			}
+		}
+		if _, err := orders.Checkout(ctx, 2, []models.CartLine{{AlbumID: 1, Quantity: math.MaxInt64}, {AlbumID: 1, Quantity: math.MaxInt64}}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("%s: checkout of two huge lines got %v, want ErrInsufficientStock", name, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/dialect"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// SessionRepository stores server-side sessions (see models.Session).
+// Expired sessions are treated as missing; DeleteExpired removes them for good.
+type SessionRepository interface {
+	ByID(ctx context.Context, id string) (models.Session, error)
+	Create(ctx context.Context, s models.Session) error
+	// Update replaces the data, owner, client and expiry of an existing session;
+	// it returns ErrNotFound once the session has been deleted.
+	Update(ctx context.Context, s models.Session) error
+	// Touch records activity without rewriting the session data.
+	Touch(ctx context.Context, id string, seen time.Time, ip, userAgent string) error
+	// ByUser returns a user's unexpired sessions, most recently seen first.
+	ByUser(ctx context.Context, userID int64) ([]models.Session, error)
+	Delete(ctx context.Context, id string) error
+	// DeleteForUser deletes one session of userID; it returns ErrNotFound for other users' sessions.
+	DeleteForUser(ctx context.Context, userID int64, id string) error
+	DeleteAllForUser(ctx context.Context, userID int64) (int64, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// SQLSessionRepo implements SessionRepository using the user_session table.
+type SQLSessionRepo struct {
+	DB      *sql.DB
+	Dialect dialect.Dialect
+}
+
+// NewSQLSessionRepo creates a new SQLSessionRepo with a given DB connection and dialect.
+func NewSQLSessionRepo(db *sql.DB, d dialect.Dialect) *SQLSessionRepo {
+	return &SQLSessionRepo{DB: db, Dialect: d}
+}
+
+const sessionColumns = "id, user_id, data, ip, user_agent, created_at, last_seen_at, expires_at"
+
+// scanSession scans one row selected with sessionColumns.
+func scanSession(row interface{ Scan(...any) error }) (models.Session, error) {
+	var s models.Session
+	var userID sql.NullInt64
+	err := row.Scan(&s.ID, &userID, &s.Data, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	s.UserID = userID.Int64
+	return s, err
+}
+
+// nullUserID stores anonymous sessions (user 0) as NULL to satisfy the foreign key.
+func nullUserID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// ByID returns an unexpired session by ID.
+func (repo *SQLSessionRepo) ByID(ctx context.Context, id string) (models.Session, error) {
+	s, err := scanSession(repo.DB.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM user_session WHERE id = ? AND expires_at > ?", id, time.Now()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+// Create inserts a new session.
+func (repo *SQLSessionRepo) Create(ctx context.Context, s models.Session) error {
+	_, err := repo.DB.ExecContext(ctx, "INSERT INTO user_session ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		s.ID, nullUserID(s.UserID), s.Data, s.IP, s.UserAgent, s.CreatedAt, s.LastSeenAt, s.ExpiresAt)
+	return err
+}
+
+// Update rewrites an existing session, keeping created_at. It never re-creates a
+// deleted row, so a request still in flight can't undo a revocation.
+func (repo *SQLSessionRepo) Update(ctx context.Context, s models.Session) error {
+	res, err := repo.DB.ExecContext(ctx, "UPDATE user_session SET user_id = ?, data = ?, ip = ?, user_agent = ?, last_seen_at = ?, expires_at = ? WHERE id = ?",
+		nullUserID(s.UserID), s.Data, s.IP, s.UserAgent, s.LastSeenAt, s.ExpiresAt, s.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Touch updates last_seen_at, ip and user_agent.
+func (repo *SQLSessionRepo) Touch(ctx context.Context, id string, seen time.Time, ip, userAgent string) error {
+	_, err := repo.DB.ExecContext(ctx, "UPDATE user_session SET last_seen_at = ?, ip = ?, user_agent = ? WHERE id = ?",
+		seen, ip, userAgent, id)
+	return err
+}
+
+// ByUser returns a user's unexpired sessions, most recently seen first.
+func (repo *SQLSessionRepo) ByUser(ctx context.Context, userID int64) ([]models.Session, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT "+sessionColumns+" FROM user_session WHERE user_id = ? AND expires_at > ? ORDER BY last_seen_at DESC",
+		userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Delete removes a session; deleting a missing one is not an error.
+func (repo *SQLSessionRepo) Delete(ctx context.Context, id string) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM user_session WHERE id = ?", id)
+	return err
+}
+
+// DeleteForUser removes one session belonging to userID.
+func (repo *SQLSessionRepo) DeleteForUser(ctx context.Context, userID int64, id string) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM user_session WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteAllForUser removes every session of userID and returns how many there were.
+func (repo *SQLSessionRepo) DeleteAllForUser(ctx context.Context, userID int64) (int64, error) {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM user_session WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpired removes sessions that expired before now.
+func (repo *SQLSessionRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM user_session WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
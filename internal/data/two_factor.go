@@ -0,0 +1,190 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// TwoFactorRepository stores TOTP enrollments and their recovery codes.
+type TwoFactorRepository interface {
+	// ByUser returns the user's enrollment, confirmed or not; ErrNotFound if there is none.
+	ByUser(ctx context.Context, userID int64) (*models.TwoFactor, error)
+	// Begin starts an unconfirmed enrollment, replacing an earlier unconfirmed one.
+	// It returns ErrTwoFactorEnabled if the user already has 2FA on.
+	Begin(ctx context.Context, userID int64, secret string) error
+	// Enable confirms the enrollment, records step as used and stores the recovery code hashes.
+	// It returns ErrNotFound if there is no unconfirmed enrollment.
+	Enable(ctx context.Context, userID, step int64, codeHashes []string) error
+	// UseStep records step as the last accepted one; ErrNotFound if it isn't newer,
+	// which means the code was already used.
+	UseStep(ctx context.Context, userID, step int64) error
+	// UseRecoveryCode marks an unused recovery code used; ErrNotFound if none matches.
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores new ones.
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	// RecoveryCodesLeft counts the user's unused recovery codes.
+	RecoveryCodesLeft(ctx context.Context, userID int64) (int, error)
+	// Delete removes the enrollment and recovery codes, turning 2FA off.
+	Delete(ctx context.Context, userID int64) error
+}
+
+// SQLTwoFactorRepo implements TwoFactorRepository using the user_totp and user_recovery_code tables.
+type SQLTwoFactorRepo struct {
+	DB *sql.DB
+}
+
+// NewSQLTwoFactorRepo creates a new SQLTwoFactorRepo with a given DB connection.
+func NewSQLTwoFactorRepo(db *sql.DB) *SQLTwoFactorRepo {
+	return &SQLTwoFactorRepo{DB: db}
+}
+
+// ByUser returns a user's enrollment.
+func (repo *SQLTwoFactorRepo) ByUser(ctx context.Context, userID int64) (*models.TwoFactor, error) {
+	var t models.TwoFactor
+	var enabledAt sql.NullTime
+	err := repo.DB.QueryRowContext(ctx, "SELECT user_id, secret, created_at, enabled_at, last_step FROM user_totp WHERE user_id = ?", userID).
+		Scan(&t.UserID, &t.Secret, &t.CreatedAt, &enabledAt, &t.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		t.EnabledAt = &enabledAt.Time
+	}
+	return &t, nil
+}
+
+// Begin replaces any unconfirmed enrollment with a new one.
+func (repo *SQLTwoFactorRepo) Begin(ctx context.Context, userID int64, secret string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id = ? AND enabled_at IS NULL", userID); err != nil {
+		return err
+	}
+	var enabled int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_totp WHERE user_id = ?", userID).Scan(&enabled); err != nil {
+		return err
+	}
+	if enabled > 0 {
+		return ErrTwoFactorEnabled
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO user_totp (user_id, secret, created_at) VALUES (?, ?, ?)",
+		userID, secret, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Enable confirms an unconfirmed enrollment and stores its recovery codes in one transaction.
+func (repo *SQLTwoFactorRepo) Enable(ctx context.Context, userID, step int64, codeHashes []string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE user_totp SET enabled_at = ?, last_step = ? WHERE user_id = ? AND enabled_at IS NULL",
+		time.Now(), step, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseStep advances last_step with a compare-and-set UPDATE, so each code works once.
+func (repo *SQLTwoFactorRepo) UseStep(ctx context.Context, userID, step int64) error {
+	res, err := repo.DB.ExecContext(ctx, "UPDATE user_totp SET last_step = ? WHERE user_id = ? AND enabled_at IS NOT NULL AND last_step < ?",
+		step, userID, step)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UseRecoveryCode marks a matching unused code used.
+func (repo *SQLTwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	res, err := repo.DB.ExecContext(ctx, "UPDATE user_recovery_code SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now(), userID, codeHash)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes in one transaction.
+func (repo *SQLTwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_code WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO user_recovery_code (user_id, code_hash) VALUES (?, ?)", userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecoveryCodesLeft counts unused recovery codes.
+func (repo *SQLTwoFactorRepo) RecoveryCodesLeft(ctx context.Context, userID int64) (int, error) {
+	var n int
+	err := repo.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_recovery_code WHERE user_id = ? AND used_at IS NULL", userID).Scan(&n)
+	return n, err
+}
+
+// Delete removes the enrollment and its recovery codes.
+func (repo *SQLTwoFactorRepo) Delete(ctx context.Context, userID int64) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_code WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
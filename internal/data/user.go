@@ -1,16 +1,59 @@
 package data
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
-
-	"golang.org/x/crypto/bcrypt"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/passhash"
 )
 
-// GetAllUsers fetches all users from the database.
-func GetAllUsers() ([]models.User, error) {
-	rows, err := db.Query(`SELECT id, username, password, created_at FROM users`)
+// UserRepository provides access to application users.
+type UserRepository interface {
+	All(ctx context.Context) ([]models.User, error)
+	ByID(ctx context.Context, id int) (*models.User, error)
+	Create(ctx context.Context, username, password string) (int64, error)
+	Update(ctx context.Context, id int, username, password string) error
+	Delete(ctx context.Context, id int) error
+	// SetRole changes a user's role; ErrNotFound if the user doesn't exist.
+	SetRole(ctx context.Context, id int, role string) error
+	// Register creates a customer with an unverified email; ErrUserExists if username or email is taken.
+	Register(ctx context.Context, username, email, password string) (int64, error)
+	// ByEmail finds a user by (lower-cased) email; ErrNotFound if none.
+	ByEmail(ctx context.Context, email string) (*models.User, error)
+	MarkEmailVerified(ctx context.Context, id int) error
+}
+
+const userColumns = "id, username, password, role, created_at, email, email_verified_at"
+
+// scanUser scans one row selected with userColumns.
+func scanUser(row interface{ Scan(...any) error }) (models.User, error) {
+	var u models.User
+	var email sql.NullString
+	var verified sql.NullTime
+	err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Role, &u.CreatedAt, &email, &verified)
+	u.Email = email.String
+	if verified.Valid {
+		u.EmailVerifiedAt = &verified.Time
+	}
+	return u, err
+}
+
+// SQLUserRepo implements UserRepository using a SQL database.
+type SQLUserRepo struct {
+	DB *sql.DB
+}
+
+// NewSQLUserRepo creates a new SQLUserRepo with a given DB connection.
+func NewSQLUserRepo(db *sql.DB) *SQLUserRepo {
+	return &SQLUserRepo{DB: db}
+}
+
+// All fetches all users from the database.
+func (repo *SQLUserRepo) All(ctx context.Context) ([]models.User, error) {
+	rows, err := repo.DB.QueryContext(ctx, `SELECT `+userColumns+` FROM users`)
 	if err != nil {
 		return nil, err
 	}
@@ -18,8 +61,8 @@ func GetAllUsers() ([]models.User, error) {
 
 	var users []models.User
 	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Password, &u.CreatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -28,51 +71,54 @@ func GetAllUsers() ([]models.User, error) {
 
 }
 
-// GetUserByID fetches a user by their ID.
-func GetUserByID(id int) (*models.User, error) {
-	var u models.User
-	err := db.QueryRow(`SELECT id, username, password, created_at FROM users WHERE id = ?`, id).
-		Scan(&u.ID, &u.Username, &u.Password, &u.CreatedAt)
+// ByID fetches a user by their ID.
+func (repo *SQLUserRepo) ByID(ctx context.Context, id int) (*models.User, error) {
+	u, err := scanUser(repo.DB.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	return &u, nil
 }
 
-// CreateUser inserts a new user with hashed password and returns the new user ID.
-func CreateUser(username, password string) (int64, error) {
+// Create inserts a new user with hashed password and returns the new user ID.
+func (repo *SQLUserRepo) Create(ctx context.Context, username, password string) (int64, error) {
 	hashed, err := HashPassword(password)
 	if err != nil {
 		return 0, err
 	}
-	result, err := db.Exec(`INSERT INTO users (username, password, created_at) VALUES (?, ?, ?)`,
-		username, hashed, time.Now())
+	result, err := repo.DB.ExecContext(ctx, `INSERT INTO users (username, password, role, created_at) VALUES (?, ?, ?, ?)`,
+		username, hashed, models.RoleCustomer, time.Now())
 	if err != nil {
 		return 0, err
 	}
 	return result.LastInsertId()
 }
 
-// HashPassword generates a bcrypt hash of the password.
-// Recommended cost for most apps: 10–14 (higher = more secure but slower).
+// PasswordPolicy is the hashing policy of HashPassword. main sets it from the
+// environment at startup; VerifyUser upgrades hashes weaker than it.
+var PasswordPolicy = passhash.DefaultPolicy
+
+// HashPassword hashes the password under PasswordPolicy.
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+	return PasswordPolicy.Hash(password)
 }
 
-// UpdateUserByID updates username and/or password for a given user ID.
-func UpdateUserByID(id int, username, password string) error {
+// Update updates username and/or password for a given user ID.
+func (repo *SQLUserRepo) Update(ctx context.Context, id int, username, password string) error {
 	if username != "" && password != "" {
 		hashed, err := HashPassword(password)
 		if err != nil {
 			return err
 		}
-		_, err = db.Exec(`UPDATE users SET username = ?, password = ? WHERE id = ?`, username, hashed, id)
+		_, err = repo.DB.ExecContext(ctx, `UPDATE users SET username = ?, password = ? WHERE id = ?`, username, hashed, id)
 		return err
 	}
 
 	if username != "" {
-		_, err := db.Exec(`UPDATE users SET username = ? WHERE id = ?`, username, id)
+		_, err := repo.DB.ExecContext(ctx, `UPDATE users SET username = ? WHERE id = ?`, username, id)
 		return err
 	}
 
@@ -81,15 +127,70 @@ func UpdateUserByID(id int, username, password string) error {
 		if err != nil {
 			return err
 		}
-		_, err = db.Exec(`UPDATE users SET password = ? WHERE id = ?`, hashed, id)
+		_, err = repo.DB.ExecContext(ctx, `UPDATE users SET password = ? WHERE id = ?`, hashed, id)
 		return err
 	}
 
 	return nil
 }
 
-// DeleteUserByID removes a user from the database by ID.
-func DeleteUserByID(id int) error {
-	_, err := db.Exec(`DELETE FROM users WHERE id = ?`, id)
+// Delete removes a user from the database by ID.
+func (repo *SQLUserRepo) Delete(ctx context.Context, id int) error {
+	_, err := repo.DB.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	return err
+}
+
+// SetRole changes a user's role.
+func (repo *SQLUserRepo) SetRole(ctx context.Context, id int, role string) error {
+	res, err := repo.DB.ExecContext(ctx, `UPDATE users SET role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		return err
+	}
+	// MySQL reports 0 affected rows when the role is unchanged, so check existence separately.
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	_, err = repo.ByID(ctx, id)
+	return err
+}
+
+// Register inserts a customer with an email address awaiting verification.
+func (repo *SQLUserRepo) Register(ctx context.Context, username, email, password string) (int64, error) {
+	// The unique indexes are the real guard; this lookup just gives a clean error in the common case.
+	var n int
+	if err := repo.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE username = ? OR email = ?`, username, email).Scan(&n); err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		return 0, ErrUserExists
+	}
+
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	result, err := repo.DB.ExecContext(ctx, `INSERT INTO users (username, password, role, created_at, email) VALUES (?, ?, ?, ?, ?)`,
+		username, hashed, models.RoleCustomer, time.Now(), email)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ByEmail fetches a user by email address.
+func (repo *SQLUserRepo) ByEmail(ctx context.Context, email string) (*models.User, error) {
+	u, err := scanUser(repo.DB.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// MarkEmailVerified records that the user proved control of their email address.
+func (repo *SQLUserRepo) MarkEmailVerified(ctx context.Context, id int) error {
+	_, err := repo.DB.ExecContext(ctx, `UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL`, time.Now(), id)
 	return err
 }
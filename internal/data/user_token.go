@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Purposes of emailed single-use tokens.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// UserTokenRepository stores single-use tokens sent by email. Only a hash of
+// each token is kept; the raw token exists only in the link.
+type UserTokenRepository interface {
+	// Issue creates a token for userID valid for ttl and returns it.
+	Issue(ctx context.Context, userID int64, purpose string, ttl time.Duration) (string, error)
+	// Consume marks the token used and returns its user; ErrNotFound if it is
+	// unknown, expired, already used or issued for another purpose.
+	Consume(ctx context.Context, token, purpose string) (int64, error)
+	// Lookup returns the user of a token Consume would accept, without using it up.
+	Lookup(ctx context.Context, token, purpose string) (int64, error)
+	// ResetPassword consumes a TokenResetPassword token and sets its user's password
+	// in one transaction, returning the user; ErrNotFound like Consume. When it fails
+	// the token stays usable.
+	ResetPassword(ctx context.Context, token, password string) (int64, error)
+	// Invalidate marks every unused token of userID for purpose as used.
+	Invalidate(ctx context.Context, userID int64, purpose string) error
+}
+
+// SQLUserTokenRepo implements UserTokenRepository using the user_token table.
+type SQLUserTokenRepo struct {
+	DB *sql.DB
+}
+
+// NewSQLUserTokenRepo creates a new SQLUserTokenRepo with a given DB connection.
+func NewSQLUserTokenRepo(db *sql.DB) *SQLUserTokenRepo {
+	return &SQLUserTokenRepo{DB: db}
+}
+
+// newUserToken returns a random URL-safe token and its storage ID.
+func newUserToken() (token, id string) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, userTokenID(token)
+}
+
+// userTokenID is the stored ID of token.
+func userTokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue inserts a new token.
+func (repo *SQLUserTokenRepo) Issue(ctx context.Context, userID int64, purpose string, ttl time.Duration) (string, error) {
+	token, id := newUserToken()
+	now := time.Now()
+	_, err := repo.DB.ExecContext(ctx, "INSERT INTO user_token (id, user_id, purpose, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		id, userID, purpose, now, now.Add(ttl))
+	return token, err
+}
+
+// Consume marks a token used with a compare-and-set UPDATE, so it works only once.
+func (repo *SQLUserTokenRepo) Consume(ctx context.Context, token, purpose string) (int64, error) {
+	id := userTokenID(token)
+	now := time.Now()
+	res, err := repo.DB.ExecContext(ctx, "UPDATE user_token SET used_at = ? WHERE id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+		now, id, purpose, now)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrNotFound
+	}
+
+	var userID int64
+	err = repo.DB.QueryRowContext(ctx, "SELECT user_id FROM user_token WHERE id = ?", id).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return userID, err
+}
+
+// Lookup reads the owner of a usable token.
+func (repo *SQLUserTokenRepo) Lookup(ctx context.Context, token, purpose string) (int64, error) {
+	var userID int64
+	err := repo.DB.QueryRowContext(ctx, "SELECT user_id FROM user_token WHERE id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+		userTokenID(token), purpose, time.Now()).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return userID, err
+}
+
+// ResetPassword marks the token used with the same compare-and-set as Consume and
+// updates the password in the same transaction.
+func (repo *SQLUserTokenRepo) ResetPassword(ctx context.Context, token, password string) (int64, error) {
+	hashed, err := HashPassword(password) // before the transaction: bcrypt/argon2 are slow
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id := userTokenID(token)
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, "UPDATE user_token SET used_at = ? WHERE id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+		now, id, TokenResetPassword, now)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrNotFound
+	}
+
+	var userID int64
+	if err := tx.QueryRowContext(ctx, "SELECT user_id FROM user_token WHERE id = ?", id).Scan(&userID); err != nil {
+		return 0, err
+	}
+	res, err = tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", hashed, userID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrNotFound
+	}
+	return userID, tx.Commit()
+}
+
+// Invalidate marks a user's outstanding tokens for purpose as used.
+func (repo *SQLUserTokenRepo) Invalidate(ctx context.Context, userID int64, purpose string) error {
+	_, err := repo.DB.ExecContext(ctx, "UPDATE user_token SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		time.Now(), userID, purpose)
+	return err
+}
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConsumeUserTokenWithMock(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+	repo := NewSQLUserTokenRepo(db)
+	id := userTokenID("raw-token")
+
+	// First use: the compare-and-set UPDATE matches, then the owner is read back.
+	mock.ExpectExec("UPDATE user_token SET used_at = \\? WHERE id = \\? AND purpose = \\? AND used_at IS NULL AND expires_at > \\?").
+		WithArgs(sqlmock.AnyArg(), id, TokenResetPassword, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT user_id FROM user_token WHERE id = \\?").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	// Second use: nothing left to update.
+	mock.ExpectExec("UPDATE user_token SET used_at").
+		WithArgs(sqlmock.AnyArg(), id, TokenResetPassword, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	userID, err := repo.Consume(context.Background(), "raw-token", TokenResetPassword)
+	if err != nil || userID != 7 {
+		t.Fatalf("first Consume = %d, %v; want 7, nil", userID, err)
+	}
+	if _, err := repo.Consume(context.Background(), "raw-token", TokenResetPassword); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Consume error = %v, want ErrNotFound", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestLookupUserTokenWithMock(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+	repo := NewSQLUserTokenRepo(db)
+	id := userTokenID("raw-token")
+
+	// Lookup applies Consume's conditions but only reads.
+	mock.ExpectQuery("SELECT user_id FROM user_token WHERE id = \\? AND purpose = \\? AND used_at IS NULL AND expires_at > \\?").
+		WithArgs(id, TokenResetPassword, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	mock.ExpectQuery("SELECT user_id FROM user_token").
+		WithArgs(id, TokenVerifyEmail, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	if userID, err := repo.Lookup(context.Background(), "raw-token", TokenResetPassword); err != nil || userID != 7 {
+		t.Fatalf("Lookup = %d, %v; want 7, nil", userID, err)
+	}
+	if _, err := repo.Lookup(context.Background(), "raw-token", TokenVerifyEmail); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup for another purpose: error = %v, want ErrNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMemoryUserTokenPurposeAndInvalidate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserTokenRepo(NewMemoryUserRepo())
+
+	verify, _ := repo.Issue(ctx, 1, TokenVerifyEmail, time.Hour)
+	if _, err := repo.Consume(ctx, verify, TokenResetPassword); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("token used for another purpose: error = %v, want ErrNotFound", err)
+	}
+
+	expired, _ := repo.Issue(ctx, 1, TokenResetPassword, -time.Minute)
+	if _, err := repo.Consume(ctx, expired, TokenResetPassword); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired token: error = %v, want ErrNotFound", err)
+	}
+
+	reset, _ := repo.Issue(ctx, 1, TokenResetPassword, time.Hour)
+	for i := 0; i < 2; i++ {
+		if id, err := repo.Lookup(ctx, reset, TokenResetPassword); err != nil || id != 1 {
+			t.Fatalf("Lookup #%d = %d, %v; want 1, nil (Lookup must not use the token up)", i+1, id, err)
+		}
+	}
+	if _, err := repo.Lookup(ctx, expired, TokenResetPassword); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup of an expired token: error = %v, want ErrNotFound", err)
+	}
+	_ = repo.Invalidate(ctx, 1, TokenResetPassword)
+	if _, err := repo.Consume(ctx, reset, TokenResetPassword); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("invalidated token: error = %v, want ErrNotFound", err)
+	}
+	if id, err := repo.Consume(ctx, verify, TokenVerifyEmail); err != nil || id != 1 {
+		t.Fatalf("Invalidate should leave other purposes alone: got %d, %v", id, err)
+	}
+}
+
+func TestResetPasswordConsumesTokenWithTheChange(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryUserRepo()
+	db := openSQLiteDB(t)
+	for name, repos := range map[string]struct {
+		users  UserRepository
+		tokens UserTokenRepository
+	}{
+		"memory": {users, NewMemoryUserTokenRepo(users)},
+		"sql":    {NewSQLUserRepo(db), NewSQLUserTokenRepo(db)},
+	} {
+		userID, err := repos.users.Create(ctx, "alice", "old password")
+		if err != nil {
+			t.Fatalf("%s: create user: %v", name, err)
+		}
+		token, _ := repos.tokens.Issue(ctx, userID, TokenResetPassword, time.Hour)
+
+		if id, err := repos.tokens.ResetPassword(ctx, token, "new password"); err != nil || id != userID {
+			t.Fatalf("%s: ResetPassword = %d, %v; want %d, nil", name, id, err, userID)
+		}
+		if u, _ := repos.users.ByID(ctx, int(userID)); !CheckPasswordHash("new password", u.Password) {
+			t.Errorf("%s: password not changed", name)
+		}
+		if _, err := repos.tokens.ResetPassword(ctx, token, "third password"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: reusing the token: error = %v, want ErrNotFound", name, err)
+		}
+		if u, _ := repos.users.ByID(ctx, int(userID)); !CheckPasswordHash("new password", u.Password) {
+			t.Errorf("%s: a used token changed the password", name)
+		}
+	}
+
+	// When the password can't be set, the token is left for another try.
+	tokens := NewMemoryUserTokenRepo(users)
+	orphan, _ := tokens.Issue(ctx, 99, TokenResetPassword, time.Hour)
+	if _, err := tokens.ResetPassword(ctx, orphan, "new password"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("reset for a missing user: error = %v, want ErrNotFound", err)
+	}
+	if id, err := tokens.Lookup(ctx, orphan, TokenResetPassword); err != nil || id != 99 {
+		t.Errorf("failed reset used up the token: Lookup = %d, %v", id, err)
+	}
+}
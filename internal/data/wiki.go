@@ -0,0 +1,508 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// MaxTitleLen caps wiki page titles, in characters.
+const MaxTitleLen = 100
+
+// AnyRevision as the base of PageStore.Save saves whatever the current revision is.
+const AnyRevision = -1
+
+// NormalizeTitle returns the canonical form of a wiki page title: NFC-normalised,
+// trimmed, with runs of whitespace collapsed to one space. It returns ErrInvalidTitle
+// for an empty or over-long title, one starting with '.', or one containing control
+// characters, path separators or any of :*?"<>|#%[]{}, so a valid title is always
+// a plain file name.
+func NormalizeTitle(title string) (string, error) {
+	if !utf8.ValidString(title) {
+		return "", ErrInvalidTitle
+	}
+	title = strings.Join(strings.Fields(norm.NFC.String(title)), " ")
+	if title == "" || utf8.RuneCountInString(title) > MaxTitleLen || strings.HasPrefix(title, ".") {
+		return "", ErrInvalidTitle
+	}
+	for _, r := range title {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|#%[]{}`, r) {
+			return "", ErrInvalidTitle
+		}
+	}
+	return title, nil
+}
+
+// PageStore stores wiki pages and their revisions. Every method normalises the
+// title with NormalizeTitle and returns ErrInvalidTitle if it is rejected.
+type PageStore interface {
+	// Latest returns the current revision of a page; ErrNotFound if the page doesn't exist.
+	Latest(ctx context.Context, title string) (models.WikiRevision, error)
+	// History returns every revision of a page, oldest first; ErrNotFound if the page doesn't exist.
+	History(ctx context.Context, title string) ([]models.WikiRevision, error)
+	// Exists reports whether a page has been saved.
+	Exists(ctx context.Context, title string) (bool, error)
+	// Save appends rev as the page's next revision, numbering it and setting Time
+	// if it is zero, and returns it. base is the revision the edit started from
+	// (0 for a new page); if the page has moved on since, Save returns
+	// ErrVersionConflict. Saving the current body again records nothing and
+	// returns the current revision.
+	Save(ctx context.Context, title string, base int, rev models.WikiRevision) (models.WikiRevision, error)
+	// Settings returns who may edit a page; ErrNotFound if the page doesn't exist.
+	Settings(ctx context.Context, title string) (models.WikiPageSettings, error)
+	// SetSettings changes who may edit a page; ErrNotFound if the page doesn't exist.
+	SetSettings(ctx context.Context, title string, settings models.WikiPageSettings) error
+	// Titles lists the saved pages in order.
+	Titles(ctx context.Context) ([]string, error)
+}
+
+// ImportPages copies every page of src that dst doesn't have yet into dst, with its
+// history and settings, and returns the imported titles. Pages already in dst are left alone,
+// so an interrupted import can be rerun.
+func ImportPages(ctx context.Context, dst, src PageStore) ([]string, error) {
+	titles, err := src.Titles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var imported []string
+	for _, title := range titles {
+		if ok, err := dst.Exists(ctx, title); err != nil {
+			return imported, err
+		} else if ok {
+			continue
+		}
+		revs, err := src.History(ctx, title)
+		if err != nil {
+			return imported, fmt.Errorf("reading %s: %w", title, err)
+		}
+		for _, r := range revs {
+			if _, err := dst.Save(ctx, title, AnyRevision, r); err != nil {
+				return imported, fmt.Errorf("importing %s: %w", title, err)
+			}
+		}
+		if settings, err := src.Settings(ctx, title); err != nil {
+			return imported, fmt.Errorf("reading settings of %s: %w", title, err)
+		} else if err := dst.SetSettings(ctx, title, settings); err != nil {
+			return imported, fmt.Errorf("importing settings of %s: %w", title, err)
+		}
+		imported = append(imported, title)
+	}
+	return imported, nil
+}
+
+// FSPageStore implements PageStore with files in Dir: <Title>.txt holds the
+// current text, <Title>.history the revisions as JSON lines and the optional
+// <Title>.meta the page's settings. A page written before revisions were kept
+// (a .txt alone) has its text as revision 1.
+type FSPageStore struct {
+	Dir string
+
+	mu sync.Mutex // serializes saves so revision numbers stay sequential, and settings changes
+}
+
+// NewFSPageStore creates a new FSPageStore for the pages in dir.
+func NewFSPageStore(dir string) *FSPageStore {
+	return &FSPageStore{Dir: dir}
+}
+
+func (s *FSPageStore) path(title, ext string) string {
+	return filepath.Join(s.Dir, title+ext)
+}
+
+// Latest returns the last revision in the page's history.
+func (s *FSPageStore) Latest(ctx context.Context, title string) (models.WikiRevision, error) {
+	revs, err := s.History(ctx, title)
+	if err != nil {
+		return models.WikiRevision{}, err
+	}
+	return revs[len(revs)-1], nil
+}
+
+// History reads the page's history file.
+func (s *FSPageStore) History(ctx context.Context, title string) ([]models.WikiRevision, error) {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return nil, err
+	}
+	revs, err := s.history(title)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return nil, ErrNotFound
+	}
+	return revs, nil
+}
+
+// history returns the revisions of a normalised title, or none for a missing page.
+func (s *FSPageStore) history(title string) ([]models.WikiRevision, error) {
+	f, err := os.Open(s.path(title, ".history"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s.legacyRevision(title)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var revs []models.WikiRevision
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var r models.WikiRevision
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("reading history of %s: %w", title, err)
+		}
+		revs = append(revs, r)
+	}
+	return revs, nil
+}
+
+// legacyRevision returns a page's text file as revision 1, or none if there is no file.
+func (s *FSPageStore) legacyRevision(title string) ([]models.WikiRevision, error) {
+	path := s.path(title, ".txt")
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return []models.WikiRevision{{ID: 1, Time: info.ModTime(), Summary: "Imported", Body: string(body)}}, nil
+}
+
+// Exists checks for the page's text or history file.
+func (s *FSPageStore) Exists(ctx context.Context, title string) (bool, error) {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return false, err
+	}
+	for _, ext := range []string{".txt", ".history"} {
+		if _, err := os.Stat(s.path(title, ext)); err == nil {
+			return true, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// Save appends the revision to the history file, then rewrites the text file.
+func (s *FSPageStore) Save(ctx context.Context, title string, base int, rev models.WikiRevision) (models.WikiRevision, error) {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return models.WikiRevision{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return models.WikiRevision{}, err
+	}
+	history, err := s.history(title)
+	if err != nil {
+		return models.WikiRevision{}, err
+	}
+	if n := len(history); n > 0 && history[n-1].Body == rev.Body {
+		return history[n-1], nil
+	}
+	if base != AnyRevision && base != len(history) {
+		return models.WikiRevision{}, ErrVersionConflict
+	}
+
+	var revs []models.WikiRevision
+	if _, err := os.Stat(s.path(title, ".history")); errors.Is(err, fs.ErrNotExist) {
+		revs = history // keep the text from before revisions were recorded
+	}
+	rev.ID = len(history) + 1
+	if rev.Time.IsZero() {
+		rev.Time = time.Now()
+	}
+	if err := s.appendRevisions(title, append(revs, rev)...); err != nil {
+		return models.WikiRevision{}, err
+	}
+	if err := os.WriteFile(s.path(title, ".txt"), []byte(rev.Body), 0600); err != nil {
+		return models.WikiRevision{}, err
+	}
+	return rev, nil
+}
+
+// Settings reads the page's .meta file; a page without one has the default settings.
+func (s *FSPageStore) Settings(ctx context.Context, title string) (models.WikiPageSettings, error) {
+	var settings models.WikiPageSettings
+	if ok, err := s.Exists(ctx, title); err != nil {
+		return settings, err
+	} else if !ok {
+		return settings, ErrNotFound
+	}
+	title, _ = NormalizeTitle(title)
+	b, err := os.ReadFile(s.path(title, ".meta"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(b, &settings); err != nil {
+		return settings, fmt.Errorf("reading settings of %s: %w", title, err)
+	}
+	return settings, nil
+}
+
+// SetSettings writes the page's .meta file.
+func (s *FSPageStore) SetSettings(ctx context.Context, title string, settings models.WikiPageSettings) error {
+	if ok, err := s.Exists(ctx, title); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotFound
+	}
+	title, _ = NormalizeTitle(title)
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.WriteFile(s.path(title, ".meta"), b, 0600)
+}
+
+// appendRevisions adds revisions to the end of a page's history file.
+func (s *FSPageStore) appendRevisions(title string, revs ...models.WikiRevision) error {
+	f, err := os.OpenFile(s.path(title, ".history"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range revs {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Titles lists the pages with a text or history file in Dir. Files whose names
+// aren't valid, normalised titles are skipped.
+func (s *FSPageStore) Titles(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var titles []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".txt")
+		if !ok {
+			name, ok = strings.CutSuffix(e.Name(), ".history")
+		}
+		if !ok || e.IsDir() {
+			continue
+		}
+		if t, err := NormalizeTitle(name); err == nil && t == name {
+			titles = append(titles, name)
+		}
+	}
+	slices.Sort(titles)
+	return slices.Compact(titles), nil
+}
+
+// SQLPageStore implements PageStore using the wiki_page and wiki_revision tables.
+// wiki_page.revision names the current revision.
+type SQLPageStore struct {
+	DB *sql.DB
+}
+
+// NewSQLPageStore creates a new SQLPageStore with a given DB connection.
+func NewSQLPageStore(db *sql.DB) *SQLPageStore {
+	return &SQLPageStore{DB: db}
+}
+
+// Latest returns the revision wiki_page points at.
+func (s *SQLPageStore) Latest(ctx context.Context, title string) (models.WikiRevision, error) {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return models.WikiRevision{}, err
+	}
+	return latestRevision(ctx, s.DB, title)
+}
+
+// latestRevision reads the current revision with q, which may be a transaction.
+func latestRevision(ctx context.Context, q rowQuerier, title string) (models.WikiRevision, error) {
+	var r models.WikiRevision
+	err := q.QueryRowContext(ctx, `
+		SELECT r.id, r.created_at, r.author, r.summary, r.body
+		FROM wiki_page p
+		JOIN wiki_revision r ON r.title = p.title AND r.id = p.revision
+		WHERE p.title = ?`, title).
+		Scan(&r.ID, &r.Time, &r.Author, &r.Summary, &r.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+// History returns the page's revisions by ID.
+func (s *SQLPageStore) History(ctx context.Context, title string) ([]models.WikiRevision, error) {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, created_at, author, summary, body FROM wiki_revision WHERE title = ? ORDER BY id", title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []models.WikiRevision
+	for rows.Next() {
+		var r models.WikiRevision
+		if err := rows.Scan(&r.ID, &r.Time, &r.Author, &r.Summary, &r.Body); err != nil {
+			return nil, err
+		}
+		revs = append(revs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return nil, ErrNotFound
+	}
+	return revs, nil
+}
+
+// Exists looks the page up in wiki_page.
+func (s *SQLPageStore) Exists(ctx context.Context, title string) (bool, error) {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return false, err
+	}
+	var n int
+	err = s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM wiki_page WHERE title = ?", title).Scan(&n)
+	return n > 0, err
+}
+
+// Save inserts the revision and moves wiki_page to it in one transaction. Moving
+// wiki_page is a compare-and-set UPDATE, so of two saves from the same base only
+// the first succeeds.
+func (s *SQLPageStore) Save(ctx context.Context, title string, base int, rev models.WikiRevision) (models.WikiRevision, error) {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return models.WikiRevision{}, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.WikiRevision{}, err
+	}
+	defer tx.Rollback()
+
+	cur, err := latestRevision(ctx, tx, title)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return models.WikiRevision{}, err
+	}
+	if cur.Body == rev.Body && cur.ID > 0 {
+		return cur, nil
+	}
+	if base != AnyRevision && base != cur.ID {
+		return models.WikiRevision{}, ErrVersionConflict
+	}
+	if cur.ID == 0 {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO wiki_page (title, revision) VALUES (?, 1)", title); err != nil {
+			return models.WikiRevision{}, err
+		}
+	} else {
+		res, err := tx.ExecContext(ctx, "UPDATE wiki_page SET revision = ? WHERE title = ? AND revision = ?", cur.ID+1, title, cur.ID)
+		if err != nil {
+			return models.WikiRevision{}, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return models.WikiRevision{}, err
+		} else if n == 0 {
+			return models.WikiRevision{}, ErrVersionConflict
+		}
+	}
+
+	rev.ID = cur.ID + 1
+	if rev.Time.IsZero() {
+		rev.Time = time.Now()
+	}
+	rev.Time = rev.Time.UTC().Truncate(time.Second) // DATETIME has no zone or fraction
+	if _, err := tx.ExecContext(ctx, "INSERT INTO wiki_revision (title, id, created_at, author, summary, body) VALUES (?, ?, ?, ?, ?, ?)",
+		title, rev.ID, rev.Time, rev.Author, rev.Summary, rev.Body); err != nil {
+		return models.WikiRevision{}, err
+	}
+	return rev, tx.Commit()
+}
+
+// Settings reads the page's wiki_page row.
+func (s *SQLPageStore) Settings(ctx context.Context, title string) (models.WikiPageSettings, error) {
+	var settings models.WikiPageSettings
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return settings, err
+	}
+	err = s.DB.QueryRowContext(ctx, "SELECT protected, anonymous_edits FROM wiki_page WHERE title = ?", title).
+		Scan(&settings.Protected, &settings.AnonymousEdits)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, ErrNotFound
+	}
+	return settings, err
+}
+
+// SetSettings updates the page's wiki_page row.
+func (s *SQLPageStore) SetSettings(ctx context.Context, title string, settings models.WikiPageSettings) error {
+	title, err := NormalizeTitle(title)
+	if err != nil {
+		return err
+	}
+	res, err := s.DB.ExecContext(ctx, "UPDATE wiki_page SET protected = ?, anonymous_edits = ? WHERE title = ?",
+		settings.Protected, settings.AnonymousEdits, title)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Titles lists wiki_page by title.
+func (s *SQLPageStore) Titles(ctx context.Context) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT title FROM wiki_page ORDER BY title")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var titles []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		titles = append(titles, t)
+	}
+	return titles, rows.Err()
+}
@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/dialect"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/migrations"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	for in, want := range map[string]string{
+		"FrontPage":          "FrontPage",
+		"  Release   notes ": "Release notes",
+		"Cafe\u0301":         "Caf\u00e9", // NFC
+	} {
+		if got, err := NormalizeTitle(in); err != nil || got != want {
+			t.Errorf("NormalizeTitle(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "   ", "../secret", "..", ".hidden", "a/b", `a\b`, "a\x00b", "C:x", "[[x]]", strings.Repeat("a", MaxTitleLen+1)} {
+		if got, err := NormalizeTitle(in); !errors.Is(err, ErrInvalidTitle) {
+			t.Errorf("NormalizeTitle(%q) = %q, %v; want ErrInvalidTitle", in, got, err)
+		}
+	}
+}
+
+// testPageStore runs the PageStore contract against s.
+func testPageStore(t *testing.T, s PageStore) {
+	ctx := context.Background()
+
+	if _, err := s.Latest(ctx, "Home"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Latest(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Save(ctx, "../escape", AnyRevision, models.WikiRevision{Body: "x"}); !errors.Is(err, ErrInvalidTitle) {
+		t.Fatalf("Save(../escape) error = %v, want ErrInvalidTitle", err)
+	}
+
+	for _, body := range []string{"v1", "v2", "v2", "v3"} { // the unchanged save records nothing
+		if _, err := s.Save(ctx, " Home ", AnyRevision, models.WikiRevision{Author: "ann", Summary: "edit", Body: body}); err != nil {
+			t.Fatalf("Save(%q): %v", body, err)
+		}
+	}
+	revs, err := s.History(ctx, "Home")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(revs) != 3 || revs[0].Body != "v1" || revs[2].ID != 3 || revs[2].Author != "ann" || revs[2].Time.IsZero() {
+		t.Errorf("unexpected history %+v", revs)
+	}
+	if r, err := s.Latest(ctx, "Home"); err != nil || r.ID != 3 || r.Body != "v3" {
+		t.Errorf("Latest = %+v, %v; want revision 3", r, err)
+	}
+	if ok, err := s.Exists(ctx, "Home"); !ok || err != nil {
+		t.Errorf("Exists(Home) = %v, %v", ok, err)
+	}
+	if _, err := s.Save(ctx, "About", AnyRevision, models.WikiRevision{Body: "about"}); err != nil {
+		t.Fatalf("Save(About): %v", err)
+	}
+	if titles, err := s.Titles(ctx); err != nil || !slices.Equal(titles, []string{"About", "Home"}) {
+		t.Errorf("Titles = %q, %v", titles, err)
+	}
+
+	// Edits name the revision they started from.
+	if _, err := s.Save(ctx, "Home", 2, models.WikiRevision{Body: "stale"}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Save(base 2 of 3) error = %v, want ErrVersionConflict", err)
+	}
+	if _, err := s.Save(ctx, "New", 1, models.WikiRevision{Body: "x"}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Save(new page, base 1) error = %v, want ErrVersionConflict", err)
+	}
+	if r, err := s.Save(ctx, "Home", 3, models.WikiRevision{Body: "v4"}); err != nil || r.ID != 4 {
+		t.Errorf("Save(base 3) = %+v, %v; want revision 4", r, err)
+	}
+
+	if settings, err := s.Settings(ctx, "Home"); err != nil || settings != (models.WikiPageSettings{}) {
+		t.Errorf("Settings(Home) = %+v, %v; want the defaults", settings, err)
+	}
+	want := models.WikiPageSettings{Protected: true}
+	if err := s.SetSettings(ctx, "Home", want); err != nil {
+		t.Fatalf("SetSettings: %v", err)
+	}
+	if settings, err := s.Settings(ctx, "Home"); err != nil || settings != want {
+		t.Errorf("Settings(Home) = %+v, %v; want %+v", settings, err, want)
+	}
+	if err := s.SetSettings(ctx, "Nowhere", want); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetSettings(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFSPageStore(t *testing.T) {
+	testPageStore(t, NewFSPageStore(t.TempDir()))
+}
+
+func TestSQLPageStore(t *testing.T) {
+	testPageStore(t, NewSQLPageStore(openSQLiteDB(t)))
+}
+
+func TestFSPageStoreLegacyText(t *testing.T) {
+	dir := t.TempDir()
+	// A page written before revisions were kept.
+	if err := os.WriteFile(filepath.Join(dir, "Home.txt"), []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFSPageStore(dir)
+	ctx := context.Background()
+
+	if _, err := s.Save(ctx, "Home", AnyRevision, models.WikiRevision{Body: "v2"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	revs, err := s.History(ctx, "Home")
+	if err != nil || len(revs) != 2 || revs[0].Body != "v1" || revs[0].Summary != "Imported" || revs[1].ID != 2 {
+		t.Fatalf("History = %+v, %v; want the old text as revision 1", revs, err)
+	}
+	if body, _ := os.ReadFile(filepath.Join(dir, "Home.txt")); string(body) != "v2" {
+		t.Errorf("Home.txt = %q, want the latest revision", body)
+	}
+}
+
+func TestImportPages(t *testing.T) {
+	ctx := context.Background()
+	src := NewFSPageStore(t.TempDir())
+	for _, body := range []string{"one", "two"} {
+		if _, err := src.Save(ctx, "Home", AnyRevision, models.WikiRevision{Author: "ann", Body: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.SetSettings(ctx, "Home", models.WikiPageSettings{Protected: true}); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewSQLPageStore(openSQLiteDB(t))
+
+	for run, want := range [][]string{{"Home"}, nil} { // the second run has nothing to do
+		imported, err := ImportPages(ctx, dst, src)
+		if err != nil || !slices.Equal(imported, want) {
+			t.Fatalf("run %d: ImportPages = %q, %v; want %q", run+1, imported, err, want)
+		}
+	}
+	srcRevs, _ := src.History(ctx, "Home")
+	dstRevs, err := dst.History(ctx, "Home")
+	if err != nil || len(dstRevs) != 2 || dstRevs[1].Body != "two" || dstRevs[1].Author != "ann" ||
+		!dstRevs[0].Time.Equal(srcRevs[0].Time.Truncate(time.Second)) {
+		t.Errorf("imported history %+v, %v; want %+v", dstRevs, err, srcRevs)
+	}
+	if settings, err := dst.Settings(ctx, "Home"); err != nil || !settings.Protected {
+		t.Errorf("imported settings %+v, %v; want the page protected", settings, err)
+	}
+}
+
+// openSQLiteDB returns an in-memory SQLite database with every migration applied.
+func openSQLiteDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // every connection to :memory: is a separate database
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.NewEmbedded(db, dialect.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,45 @@
+package dialect
+
+import "fmt"
+
+// Dialect captures the SQL differences between the databases the app can run on.
+// Queries that are valid on every dialect (plain SELECT/INSERT/UPDATE with ? placeholders)
+// don't need it; schema differences live in per-dialect migration directories.
+type Dialect interface {
+	// Name is the DB_DRIVER value and the migrations subdirectory (e.g. "mysql").
+	Name() string
+	// DriverName is the database/sql driver to open.
+	DriverName() string
+	// SupportsMultiResultSets reports whether one query may return several result sets.
+	SupportsMultiResultSets() bool
+}
+
+type mysql struct{}
+
+func (mysql) Name() string                  { return "mysql" }
+func (mysql) DriverName() string            { return "mysql" }
+func (mysql) SupportsMultiResultSets() bool { return true }
+
+type sqlite struct{}
+
+func (sqlite) Name() string                  { return "sqlite" }
+func (sqlite) DriverName() string            { return "sqlite" }
+func (sqlite) SupportsMultiResultSets() bool { return false }
+
+var (
+	// MySQL is the production dialect (github.com/go-sql-driver/mysql).
+	MySQL Dialect = mysql{}
+	// SQLite runs the app against a local file (modernc.org/sqlite), no server needed.
+	SQLite Dialect = sqlite{}
+)
+
+// Lookup returns the dialect for a DB_DRIVER value; an empty name means MySQL.
+func Lookup(name string) (Dialect, error) {
+	switch name {
+	case "", "mysql":
+		return MySQL, nil
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	}
+	return nil, fmt.Errorf("unsupported DB_DRIVER %q (want mysql or sqlite)", name)
+}
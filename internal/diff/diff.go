@@ -0,0 +1,106 @@
+// Package diff computes line-by-line differences between two texts, as shown
+// by the wiki's revision history.
+package diff
+
+import "strings"
+
+// Kind says whether a line is in both texts or only in one of them.
+type Kind int
+
+const (
+	Equal  Kind = iota // in both
+	Delete             // only in the old text
+	Insert             // only in the new text
+)
+
+// Line is one line of a diff.
+type Line struct {
+	Kind Kind
+	Text string
+}
+
+// maxCells caps the LCS table (old lines × new lines). Bigger inputs are shown
+// as the old text deleted and the new one inserted rather than using ~64MB.
+const maxCells = 1 << 24
+
+// SplitLines splits s into lines, accepting \n and \r\n line endings.
+func SplitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Lines returns a shortest edit script turning a into b, built from a longest
+// common subsequence of lines. Deletions come before insertions within a change.
+func Lines(a, b []string) []Line {
+	// Common prefix and suffix don't need the table.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	out := make([]Line, 0, len(a)+len(b))
+	for _, s := range a[:pre] {
+		out = append(out, Line{Equal, s})
+	}
+	out = append(out, middle(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, s := range a[len(a)-suf:] {
+		out = append(out, Line{Equal, s})
+	}
+	return out
+}
+
+// middle diffs the part between the common prefix and suffix.
+func middle(a, b []string) []Line {
+	var out []Line
+	if len(a)*len(b) > maxCells {
+		for _, s := range a {
+			out = append(out, Line{Delete, s})
+		}
+		for _, s := range b {
+			out = append(out, Line{Insert, s})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	w := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else {
+				lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{Equal, a[i]})
+			i, j = i+1, j+1
+		case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+			out = append(out, Line{Delete, a[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, Line{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, Line{Insert, b[j]})
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// render shows a diff in unified-diff style: " " equal, "-" deleted, "+" inserted.
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString([]string{" ", "-", "+"}[l.Kind] + l.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	for _, tc := range []struct {
+		name, a, b, want string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", " a\n b\n"},
+		{"empty to text", "", "a\nb", "+a\n+b\n"},
+		{"text to empty", "a\nb", "", "-a\n-b\n"},
+		{"changed middle", "a\nb\nc\n", "a\nx\nc\n", " a\n-b\n+x\n c\n"},
+		{"insert and delete", "a\nb\nc\nd\n", "b\nc\ne\nd\n", "-a\n b\n c\n+e\n d\n"},
+		{"crlf endings", "a\r\nb\r\n", "a\nb\n", " a\n b\n"},
+	} {
+		got := render(Lines(SplitLines(tc.a), SplitLines(tc.b)))
+		if got != tc.want {
+			t.Errorf("%s:\ngot\n%swant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLinesIsMinimal(t *testing.T) {
+	a := SplitLines("one\ntwo\nthree\nfour\nfive\nsix\n")
+	b := SplitLines("zero\none\nthree\nfour\nFIVE\nsix\nseven\n")
+	changes := 0
+	for _, l := range Lines(a, b) {
+		if l.Kind != Equal {
+			changes++
+		}
+	}
+	// +zero, -two, -five, +FIVE, +seven
+	if changes != 5 {
+		t.Errorf("got %d changed lines, want 5:\n%s", changes, render(Lines(a, b)))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	const base = "a\nb\nc\nd\ne\n"
+	for _, tc := range []struct {
+		name, ours, theirs, want string
+		conflicts                int
+	}{
+		{"only ours", "a\nB\nc\nd\ne\n", base, "a\nB\nc\nd\ne\n", 0},
+		{"only theirs", base, "a\nb\nc\nd\nE\n", "a\nb\nc\nd\nE\n", 0},
+		{"separate lines", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", 0},
+		{"same change", "a\nX\nc\nd\ne\n", "a\nX\nc\nd\ne\n", "a\nX\nc\nd\ne\n", 0},
+		{"insert and delete", "a\nb\nnew\nc\nd\ne\n", "a\nb\nc\ne\n", "a\nb\nnew\nc\ne\n", 0},
+		{"same line", "a\nours\nc\nd\ne\n", "a\ntheirs\nc\nd\ne\n",
+			"a\n<<<<<<< mine\nours\n=======\ntheirs\n>>>>>>> r2\nc\nd\ne\n", 1},
+		{"adjacent lines", "a\nB\nc\nd\ne\n", "a\nb\nC\nd\ne\n",
+			"a\n<<<<<<< mine\nB\nc\n=======\nb\nC\n>>>>>>> r2\nd\ne\n", 1},
+	} {
+		merged, n := Merge(SplitLines(base), SplitLines(tc.ours), SplitLines(tc.theirs), "mine", "r2")
+		if got := strings.Join(merged, "\n") + "\n"; got != tc.want || n != tc.conflicts {
+			t.Errorf("%s: got %d conflict(s)\n%swant %d\n%s", tc.name, n, got, tc.conflicts, tc.want)
+		}
+	}
+}
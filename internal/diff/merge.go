@@ -0,0 +1,109 @@
+package diff
+
+import "slices"
+
+// Conflict markers written by Merge around changes both sides made to the same lines.
+const (
+	MarkerOurs   = "<<<<<<< "
+	MarkerSep    = "======="
+	MarkerTheirs = ">>>>>>> "
+)
+
+// hunk replaces base[start:end] with lines.
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+// hunks returns the changes turning base into other, in order.
+func hunks(base, other []string) []hunk {
+	var hs []hunk
+	pos := 0
+	var cur *hunk
+	for _, l := range Lines(base, other) {
+		switch l.Kind {
+		case Equal:
+			if cur != nil {
+				hs = append(hs, *cur)
+				cur = nil
+			}
+			pos++
+		case Delete:
+			if cur == nil {
+				cur = &hunk{start: pos, end: pos}
+			}
+			cur.end++
+			pos++
+		case Insert:
+			if cur == nil {
+				cur = &hunk{start: pos, end: pos}
+			}
+			cur.lines = append(cur.lines, l.Text)
+		}
+	}
+	if cur != nil {
+		hs = append(hs, *cur)
+	}
+	return hs
+}
+
+// apply returns base[start:end] with the hunks, which must lie within it, applied.
+func apply(base []string, start, end int, hs []hunk) []string {
+	var out []string
+	pos := start
+	for _, h := range hs {
+		out = append(out, base[pos:h.start]...)
+		out = append(out, h.lines...)
+		pos = h.end
+	}
+	return append(out, base[pos:end]...)
+}
+
+// Merge combines the changes ours and theirs each made to base, line by line. Where
+// both changed the same or adjacent lines differently, the merged text has both
+// versions between conflict markers labelled oursLabel and theirsLabel, and
+// conflicts counts such places.
+func Merge(base, ours, theirs []string, oursLabel, theirsLabel string) (merged []string, conflicts int) {
+	a, b := hunks(base, ours), hunks(base, theirs)
+	pos := 0
+	for len(a) > 0 || len(b) > 0 {
+		// Start a group with the earliest hunk, then take in every hunk that
+		// overlaps or touches it, from either side.
+		var ga, gb []hunk
+		var start, end int
+		if len(b) == 0 || len(a) > 0 && a[0].start <= b[0].start {
+			start, end, ga, a = a[0].start, a[0].end, a[:1], a[1:]
+		} else {
+			start, end, gb, b = b[0].start, b[0].end, b[:1], b[1:]
+		}
+		for {
+			if len(a) > 0 && a[0].start <= end {
+				end = max(end, a[0].end)
+				ga, a = append(ga, a[0]), a[1:]
+			} else if len(b) > 0 && b[0].start <= end {
+				end = max(end, b[0].end)
+				gb, b = append(gb, b[0]), b[1:]
+			} else {
+				break
+			}
+		}
+
+		merged = append(merged, base[pos:start]...)
+		oursText, theirsText := apply(base, start, end, ga), apply(base, start, end, gb)
+		switch {
+		case len(gb) == 0:
+			merged = append(merged, oursText...)
+		case len(ga) == 0, slices.Equal(oursText, theirsText):
+			merged = append(merged, theirsText...)
+		default:
+			merged = append(merged, MarkerOurs+oursLabel)
+			merged = append(merged, oursText...)
+			merged = append(merged, MarkerSep)
+			merged = append(merged, theirsText...)
+			merged = append(merged, MarkerTheirs+theirsLabel)
+			conflicts++
+		}
+		pos = end
+	}
+	return append(merged, base[pos:]...), conflicts
+}
@@ -0,0 +1,150 @@
+// Package events is an in-process publish/subscribe bus. The data layer
+// publishes changes (stock levels, new orders) after they are committed, and
+// subscribers such as /ws/events pick the topics they want. Publishing never
+// blocks: a subscriber whose buffer is full is dropped.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// Topics.
+const (
+	TopicStockChanged = "album.stock_changed" // Data is StockChanged
+	TopicOrderCreated = "order.created"       // Data is OrderCreated; private to the ordering customer
+)
+
+// Topics lists every topic, for validating subscriptions.
+var Topics = []string{TopicStockChanged, TopicOrderCreated}
+
+// Event is something that happened. Events with a Customer are private: only
+// subscriptions for that customer receive them.
+type Event struct {
+	Topic    string    `json:"topic"`
+	Time     time.Time `json:"time"`
+	Customer int64     `json:"-"`
+	Data     any       `json:"data"`
+}
+
+// StockChanged reports an album's new quantity.
+type StockChanged struct {
+	AlbumID  int64 `json:"album_id"`
+	Quantity int64 `json:"quantity"`
+}
+
+// OrderCreated reports a new order.
+type OrderCreated struct {
+	OrderID    int64              `json:"order_id"`
+	CustomerID int64              `json:"customer_id"`
+	Quantity   int64              `json:"quantity"` // units over all items
+	Items      []models.OrderItem `json:"items"`
+}
+
+// Buffer is the number of events queued per subscription before it is dropped.
+const Buffer = 64
+
+// Bus delivers published events to subscriptions. It is safe for concurrent use,
+// and a nil *Bus discards everything published to it.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]bool
+}
+
+// NewBus returns a bus without subscriptions.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]bool)}
+}
+
+// Subscription receives the events of its topics on C, which the bus closes
+// when the subscription is closed or dropped.
+type Subscription struct {
+	C <-chan Event
+
+	c        chan Event
+	bus      *Bus
+	customer int64
+	topics   map[string]bool
+	dropped  bool
+}
+
+// Subscribe returns a subscription for customer (0 for none, which receives only
+// public events) to topics; more can be added later.
+func (b *Bus) Subscribe(customer int64, topics ...string) *Subscription {
+	c := make(chan Event, Buffer)
+	s := &Subscription{C: c, c: c, bus: b, customer: customer, topics: make(map[string]bool)}
+	for _, t := range topics {
+		s.topics[t] = true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s] = true
+	return s
+}
+
+// Publish sends e to every subscription to its topic that may see it, setting
+// e.Time if it is zero.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !s.topics[e.Topic] || e.Customer != 0 && e.Customer != s.customer {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			s.dropped = true
+			b.remove(s)
+		}
+	}
+}
+
+// Add subscribes s to topic as well.
+func (s *Subscription) Add(topic string) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.topics[topic] = true
+}
+
+// Remove unsubscribes s from topic.
+func (s *Subscription) Remove(topic string) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	delete(s.topics, topic)
+}
+
+// SetCustomer changes whose private events s receives, for a customer that
+// didn't exist yet when s subscribed.
+func (s *Subscription) SetCustomer(customer int64) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.customer = customer
+}
+
+// Close ends the subscription and closes C. It may be called more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
+
+// Dropped reports whether the subscription was ended for falling behind. It is
+// only meaningful once C has been closed.
+func (s *Subscription) Dropped() bool { return s.dropped }
+
+// remove ends s if it is still subscribed. Callers must hold b.mu.
+func (b *Bus) remove(s *Subscription) {
+	if b.subs[s] {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
@@ -0,0 +1,80 @@
+package events
+
+import "testing"
+
+// received returns the events queued on s, and whether s is still open.
+func received(s *Subscription) ([]Event, bool) {
+	var got []Event
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return got, false
+			}
+			got = append(got, e)
+		default:
+			return got, true
+		}
+	}
+}
+
+func TestTopicsAndPrivacy(t *testing.T) {
+	b := NewBus()
+	stock := b.Subscribe(0, TopicStockChanged)
+	alice := b.Subscribe(1, TopicOrderCreated)
+	bob := b.Subscribe(2, TopicOrderCreated, TopicStockChanged)
+	nobody := b.Subscribe(0, TopicOrderCreated)
+
+	b.Publish(Event{Topic: TopicStockChanged, Data: StockChanged{AlbumID: 1, Quantity: 4}})
+	b.Publish(Event{Topic: TopicOrderCreated, Customer: 1, Data: OrderCreated{OrderID: 7, CustomerID: 1}})
+
+	if got, _ := received(stock); len(got) != 1 || got[0].Topic != TopicStockChanged || got[0].Time.IsZero() {
+		t.Errorf("stock subscriber got %+v, want the stock change with a time", got)
+	}
+	if got, _ := received(alice); len(got) != 1 || got[0].Data.(OrderCreated).OrderID != 7 {
+		t.Errorf("alice got %+v, want her order", got)
+	}
+	if got, _ := received(bob); len(got) != 1 || got[0].Topic != TopicStockChanged {
+		t.Errorf("bob got %+v, want only the stock change", got)
+	}
+	if got, _ := received(nobody); len(got) != 0 {
+		t.Errorf("subscription without a customer got %+v", got)
+	}
+
+	bob.Remove(TopicStockChanged)
+	nobody.SetCustomer(1)
+	nobody.Add(TopicStockChanged)
+	b.Publish(Event{Topic: TopicStockChanged})
+	b.Publish(Event{Topic: TopicOrderCreated, Customer: 1})
+	if got, _ := received(bob); len(got) != 0 {
+		t.Errorf("bob got %+v after unsubscribing", got)
+	}
+	if got, _ := received(nobody); len(got) != 2 {
+		t.Errorf("got %d events after SetCustomer and Add, want 2", len(got))
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := NewBus()
+	slow := b.Subscribe(0, TopicStockChanged)
+	fast := b.Subscribe(0, TopicStockChanged)
+	for i := 0; i < Buffer+1; i++ {
+		b.Publish(Event{Topic: TopicStockChanged})
+		received(fast)
+	}
+
+	got, open := received(slow)
+	if open || len(got) != Buffer || !slow.Dropped() {
+		t.Errorf("slow subscriber: %d events, open %v, dropped %v; want %d, closed and dropped", len(got), open, slow.Dropped(), Buffer)
+	}
+	slow.Close() // already closed by the bus
+
+	fast.Close()
+	if _, open := received(fast); open || fast.Dropped() {
+		t.Errorf("Close: open %v, dropped %v", open, fast.Dropped())
+	}
+	b.Publish(Event{Topic: TopicStockChanged}) // no subscribers left
+
+	var none *Bus
+	none.Publish(Event{Topic: TopicStockChanged}) // a nil bus discards events
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	assets "github.com/shahinzaman102/Go_JumpStart_Echo"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/mail"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/passhash"
+)
+
+// Lifetimes of emailed links.
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+// accountPage is the data of the register and reset-password templates.
+type accountPage struct {
+	CSRFToken string
+	Error     string
+	Username  string
+	Email     string
+	Token     string
+}
+
+// accountMessage is the data of account_message.html.
+type accountMessage struct {
+	Title    string
+	Message  string
+	Link     string
+	LinkText string
+}
+
+// renderAccount renders an account template with the given status code.
+func renderAccount(c echo.Context, status int, name string, data any) error {
+	tmpl, err := template.ParseFS(assets.Templates, "templates/"+name)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(status)
+	return tmpl.Execute(c.Response(), data)
+}
+
+// renderAccountForm renders a form template with a fresh CSRF token.
+func renderAccountForm(c echo.Context, status int, name string, page accountPage) error {
+	token, err := csrfToken(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to save session")
+	}
+	page.CSRFToken = token
+	return renderAccount(c, status, name, page)
+}
+
+// renderEmailForm renders email_form.html, shared by forgot-password and resend-verification.
+func renderEmailForm(c echo.Context, title, intro, action, button string) error {
+	token, err := csrfToken(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to save session")
+	}
+	return renderAccount(c, http.StatusOK, "email_form.html", map[string]string{
+		"CSRFToken": token, "Title": title, "Intro": intro, "Action": action, "Button": button,
+	})
+}
+
+// normalizeEmail validates a bare email address and lower-cases it; ok is false if it is invalid.
+func normalizeEmail(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	addr, err := netmail.ParseAddress(s)
+	if err != nil || addr.Address != s || len(s) > 255 {
+		return "", false
+	}
+	return strings.ToLower(s), true
+}
+
+// sendLink emails a single-use link for userID to address.
+func (h *Handler) sendLink(c echo.Context, userID int64, address, purpose, path, subject, body string, ttl time.Duration) error {
+	token, err := h.UserTokens.Issue(c.Request().Context(), userID, purpose, ttl)
+	if err != nil {
+		return err
+	}
+	link := h.BaseURL + path + "?token=" + url.QueryEscape(token)
+	return h.Mailer.Send(c.Request().Context(), mail.Message{
+		To:      address,
+		Subject: subject,
+		Body:    fmt.Sprintf(body, link, ttl),
+	})
+}
+
+// sendVerification emails the verify-email link.
+func (h *Handler) sendVerification(c echo.Context, userID int64, address string) error {
+	return h.sendLink(c, userID, address, data.TokenVerifyEmail, "/verify-email", "Confirm your email address",
+		"Welcome! Confirm your email address by opening this link:\n\n%s\n\nThe link expires in %s.\n", verifyEmailTTL)
+}
+
+// RegisterForm renders the sign-up page.
+func (h *Handler) RegisterForm(c echo.Context) error {
+	return renderAccountForm(c, http.StatusOK, "register.html", accountPage{})
+}
+
+// Register creates a customer account from the sign-up form and emails a verification link.
+func (h *Handler) Register(c echo.Context) error {
+	page := accountPage{Username: strings.TrimSpace(c.FormValue("username")), Email: strings.TrimSpace(c.FormValue("email"))}
+	password := c.FormValue("password")
+
+	email, emailOK := normalizeEmail(page.Email)
+	switch msg := validateCredentials(page.Username, password); {
+	case msg != "":
+		page.Error = msg
+	case !emailOK:
+		page.Error = "Enter a valid email address"
+	case password != c.FormValue("confirm"):
+		page.Error = "Passwords do not match"
+	}
+	if page.Error != "" {
+		return renderAccountForm(c, http.StatusBadRequest, "register.html", page)
+	}
+
+	id, err := h.Users.Register(c.Request().Context(), page.Username, email, password)
+	if errors.Is(err, data.ErrUserExists) {
+		page.Error = "That username or email is already registered"
+		return renderAccountForm(c, http.StatusConflict, "register.html", page)
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to create account")
+	}
+
+	msg := accountMessage{
+		Title:    "Check Your Email",
+		Message:  "Your account was created. We sent a link to " + email + " to confirm your address.",
+		Link:     "/login",
+		LinkText: "Continue to login",
+	}
+	if err := h.sendVerification(c, id, email); err != nil {
+		log.Printf("[MAIL] verification email for user %d failed: %v", id, err)
+		msg.Message = "Your account was created, but the confirmation email could not be sent. You can request a new one."
+		msg.Link, msg.LinkText = "/verify-email/resend", "Resend confirmation email"
+	}
+	return renderAccount(c, http.StatusCreated, "account_message.html", msg)
+}
+
+// VerifyEmail consumes a verify-email link.
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	userID, err := h.UserTokens.Consume(c.Request().Context(), c.QueryParam("token"), data.TokenVerifyEmail)
+	if errors.Is(err, data.ErrNotFound) {
+		return renderAccount(c, http.StatusBadRequest, "account_message.html", accountMessage{
+			Title:    "Link Expired",
+			Message:  "This confirmation link is invalid, expired or was already used.",
+			Link:     "/verify-email/resend",
+			LinkText: "Send a new link",
+		})
+	}
+	if err == nil {
+		err = h.Users.MarkEmailVerified(c.Request().Context(), int(userID))
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to verify email")
+	}
+	return renderAccount(c, http.StatusOK, "account_message.html", accountMessage{
+		Title: "Email Confirmed", Message: "Thanks, your email address is confirmed.", Link: "/login", LinkText: "Log in",
+	})
+}
+
+// ResendVerificationForm asks for the address to send a new confirmation link to.
+func (h *Handler) ResendVerificationForm(c echo.Context) error {
+	return renderEmailForm(c, "Resend Confirmation Email", "Enter the email address you signed up with.",
+		"/verify-email/resend", "Send link")
+}
+
+// ResendVerification sends a new confirmation link if the address belongs to an unverified account.
+// The response is the same either way so it can't be used to discover registered addresses.
+func (h *Handler) ResendVerification(c echo.Context) error {
+	if email, ok := normalizeEmail(c.FormValue("email")); ok {
+		user, err := h.Users.ByEmail(c.Request().Context(), email)
+		if err != nil && !errors.Is(err, data.ErrNotFound) {
+			return c.String(http.StatusInternalServerError, "Failed to look up account")
+		}
+		if err == nil && user.EmailVerifiedAt == nil {
+			if err := h.sendVerification(c, int64(user.ID), email); err != nil {
+				log.Printf("[MAIL] verification email for user %d failed: %v", user.ID, err)
+			}
+		}
+	}
+	return renderAccount(c, http.StatusOK, "account_message.html", accountMessage{
+		Title:   "Check Your Email",
+		Message: "If that address belongs to an account awaiting confirmation, a new link is on its way.",
+		Link:    "/login", LinkText: "Back to login",
+	})
+}
+
+// ForgotPasswordForm asks for the address to send a reset link to.
+func (h *Handler) ForgotPasswordForm(c echo.Context) error {
+	return renderEmailForm(c, "Forgot Password", "Enter your account's email address and we'll send you a reset link.",
+		"/forgot-password", "Send reset link")
+}
+
+// ForgotPassword emails a reset link, replacing any earlier one. Like ResendVerification
+// it answers the same whether or not the address is registered.
+func (h *Handler) ForgotPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+	if email, ok := normalizeEmail(c.FormValue("email")); ok {
+		user, err := h.Users.ByEmail(ctx, email)
+		if err != nil && !errors.Is(err, data.ErrNotFound) {
+			return c.String(http.StatusInternalServerError, "Failed to look up account")
+		}
+		if err == nil {
+			if err := h.UserTokens.Invalidate(ctx, int64(user.ID), data.TokenResetPassword); err != nil {
+				return c.String(http.StatusInternalServerError, "Failed to create reset link")
+			}
+			if err := h.sendLink(c, int64(user.ID), email, data.TokenResetPassword, "/reset-password", "Reset your password",
+				"Someone asked to reset the password for your account. If it was you, open this link:\n\n%s\n\n"+
+					"The link expires in %s. If you didn't ask, ignore this email; your password stays the same.\n",
+				resetPasswordTTL); err != nil {
+				log.Printf("[MAIL] reset email for user %d failed: %v", user.ID, err)
+			}
+		}
+	}
+	return renderAccount(c, http.StatusOK, "account_message.html", accountMessage{
+		Title:   "Check Your Email",
+		Message: "If an account uses that address, we sent it a link to reset the password.",
+		Link:    "/login", LinkText: "Back to login",
+	})
+}
+
+// ResetPasswordForm renders the new-password form for a reset link. The token is only
+// checked on submit, so opening the link (or a mail scanner prefetching it) doesn't use it up.
+func (h *Handler) ResetPasswordForm(c echo.Context) error {
+	// Keep the token in the URL out of Referer headers.
+	c.Response().Header().Set("Referrer-Policy", "no-referrer")
+	return renderAccountForm(c, http.StatusOK, "reset_password.html", accountPage{Token: c.QueryParam("token")})
+}
+
+// resetLinkExpired is the response to an unusable reset link.
+func resetLinkExpired(c echo.Context) error {
+	return renderAccount(c, http.StatusBadRequest, "account_message.html", accountMessage{
+		Title:    "Link Expired",
+		Message:  "This reset link is invalid, expired or was already used.",
+		Link:     "/forgot-password",
+		LinkText: "Request a new link",
+	})
+}
+
+// ResetPassword sets a new password from a reset link and logs the account out everywhere.
+func (h *Handler) ResetPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+	page := accountPage{Token: c.FormValue("token")}
+	password := c.FormValue("password")
+
+	// Look the account up without using the link, so a rejected password can be retried.
+	userID, err := h.UserTokens.Lookup(ctx, page.Token, data.TokenResetPassword)
+	var user *models.User
+	if err == nil {
+		user, err = h.Users.ByID(ctx, int(userID))
+	}
+	if errors.Is(err, data.ErrNotFound) {
+		return resetLinkExpired(c)
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to reset password")
+	}
+
+	switch msg := passhash.CheckStrength(password, user.Username); {
+	case msg != "":
+		page.Error = msg
+	case password != c.FormValue("confirm"):
+		page.Error = "Passwords do not match"
+	}
+	if page.Error != "" {
+		return renderAccountForm(c, http.StatusBadRequest, "reset_password.html", page)
+	}
+
+	// Using the link and changing the password happen together, which also settles
+	// races with another use of the same link.
+	if _, err := h.UserTokens.ResetPassword(ctx, page.Token, password); errors.Is(err, data.ErrNotFound) {
+		return resetLinkExpired(c)
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to reset password")
+	}
+	// Following the emailed link proves the address; sessions and bearer tokens from
+	// before the reset may belong to whoever knew the old password.
+	if err := errors.Join(
+		h.UserTokens.Invalidate(ctx, userID, data.TokenResetPassword),
+		h.Users.MarkEmailVerified(ctx, int(userID)),
+		func() error { _, err := h.Sessions.DeleteAllForUser(ctx, userID); return err }(),
+		h.Tokens.RevokeAll(ctx, userID),
+	); err != nil {
+		log.Printf("password reset for user %d: cleanup failed: %v", userID, err)
+	}
+
+	return renderAccount(c, http.StatusOK, "account_message.html", accountMessage{
+		Title: "Password Changed", Message: "Your password was changed and other sessions were signed out.",
+		Link: "/login", LinkText: "Log in",
+	})
+}
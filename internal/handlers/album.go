@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"html/template"
 	"log"
 	"strconv"
@@ -10,26 +11,40 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
 )
 
-// GetAllAlbums responds with all albums in JSON format.
-func GetAllAlbums(c echo.Context) error {
-	albums, err := data.AllAlbums()
+// GetAllAlbums responds with one page of albums in JSON format.
+// Filtering, sorting and paging are driven by query parameters (see parseAlbumQuery);
+// the total match count is sent in X-Total-Count and page links in the Link header.
+func (h *Handler) GetAllAlbums(c echo.Context) error {
+	q, err := parseAlbumQuery(c)
+	if err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+
+	albums, total, err := h.Albums.List(c.Request().Context(), q)
 	if err != nil {
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
+	if albums == nil {
+		albums = []models.Album{}
+	}
+
+	c.Response().Header().Set("X-Total-Count", strconv.Itoa(total))
+	c.Response().Header().Set("Link", paginationLinks(c.Request().URL, q, total))
 	return c.JSON(200, albums)
 }
 
 // GetAlbumsByArtist responds with albums filtered by artist name.
-func GetAlbumsByArtist(c echo.Context) error {
+func (h *Handler) GetAlbumsByArtist(c echo.Context) error {
 	name := strings.TrimSpace(c.Param("name"))
 	if name == "" {
 		return c.JSON(400, map[string]string{"error": "Artist name is required"})
 	}
 
-	albums, err := data.AlbumsByArtist(name)
+	albums, err := h.Albums.ByArtist(c.Request().Context(), name)
 	if err != nil {
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
@@ -37,47 +52,230 @@ func GetAlbumsByArtist(c echo.Context) error {
 }
 
 // GetAlbumByID responds with a single album by its ID.
-func GetAlbumByID(c echo.Context) error {
+func (h *Handler) GetAlbumByID(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil || id <= 0 {
 		return c.JSON(400, map[string]string{"error": "Invalid album ID"})
 	}
 
-	album, err := data.AlbumByID(id)
-	if err != nil {
+	album, err := h.Albums.ByID(c.Request().Context(), id)
+	if errors.Is(err, data.ErrNotFound) {
 		return c.JSON(404, map[string]string{"error": "Album not found"})
 	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	etag := etagFor(album.Version)
+	c.Response().Header().Set("ETag", etag)
+	if notModified(c, etag) {
+		return c.NoContent(304)
+	}
 	return c.JSON(200, album)
 }
 
 // CreateAlbum handles adding a new album to the database.
-func CreateAlbum(c echo.Context) error {
+func (h *Handler) CreateAlbum(c echo.Context) error {
 	var album models.Album
 	if err := c.Bind(&album); err != nil {
 		return c.JSON(400, map[string]string{"error": "Invalid JSON"})
 	}
 
+	if msg := validateAlbum(&album); msg != "" {
+		return c.JSON(400, map[string]string{"error": msg})
+	}
+
+	id, err := h.Albums.Add(c.Request().Context(), album)
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	album.ID = id
+	album.Version = 1 // new rows start at version 1
+	c.Response().Header().Set("ETag", etagFor(album.Version))
+	return c.JSON(201, album)
+}
+
+// validateAlbum trims and checks album fields; it returns an error message or "" when valid.
+// Create, replace and patch all go through it so the rules can't drift apart.
+func validateAlbum(album *models.Album) string {
 	album.Title = strings.TrimSpace(album.Title)
 	album.Artist = strings.TrimSpace(album.Artist)
 
 	if album.Title == "" || album.Artist == "" {
-		return c.JSON(400, map[string]string{"error": "Title and Artist are required"})
+		return "Title and Artist are required"
 	}
 	if len(album.Title) > 200 || len(album.Artist) > 100 {
-		return c.JSON(400, map[string]string{"error": "Title or Artist too long"})
+		return "Title or Artist too long"
+	}
+	if album.Price < 0 || album.Quantity < 0 {
+		return "Price and Quantity must not be negative"
+	}
+	return ""
+}
+
+// parseAlbumID reads and validates the :id path parameter.
+func parseAlbumID(c echo.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// UpdateAlbum replaces an album (PUT /albums/:id).
+// Send If-Match with the album's ETag to avoid overwriting someone else's change (412 otherwise).
+// Use AdjustAlbumStock for stock changes that must not race with orders.
+func (h *Handler) UpdateAlbum(c echo.Context) error {
+	id, ok := parseAlbumID(c)
+	if !ok {
+		return c.JSON(400, map[string]string{"error": "Invalid album ID"})
+	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return preconditionFailed(c, "error")
+	}
+
+	var album models.Album
+	if err := c.Bind(&album); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid JSON"})
+	}
+	album.ID = id
+
+	if msg := validateAlbum(&album); msg != "" {
+		return c.JSON(400, map[string]string{"error": msg})
+	}
+	return h.saveAlbum(c, album, ifVersion)
+}
+
+// PatchAlbum updates only the fields present in the request body (PATCH /albums/:id).
+// If-Match is honoured like in UpdateAlbum.
+func (h *Handler) PatchAlbum(c echo.Context) error {
+	id, ok := parseAlbumID(c)
+	if !ok {
+		return c.JSON(400, map[string]string{"error": "Invalid album ID"})
+	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return preconditionFailed(c, "error")
+	}
+
+	var patch struct {
+		Title    *string  `json:"title"`
+		Artist   *string  `json:"artist"`
+		Price    *float32 `json:"price"`
+		Quantity *int64   `json:"quantity"`
+	}
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid JSON"})
+	}
+	if patch.Title == nil && patch.Artist == nil && patch.Price == nil && patch.Quantity == nil {
+		return c.JSON(400, map[string]string{"error": "No fields to update"})
 	}
 
-	id, err := data.AddAlbum(album)
+	album, err := h.Albums.ByID(c.Request().Context(), id)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(404, map[string]string{"error": "Album not found"})
+	}
 	if err != nil {
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
-	album.ID = id
-	return c.JSON(201, album)
+	if ifVersion != 0 && ifVersion != album.Version {
+		return preconditionFailed(c, "error")
+	}
+
+	if patch.Title != nil {
+		album.Title = *patch.Title
+	}
+	if patch.Artist != nil {
+		album.Artist = *patch.Artist
+	}
+	if patch.Price != nil {
+		album.Price = *patch.Price
+	}
+	if patch.Quantity != nil {
+		album.Quantity = *patch.Quantity
+	}
+
+	if msg := validateAlbum(&album); msg != "" {
+		return c.JSON(400, map[string]string{"error": msg})
+	}
+	// Condition on the version we read so a concurrent change between read and write isn't lost.
+	return h.saveAlbum(c, album, album.Version)
+}
+
+// saveAlbum persists a validated album and writes the response shared by PUT and PATCH.
+func (h *Handler) saveAlbum(c echo.Context, album models.Album, ifVersion int64) error {
+	saved, err := h.Albums.Update(c.Request().Context(), album, ifVersion)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return c.JSON(404, map[string]string{"error": "Album not found"})
+	case errors.Is(err, data.ErrVersionConflict):
+		return preconditionFailed(c, "error")
+	case err != nil:
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set("ETag", etagFor(saved.Version))
+	return c.JSON(200, saved)
+}
+
+// DeleteAlbum removes an album; albums that have orders are kept (409 Conflict).
+// If-Match is honoured like in UpdateAlbum.
+func (h *Handler) DeleteAlbum(c echo.Context) error {
+	id, ok := parseAlbumID(c)
+	if !ok {
+		return c.JSON(400, map[string]string{"error": "Invalid album ID"})
+	}
+
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return preconditionFailed(c, "error")
+	}
+
+	err := h.Albums.Delete(c.Request().Context(), id, ifVersion)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return c.JSON(404, map[string]string{"error": "Album not found"})
+	case errors.Is(err, data.ErrVersionConflict):
+		return preconditionFailed(c, "error")
+	case errors.Is(err, data.ErrAlbumHasOrders):
+		return c.JSON(409, map[string]string{"error": "Album has orders and cannot be deleted"})
+	case err != nil:
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, map[string]any{
+		"status": "deleted",
+		"id":     id,
+	})
+}
+
+// AdjustAlbumStock adds a signed delta to an album's quantity (POST /albums/:id/stock).
+// Body: {"delta": -2}. The change is a single atomic update, safe alongside concurrent orders.
+func (h *Handler) AdjustAlbumStock(c echo.Context) error {
+	id, ok := parseAlbumID(c)
+	if !ok {
+		return c.JSON(400, map[string]string{"error": "Invalid album ID"})
+	}
+
+	var input struct {
+		Delta *int64 `json:"delta"`
+	}
+	if err := c.Bind(&input); err != nil || input.Delta == nil {
+		return c.JSON(400, map[string]string{"error": "delta is required"})
+	}
+
+	album, err := h.Albums.AdjustStock(c.Request().Context(), id, *input.Delta)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return c.JSON(404, map[string]string{"error": "Album not found"})
+	case errors.Is(err, data.ErrInsufficientStock):
+		return c.JSON(409, map[string]any{"error": "Stock cannot go below zero", "quantity": album.Quantity})
+	case err != nil:
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set("ETag", etagFor(album.Version))
+	return c.JSON(200, album)
 }
 
 // CanPurchaseAlbum checks if the requested quantity can be purchased.
-func CanPurchaseAlbum(c echo.Context) error {
+func (h *Handler) CanPurchaseAlbum(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil || id <= 0 {
@@ -90,7 +288,7 @@ func CanPurchaseAlbum(c echo.Context) error {
 		return c.JSON(400, map[string]string{"error": "Quantity must be positive"})
 	}
 
-	ok, err := data.CanPurchase(id, qty)
+	ok, err := h.Albums.CanPurchase(c.Request().Context(), id, qty)
 	if err != nil {
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
@@ -99,28 +297,24 @@ func CanPurchaseAlbum(c echo.Context) error {
 }
 
 // GetOrdersByUser serves the last 10 orders for a logged-in user (HTML page).
-func GetOrdersByUser(c echo.Context) error {
-	session, _ := store.Get(c.Request(), "session")
-	auth, authOk := session.Values["authenticated"].(bool)
-	userID, idOk := session.Values["user_id"].(int64)
-
-	if !authOk || !auth || !idOk {
-		session.Values["redirect_after_login"] = "/orders"
-		session.Save(c.Request(), c.Response())
-		c.Response().WriteHeader(401)
-		tmpl := template.Must(template.ParseFiles("templates/login_required.html"))
-		return tmpl.Execute(c.Response(), map[string]string{"Resource": "Orders API"})
+func (h *Handler) GetOrdersByUser(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
 	}
 
-	cacheKey := fmt.Sprintf("orders:user:%d:last10", userID)
+	cacheKey := ordersCacheKey(userID)
 	var orders []models.GetOrder
 
 	if err := data.GetOrdersCache(cacheKey, &orders); err != nil {
 		log.Printf("Cache MISS for user: %d", userID)
 		log.Printf("[SIMULATION] Sleeping 2s to simulate slow DB query for user %d...", userID)
 
-		orders, err = data.GetOrdersByUser(userID)
-		if err != nil {
+		custID, err := h.customerID(c, userID)
+		if err == nil {
+			orders, err = h.Orders.ByCustomer(c.Request().Context(), custID)
+		}
+		if err != nil && !errors.Is(err, data.ErrNotFound) { // no profile means no orders yet
 			return c.JSON(500, map[string]string{"error": err.Error()})
 		}
 
@@ -135,15 +329,10 @@ func GetOrdersByUser(c echo.Context) error {
 }
 
 // CreateOrderByUser handles creating a new order for the logged-in user.
-func CreateOrderByUser(c echo.Context) error {
-	session, _ := store.Get(c.Request(), "session")
-	userID, ok := session.Values["user_id"].(int64)
-	auth, authOk := session.Values["authenticated"].(bool)
-
-	if !ok || !authOk || !auth {
-		session.Values["redirect_after_login"] = "/orders"
-		session.Save(c.Request(), c.Response())
-		return c.String(401, "Unauthorized: You must log in first to create an order.\nVisit /login to log in and retry.")
+func (h *Handler) CreateOrderByUser(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
 	}
 
 	var order models.OrderRequest
@@ -155,14 +344,26 @@ func CreateOrderByUser(c echo.Context) error {
 		return c.JSON(400, map[string]string{"error": "AlbumID and Quantity must be positive"})
 	}
 
-	order.Customer = userID
-
-	id, err := data.CreateOrderByUser(c.Request().Context(), order.AlbumID, order.Quantity, order.Customer)
+	custID, err := h.customerID(c, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return profileRequired(c)
+	}
 	if err != nil {
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
+	order.Customer = custID
+
+	id, err := h.Orders.Create(c.Request().Context(), order.AlbumID, order.Quantity, order.Customer)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return c.JSON(404, map[string]string{"error": err.Error()})
+	case errors.Is(err, data.ErrInsufficientStock):
+		return c.JSON(409, map[string]string{"error": err.Error()})
+	case err != nil:
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
 
-	cacheKey := fmt.Sprintf("orders:user:%d:last10", order.Customer)
+	cacheKey := ordersCacheKey(userID)
 	var cached []models.GetOrder
 	if err := data.GetOrdersCache(cacheKey, &cached); err == nil {
 		newOrder := models.GetOrder{
@@ -171,13 +372,14 @@ func CreateOrderByUser(c echo.Context) error {
 			Customer: order.Customer,
 			Quantity: order.Quantity,
 			Date:     time.Now(),
+			Status:   models.OrderPending,
 		}
 		cached = append([]models.GetOrder{newOrder}, cached...)
 		if len(cached) > 10 {
 			cached = cached[:10]
 		}
 		_ = data.SetOrdersCache(cacheKey, cached)
-		log.Printf("[CACHE UPDATE] User %d cache updated with new order %d", newOrder.Customer, newOrder.ID)
+		log.Printf("[CACHE UPDATE] User %d cache updated with new order %d", userID, newOrder.ID)
 	} else {
 		_ = data.OrderCache.Delete(cacheKey)
 	}
@@ -189,14 +391,14 @@ func CreateOrderByUser(c echo.Context) error {
 }
 
 // GetCustomerName returns the full name of a customer by ID.
-func GetCustomerName(c echo.Context) error {
+func (h *Handler) GetCustomerName(c echo.Context) error {
 	idStr := c.QueryParam("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil || id <= 0 {
 		return c.JSON(400, map[string]string{"error": "Invalid customer ID"})
 	}
 
-	name, err := data.GetCustomerName(id)
+	name, err := h.Customers.Name(c.Request().Context(), id)
 	if err != nil {
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
@@ -205,17 +407,23 @@ func GetCustomerName(c echo.Context) error {
 }
 
 // HandleMultipleResultSets demonstrates fetching multiple result sets (albums + customers).
-func HandleMultipleResultSets(c echo.Context) error {
-	result, err := data.GetAlbumsAndCustomers()
+func (h *Handler) HandleMultipleResultSets(c echo.Context) error {
+	albums, customers, err := h.Customers.AllWithAlbums(c.Request().Context())
 	if err != nil {
 		return c.JSON(500, map[string]string{"error": err.Error()})
 	}
-	return c.JSON(200, result)
+	return c.JSON(200, map[string]any{
+		"albums":    albums,
+		"customers": customers,
+	})
 }
 
 // QueryWithTimeout executes a DB query with a timeout context.
-func QueryWithTimeout(c echo.Context) error {
-	albums, err := data.QueryAlbumsWithTimeout(c.Request().Context())
+func (h *Handler) QueryWithTimeout(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	albums, err := h.Albums.All(ctx)
 	if err != nil {
 		return c.JSON(504, map[string]string{"error": err.Error()})
 	}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+)
+
+const (
+	defaultAlbumLimit = 50
+	maxAlbumLimit     = 100
+)
+
+// parseAlbumQuery reads the GET /albums query parameters:
+//
+//	limit=1..100            page size (default 50)
+//	cursor=<opaque>         value from a previous Link header
+//	sort=price | -price     title, artist, price, quantity or id; "-" sorts descending
+//	min_price, max_price    inclusive price range
+//	in_stock=true           only albums with quantity > 0
+//	artist=<text>           case-insensitive substring of the artist name
+func parseAlbumQuery(c echo.Context) (data.AlbumQuery, error) {
+	q := data.AlbumQuery{Limit: defaultAlbumLimit}
+
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAlbumLimit {
+			return q, fmt.Errorf("limit must be an integer between 1 and %d", maxAlbumLimit)
+		}
+		q.Limit = limit
+	}
+
+	if v := c.QueryParam("cursor"); v != "" {
+		offset, err := decodeCursor(v)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = offset
+	}
+
+	if v := c.QueryParam("sort"); v != "" {
+		field := strings.TrimPrefix(v, "-")
+		if !slices.Contains(data.AlbumSortFields, field) {
+			return q, fmt.Errorf("sort must be one of %s (prefix with - for descending)", strings.Join(data.AlbumSortFields, ", "))
+		}
+		q.SortBy = field
+		q.Desc = strings.HasPrefix(v, "-")
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **float32
+	}{{"min_price", &q.MinPrice}, {"max_price", &q.MaxPrice}} {
+		v := c.QueryParam(p.name)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil || f < 0 {
+			return q, fmt.Errorf("%s must be a non-negative number", p.name)
+		}
+		price := float32(f)
+		*p.dst = &price
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return q, errors.New("min_price must not be greater than max_price")
+	}
+
+	if v := c.QueryParam("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("in_stock must be true or false")
+		}
+		q.InStock = inStock
+	}
+
+	q.Artist = strings.TrimSpace(c.QueryParam("artist"))
+	if len(q.Artist) > 100 {
+		return q, errors.New("artist filter too long")
+	}
+	return q, nil
+}
+
+// encodeCursor turns an offset into the opaque cursor handed out in Link headers.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor reverses encodeCursor.
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if v, ok := strings.CutPrefix(string(raw), "offset:"); ok {
+			if offset, err := strconv.Atoi(v); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return 0, errors.New("invalid cursor")
+}
+
+// paginationLinks builds an RFC 8288 Link header value with first/prev/next relations,
+// keeping the request's filters and sort so clients can follow the links as-is.
+func paginationLinks(u *url.URL, q data.AlbumQuery, total int) string {
+	link := func(offset int, rel string) string {
+		params := u.Query()
+		params.Set("limit", strconv.Itoa(q.Limit))
+		if offset > 0 {
+			params.Set("cursor", encodeCursor(offset))
+		} else {
+			params.Del("cursor")
+		}
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, params.Encode(), rel)
+	}
+
+	links := []string{link(0, "first")}
+	if q.Offset > 0 {
+		links = append(links, link(max(q.Offset-q.Limit, 0), "prev"))
+	}
+	if q.Offset+q.Limit < total {
+		links = append(links, link(q.Offset+q.Limit, "next"))
+	}
+	return strings.Join(links, ", ")
+}
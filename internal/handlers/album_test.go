@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -11,41 +11,22 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
-
-	_ "modernc.org/sqlite"
 )
 
-// local test DB helper (self-contained for handlers tests)
-func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite", ":memory:") // in-memory DB
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	_, err = db.Exec(`CREATE TABLE album (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT, artist TEXT, price REAL, quantity INTEGER
-	)`)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	_, err = db.Exec(`INSERT INTO album (title, artist, price, quantity)
-		VALUES ("Go Beats", "Gopher", 9.99, 5)`)
-	if err != nil {
-		t.Fatal(err)
+// local test handler helper (self-contained, in-memory repositories — no DB needed)
+func setupAlbumHandler(t *testing.T) *Handler {
+	albums := data.NewMemoryAlbumRepo(models.Album{Title: "Go Beats", Artist: "Gopher", Price: 9.99, Quantity: 5})
+	return &Handler{
+		Albums:    albums,
+		Orders:    data.NewMemoryOrderRepo(albums),
+		Customers: data.NewMemoryCustomerRepo(albums),
+		Users:     data.NewMemoryUserRepo(),
 	}
-
-	return db
-}
-
-func setupAlbumHandlerDB(t *testing.T) {
-	db := setupTestDB(t)
-	data.InitDBConnection(db)
 }
 
 func TestGetAlbumByID(t *testing.T) {
-	setupAlbumHandlerDB(t)
+	t.Parallel()
+	h := setupAlbumHandler(t)
 
 	// Create Echo instance
 	e := echo.New()
@@ -60,7 +41,7 @@ func TestGetAlbumByID(t *testing.T) {
 	c.SetParamValues("1")
 
 	// Call handler
-	if err := GetAlbumByID(c); err != nil {
+	if err := h.GetAlbumByID(c); err != nil {
 		t.Fatalf("handler returned error: %v", err)
 	}
 
@@ -77,3 +58,154 @@ func TestGetAlbumByID(t *testing.T) {
 		t.Errorf("expected artist Gopher, got %s", alb.Artist)
 	}
 }
+
+func TestGetAlbumByIDNotFound(t *testing.T) {
+	t.Parallel()
+	h := setupAlbumHandler(t)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/albums/42", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("42")
+
+	if err := h.GetAlbumByID(c); err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestGetAllAlbumsPaginationAndFilters(t *testing.T) {
+	t.Parallel()
+	albums := data.NewMemoryAlbumRepo(
+		models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 56.99, Quantity: 10},
+		models.Album{Title: "Giant Steps", Artist: "John Coltrane", Price: 63.99, Quantity: 0},
+		models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 17.99, Quantity: 12},
+	)
+	h := &Handler{Albums: albums}
+	e := echo.New()
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/albums?"+query, nil)
+		rec := httptest.NewRecorder()
+		if err := h.GetAllAlbums(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return rec
+	}
+
+	rec := get("limit=1&sort=-price&artist=coltrane")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var page []models.Album
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(page) != 1 || page[0].Title != "Giant Steps" {
+		t.Errorf("unexpected first page: %+v", page)
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "2" {
+		t.Errorf("expected X-Total-Count 2, got %q", got)
+	}
+	if !strings.Contains(rec.Header().Get("Link"), `rel="next"`) {
+		t.Errorf("expected a next link, got %q", rec.Header().Get("Link"))
+	}
+
+	rec = get("in_stock=true&max_price=60")
+	if got := rec.Header().Get("X-Total-Count"); got != "2" {
+		t.Errorf("expected 2 in-stock albums under 60, got %q", got)
+	}
+
+	for _, bad := range []string{"limit=0", "sort=color", "cursor=nope", "min_price=9&max_price=1", "in_stock=maybe"} {
+		if rec := get(bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", bad, rec.Code)
+		}
+	}
+}
+
+func TestDeleteAlbumWithOrdersConflicts(t *testing.T) {
+	t.Parallel()
+	h := setupAlbumHandler(t)
+	e := echo.New()
+
+	if _, err := h.Orders.Create(context.Background(), 1, 2, 1); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/albums/1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	if err := h.DeleteAlbum(c); err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+
+	// Stock can't be pulled below zero: 5 seeded, 2 ordered, 3 left.
+	req = httptest.NewRequest(http.MethodPost, "/albums/1/stock", strings.NewReader(`{"delta": -4}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	if err := h.AdjustAlbumStock(c); err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for negative stock, got %d", rec.Code)
+	}
+}
+
+func TestAlbumETagPreconditions(t *testing.T) {
+	t.Parallel()
+	h := setupAlbumHandler(t)
+	e := echo.New()
+
+	do := func(method, body string, header map[string]string, fn echo.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/albums/1", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		if err := fn(c); err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return rec
+	}
+
+	rec := do(http.MethodGet, "", nil, h.GetAlbumByID)
+	etag := rec.Header().Get("ETag")
+	if etag != `"v1"` {
+		t.Fatalf("expected ETag \"v1\", got %q", etag)
+	}
+	if rec := do(http.MethodGet, "", map[string]string{"If-None-Match": etag}, h.GetAlbumByID); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodPatch, `{"price": 12.5}`, map[string]string{"If-Match": etag}, h.PatchAlbum)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"v2"` {
+		t.Fatalf("expected 200 with ETag \"v2\", got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	// A second editor still holding v1 must not overwrite the change.
+	body := `{"title": "Go Beats", "artist": "Gopher", "price": 1, "quantity": 5}`
+	if rec := do(http.MethodPut, body, map[string]string{"If-Match": etag}, h.UpdateAlbum); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for stale PUT, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "", map[string]string{"If-Match": etag}, h.DeleteAlbum); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for stale DELETE, got %d", rec.Code)
+	}
+}
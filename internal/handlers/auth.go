@@ -1,50 +1,171 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"html/template"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	assets "github.com/shahinzaman102/Go_JumpStart_Echo"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/sessionstore"
 
 	"github.com/gorilla/sessions"
 	"github.com/labstack/echo/v4"
 )
 
 var (
-	store    *sessions.CookieStore
+	store    *sessionstore.Store
 	authRepo *data.AuthRepo
 )
 
 // Init initializes the session store and auth repository.
-func Init(s *sessions.CookieStore, repo *data.AuthRepo) {
+func Init(s *sessionstore.Store, repo *data.AuthRepo) {
 	store = s
 	authRepo = repo
 }
 
+var (
+	// errBadCredentials is returned by checkCredentials for an unknown username or wrong password,
+	// and by checkSecondFactor for a wrong code.
+	errBadCredentials = errors.New("invalid username or password")
+	// errSecondFactor is returned by checkCredentials when the password is right but the
+	// account has 2FA on; the login completes only after checkSecondFactor.
+	errSecondFactor = errors.New("two-factor code required")
+)
+
+// checkCredentials verifies a username and password behind the login guard and records the attempt.
+// A positive wait means the attempt was throttled without checking the password.
+func (h *Handler) checkCredentials(c echo.Context, username, password string) (userID int64, wait time.Duration, err error) {
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+	if len(username) > 255 { // longer than any stored username
+		return 0, 0, errBadCredentials
+	}
+
+	ok, wait, err := h.Guard.Attempt(ctx, username, ip, func() (bool, error) {
+		ok, err := authRepo.VerifyUser(username, password)
+		if errors.Is(err, sql.ErrNoRows) { // unknown username: a failure like a wrong password
+			return false, nil
+		}
+		return ok, err
+	})
+	if err != nil || wait > 0 {
+		return 0, wait, err
+	}
+	if !ok {
+		return 0, 0, errBadCredentials
+	}
+
+	if userID, err = authRepo.GetUserID(username); err != nil {
+		return 0, 0, err
+	}
+	// With 2FA on, the success is recorded after the code is checked, so a known
+	// password can't be used to clear the failures of code guesses.
+	tf, err := h.TwoFactor.ByUser(ctx, userID)
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		return 0, 0, err
+	}
+	if tf.Enabled() {
+		return userID, 0, errSecondFactor
+	}
+	return userID, 0, h.Guard.Succeed(ctx, username, ip)
+}
+
+// checkSecondFactor verifies a TOTP or recovery code of userID behind the login guard.
+// Wrong codes count as failed logins for username; a success is left for the caller to record.
+func (h *Handler) checkSecondFactor(c echo.Context, username string, userID int64, code string) (wait time.Duration, err error) {
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+	ok, wait, err := h.Guard.Attempt(ctx, username, ip, func() (bool, error) {
+		return h.verifySecondFactor(ctx, userID, code)
+	})
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	if !ok {
+		return 0, errBadCredentials
+	}
+	return 0, nil
+}
+
+// retryAfter sets the Retry-After header and returns the wait in whole seconds (at least 1).
+func retryAfter(c echo.Context, wait time.Duration) int {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(secs))
+	return secs
+}
+
+// csrfToken returns the session's CSRF token for embedding in a page, saving the session
+// when the token is new. Call it before writing the response body.
+func csrfToken(c echo.Context) (string, error) {
+	session, _ := store.Get(c.Request(), "session")
+	token, created := middleware.EnsureCSRFToken(session)
+	if created {
+		if err := session.Save(c.Request(), c.Response()); err != nil {
+			return "", err
+		}
+	}
+	return token, nil
+}
+
 // Login handles user login: verifies credentials and sets session values.
-func Login(c echo.Context) error {
+// For accounts with 2FA on, it only marks the session as waiting for a code
+// and sends the user to LoginTwoFactorForm.
+func (h *Handler) Login(c echo.Context) error {
 	session, _ := store.Get(c.Request(), "session")
 
 	username := c.FormValue("username")
 	password := c.FormValue("password")
 
-	ok, err := authRepo.VerifyUser(username, password)
-	if err != nil || !ok {
+	userID, wait, err := h.checkCredentials(c, username, password)
+	switch {
+	case wait > 0:
+		return tooManyAttempts(c, wait)
+	case errors.Is(err, errBadCredentials):
 		tmpl := template.Must(template.ParseFS(assets.Templates, "templates/unauthorized.html"))
 		c.Response().WriteHeader(http.StatusUnauthorized)
 		return tmpl.Execute(c.Response(), nil)
+	case errors.Is(err, errSecondFactor):
+		redirectPath := loginRedirect(c, session)
+		if err := store.Renew(c.Request(), session); err != nil {
+			return c.String(http.StatusInternalServerError, "Failed to renew session")
+		}
+		session.Values[pendingUserKey] = userID
+		session.Values[pendingUsernameKey] = username
+		session.Values[pendingExpiresKey] = time.Now().Add(secondFactorTimeout).Unix()
+		session.Values[pendingRedirectKey] = redirectPath
+		if err := session.Save(c.Request(), c.Response()); err != nil {
+			return c.String(http.StatusInternalServerError, "Failed to save session")
+		}
+		return c.Redirect(http.StatusSeeOther, "/login/2fa")
+	case err != nil:
+		return c.String(http.StatusInternalServerError, "Failed to verify credentials")
 	}
 
-	userID, err := authRepo.GetUserID(username)
-	if err != nil {
-		return c.String(http.StatusInternalServerError, "Failed to fetch user ID")
+	redirectPath := loginRedirect(c, session)
+	if err := store.Renew(c.Request(), session); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to renew session")
 	}
-
 	session.Values["authenticated"] = true
 	session.Values["user_id"] = userID
 
-	// Determine redirect path
+	if err := session.Save(c.Request(), c.Response()); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to save session")
+	}
+
+	return c.Redirect(http.StatusSeeOther, redirectPath)
+}
+
+// loginRedirect returns where to send the user after logging in.
+func loginRedirect(c echo.Context, session *sessions.Session) string {
 	redirectPath := c.FormValue("redirect")
 	if redirectPath == "" {
 		redirectPath = c.QueryParam("redirect")
@@ -58,20 +179,28 @@ func Login(c echo.Context) error {
 	if redirectPath == "" {
 		redirectPath = "/dashboard"
 	}
+	return redirectPath
+}
 
-	if err := session.Save(c.Request(), c.Response()); err != nil {
-		return c.String(http.StatusInternalServerError, "Failed to save session")
-	}
-
-	return c.Redirect(http.StatusSeeOther, redirectPath)
+// tooManyAttempts renders the 429 page for a throttled login.
+func tooManyAttempts(c echo.Context, wait time.Duration) error {
+	tmpl := template.Must(template.ParseFS(assets.Templates, "templates/too_many_attempts.html"))
+	secs := retryAfter(c, wait)
+	c.Response().WriteHeader(http.StatusTooManyRequests)
+	return tmpl.Execute(c.Response(), map[string]int{"RetryAfter": secs})
 }
 
 // LoginForm renders the login page with an optional redirect.
 func LoginForm(c echo.Context) error {
 	redirect := c.QueryParam("redirect")
+	token, err := csrfToken(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to save session")
+	}
 	tmpl := template.Must(template.ParseFS(assets.Templates, "templates/login.html"))
 	return tmpl.Execute(c.Response(), map[string]string{
-		"Redirect": redirect,
+		"Redirect":  redirect,
+		"CSRFToken": token,
 	})
 }
 
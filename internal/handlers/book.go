@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,29 +12,43 @@ import (
 )
 
 // GetBooks returns all books in JSON format.
-func GetBooks(c echo.Context) error {
-	books := data.GetAllBooks()
+func (h *Handler) GetBooks(c echo.Context) error {
+	books, err := h.Books.All(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
+	if books == nil {
+		books = []models.Book{}
+	}
 	return c.JSON(http.StatusOK, books)
 }
 
 // GetBookByID returns a single book by ID.
-func GetBookByID(c echo.Context) error {
+func (h *Handler) GetBookByID(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "invalid book ID"})
 	}
 
-	book, err := data.GetBookByID(id)
-	if err != nil {
+	book, err := h.Books.ByID(c.Request().Context(), id)
+	if errors.Is(err, data.ErrNotFound) {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "book not found"})
 	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
 
+	etag := etagFor(int64(book.Version))
+	c.Response().Header().Set("ETag", etag)
+	if notModified(c, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	return c.JSON(http.StatusOK, book)
 }
 
 // PostBook creates a new book.
-func PostBook(c echo.Context) error {
+func (h *Handler) PostBook(c echo.Context) error {
 	var newBook models.Book
 	if err := c.Bind(&newBook); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "invalid JSON"})
@@ -54,17 +69,26 @@ func PostBook(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Author must be 1-100 characters"})
 	}
 
-	book := data.AddBook(newBook)
+	book, err := h.Books.Add(c.Request().Context(), newBook)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
+	c.Response().Header().Set("ETag", etagFor(int64(book.Version)))
 	return c.JSON(http.StatusCreated, book)
 }
 
 // UpdateBook updates an existing book by ID.
-func UpdateBook(c echo.Context) error {
+// If-Match with the book's ETag guards against overwriting a concurrent edit (412 otherwise).
+func (h *Handler) UpdateBook(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "invalid book ID"})
 	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return preconditionFailed(c, "message")
+	}
 
 	var updatedData models.Book
 	if err := c.Bind(&updatedData); err != nil {
@@ -87,11 +111,13 @@ func UpdateBook(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Author must be 1-100 characters"})
 	}
 
-	updatedBook, err := data.UpdateBook(id, updatedData)
+	updatedBook, err := h.Books.Update(c.Request().Context(), id, updatedData, int(ifVersion))
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"message": "book not found"})
+		return bookError(c, err)
 	}
 
+	c.Response().Header().Set("ETag", etagFor(int64(updatedBook.Version)))
+
 	return c.JSON(http.StatusOK, map[string]any{
 		"status":  "success",
 		"message": "book updated successfully",
@@ -99,16 +125,20 @@ func UpdateBook(c echo.Context) error {
 	})
 }
 
-// DeleteBook removes a book by ID.
-func DeleteBook(c echo.Context) error {
+// DeleteBook removes a book by ID, honouring If-Match like UpdateBook.
+func (h *Handler) DeleteBook(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "invalid book ID"})
 	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return preconditionFailed(c, "message")
+	}
 
-	if err := data.DeleteBook(id); err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"message": "book not found"})
+	if err := h.Books.Delete(c.Request().Context(), id, int(ifVersion)); err != nil {
+		return bookError(c, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -116,3 +146,14 @@ func DeleteBook(c echo.Context) error {
 		"message": "book deleted successfully",
 	})
 }
+
+// bookError maps a BookRepository write error to a JSON response.
+func bookError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "book not found"})
+	case errors.Is(err, data.ErrVersionConflict):
+		return preconditionFailed(c, "message")
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// maxCartLines bounds the cart, which is stored in the server-side session row
+// and checked line by line at checkout, so one session can't grow either without limit.
+const maxCartLines = 50
+
+// maxLineQuantity caps the quantity of one cart line, keeping merged additions
+// far from overflowing int64.
+const maxLineQuantity = 1000
+
+func init() {
+	// Session values are gob-encoded; custom types must be registered.
+	gob.Register([]models.CartLine{})
+}
+
+// loadCart returns the session and the cart stored in it.
+func loadCart(c echo.Context) (*sessions.Session, []models.CartLine) {
+	session, _ := store.Get(c.Request(), "session")
+	lines, _ := session.Values["cart"].([]models.CartLine)
+	return session, lines
+}
+
+// saveCart stores lines in the session, removing the key once the cart is empty.
+func saveCart(c echo.Context, session *sessions.Session, lines []models.CartLine) error {
+	if len(lines) == 0 {
+		delete(session.Values, "cart")
+	} else {
+		session.Values["cart"] = lines
+	}
+	return session.Save(c.Request(), c.Response())
+}
+
+// cartView joins the cart lines with the current album data.
+// Albums that no longer exist stay in the view (with no stock) so checkout can report them.
+func (h *Handler) cartView(c echo.Context, lines []models.CartLine) (models.CartView, error) {
+	view := models.CartView{Lines: []models.CartViewLine{}}
+	for _, l := range lines {
+		vl := models.CartViewLine{CartLine: l}
+		album, err := h.Albums.ByID(c.Request().Context(), l.AlbumID)
+		if err != nil && !errors.Is(err, data.ErrNotFound) {
+			return view, err
+		}
+		if err == nil {
+			vl.Title, vl.Artist, vl.Price, vl.InStock = album.Title, album.Artist, album.Price, album.Quantity
+			vl.Subtotal = album.Price * float32(l.Quantity)
+		}
+		view.Lines = append(view.Lines, vl)
+		view.Total += vl.Subtotal
+	}
+	return view, nil
+}
+
+// respondCart writes the cart view as JSON.
+func (h *Handler) respondCart(c echo.Context, status int, lines []models.CartLine) error {
+	view, err := h.cartView(c, lines)
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(status, view)
+}
+
+// parseCartAlbumID reads the :album_id path parameter, writing a 400 response when it is invalid.
+func parseCartAlbumID(c echo.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("album_id"), 10, 64)
+	if err != nil || id <= 0 {
+		_ = c.JSON(400, map[string]string{"error": "Invalid album ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetCart returns the session cart with current titles, prices and stock.
+func (h *Handler) GetCart(c echo.Context) error {
+	_, lines := loadCart(c)
+	return h.respondCart(c, 200, lines)
+}
+
+// AddCartItem adds an album to the cart, or increases its quantity when it is already there.
+func (h *Handler) AddCartItem(c echo.Context) error {
+	var line models.CartLine
+	if err := c.Bind(&line); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid JSON"})
+	}
+	if line.AlbumID <= 0 || line.Quantity <= 0 {
+		return c.JSON(400, map[string]string{"error": "AlbumID and Quantity must be positive"})
+	}
+	if line.Quantity > maxLineQuantity {
+		return c.JSON(400, map[string]string{"error": fmt.Sprintf("Quantity must be at most %d", maxLineQuantity)})
+	}
+
+	if _, err := h.Albums.ByID(c.Request().Context(), line.AlbumID); errors.Is(err, data.ErrNotFound) {
+		return c.JSON(404, map[string]string{"error": "Album not found"})
+	} else if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	session, lines := loadCart(c)
+	i := slices.IndexFunc(lines, func(l models.CartLine) bool { return l.AlbumID == line.AlbumID })
+	switch {
+	case i >= 0 && lines[i].Quantity > maxLineQuantity-line.Quantity:
+		return c.JSON(400, map[string]string{"error": fmt.Sprintf("Quantity must be at most %d", maxLineQuantity)})
+	case i >= 0:
+		lines[i].Quantity += line.Quantity
+	case len(lines) >= maxCartLines:
+		return c.JSON(400, map[string]string{"error": "Cart is full"})
+	default:
+		lines = append(lines, line)
+	}
+
+	if err := saveCart(c, session, lines); err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to save session"})
+	}
+	return h.respondCart(c, 200, lines)
+}
+
+// UpdateCartItem sets the quantity of a cart line; a quantity of 0 removes it.
+func (h *Handler) UpdateCartItem(c echo.Context) error {
+	albumID, ok := parseCartAlbumID(c)
+	if !ok {
+		return nil
+	}
+	var body struct {
+		Quantity *int64 `json:"quantity"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid JSON"})
+	}
+	if body.Quantity == nil || *body.Quantity < 0 {
+		return c.JSON(400, map[string]string{"error": "Quantity must be zero or positive"})
+	}
+	if *body.Quantity > maxLineQuantity {
+		return c.JSON(400, map[string]string{"error": fmt.Sprintf("Quantity must be at most %d", maxLineQuantity)})
+	}
+
+	session, lines := loadCart(c)
+	i := slices.IndexFunc(lines, func(l models.CartLine) bool { return l.AlbumID == albumID })
+	if i < 0 {
+		return c.JSON(404, map[string]string{"error": "Album is not in the cart"})
+	}
+	if *body.Quantity == 0 {
+		lines = slices.Delete(lines, i, i+1)
+	} else {
+		lines[i].Quantity = *body.Quantity
+	}
+
+	if err := saveCart(c, session, lines); err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to save session"})
+	}
+	return h.respondCart(c, 200, lines)
+}
+
+// RemoveCartItem removes an album from the cart.
+func (h *Handler) RemoveCartItem(c echo.Context) error {
+	albumID, ok := parseCartAlbumID(c)
+	if !ok {
+		return nil
+	}
+
+	session, lines := loadCart(c)
+	i := slices.IndexFunc(lines, func(l models.CartLine) bool { return l.AlbumID == albumID })
+	if i < 0 {
+		return c.JSON(404, map[string]string{"error": "Album is not in the cart"})
+	}
+	lines = slices.Delete(lines, i, i+1)
+
+	if err := saveCart(c, session, lines); err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to save session"})
+	}
+	return h.respondCart(c, 200, lines)
+}
+
+// Checkout turns the cart into one order for the logged-in user.
+// Stock for all lines is taken in a single transaction; when any line can't be filled
+// nothing is ordered, the cart is kept, and the response lists each failing line.
+func (h *Handler) Checkout(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+
+	session, lines := loadCart(c)
+	if len(lines) == 0 {
+		return c.JSON(400, map[string]string{"error": "Cart is empty"})
+	}
+
+	custID, err := h.customerID(c, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return profileRequired(c)
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	id, err := h.Orders.Checkout(c.Request().Context(), custID, lines)
+	var stockErr *data.StockError
+	switch {
+	case errors.As(err, &stockErr):
+		failed := make([]map[string]any, len(stockErr.Lines))
+		for i, l := range stockErr.Lines {
+			failed[i] = map[string]any{
+				"album_id":  l.AlbumID,
+				"requested": l.Requested,
+				"available": l.Available,
+				"error":     l.Error(),
+			}
+		}
+		return c.JSON(409, map[string]any{
+			"error": "Some items can't be ordered",
+			"lines": failed,
+		})
+	case err != nil:
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	if err := saveCart(c, session, nil); err != nil {
+		log.Printf("order %d placed but the cart could not be cleared: %v", id, err)
+	}
+	_ = data.OrderCache.Delete(ordersCacheKey(userID))
+
+	return c.JSON(201, map[string]any{
+		"order_id": id,
+		"message":  "Order created successfully",
+	})
+}
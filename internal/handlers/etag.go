@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Optimistic concurrency for versioned resources (albums, books):
+// GET sends ETag "v<version>" and answers If-None-Match with 304; writes honour If-Match
+// and fail with 412 Precondition Failed when the client's copy is stale.
+
+// etagFor formats a resource version as a strong entity tag.
+func etagFor(version int64) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// ifMatchVersion parses the If-Match header into the version the client expects.
+// It returns 0 when there is no header or it is "*" (no version precondition),
+// and ok=false when the header can't name a current version (weak tags, lists, garbage).
+func ifMatchVersion(c echo.Context) (version int64, ok bool) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	// If-Match uses strong comparison, so weak tags never match.
+	tag, found := strings.CutPrefix(header, `"v`)
+	if !found || !strings.HasSuffix(tag, `"`) {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(strings.TrimSuffix(tag, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified reports whether If-None-Match already names etag (weak comparison).
+func notModified(c echo.Context, etag string) bool {
+	header := c.Request().Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed writes the 412 response used by all versioned writes.
+func preconditionFailed(c echo.Context, key string) error {
+	return c.JSON(http.StatusPreconditionFailed, map[string]string{
+		key: "resource was modified by someone else; reload it and retry with the new ETag",
+	})
+}
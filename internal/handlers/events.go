@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+)
+
+// Keepalive timing of /ws/events.
+const (
+	eventsWriteWait  = 10 * time.Second
+	eventsPongWait   = 60 * time.Second
+	eventsPingPeriod = eventsPongWait * 9 / 10
+)
+
+// eventFrame is a frame of /ws/events. The client sends subscribe and unsubscribe
+// requests naming a topic; the server answers each with subscribed, unsubscribed
+// or error, and sends event frames for the subscribed topics.
+type eventFrame struct {
+	Type  string    `json:"type"`
+	Topic string    `json:"topic,omitempty"`
+	Time  time.Time `json:"time,omitzero"`
+	Data  any       `json:"data,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// EventsSocket handles GET /ws/events (logged-in users), streaming bus events of the
+// topics the client subscribes to, initially those in ?topics=a,b. Order events are
+// only sent to the customer who placed the order.
+func (h *Handler) EventsSocket(c echo.Context) error {
+	id, ok := middleware.IdentityFrom(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	custID, err := h.eventsCustomer(c, id.UserID)
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	conn, err := sameOriginUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return nil // Upgrade has already written the error response
+	}
+	defer conn.Close()
+
+	sub := h.Events.Subscribe(custID)
+	defer sub.Close()
+
+	subscribe := func(f eventFrame) eventFrame {
+		switch {
+		case f.Type != "subscribe" && f.Type != "unsubscribe":
+			return eventFrame{Type: "error", Error: "expected a JSON message of type subscribe or unsubscribe"}
+		case !slices.Contains(events.Topics, f.Topic):
+			return eventFrame{Type: "error", Topic: f.Topic, Error: "unknown topic"}
+		case f.Type == "unsubscribe":
+			sub.Remove(f.Topic)
+			return eventFrame{Type: "unsubscribed", Topic: f.Topic}
+		}
+		if f.Topic == events.TopicOrderCreated && custID == 0 {
+			// The profile may have been created since the connection opened.
+			found, err := h.eventsCustomer(c, id.UserID)
+			if err != nil || found == 0 {
+				return eventFrame{Type: "error", Topic: f.Topic, Error: "no customer profile for this account; create one with POST /profile first"}
+			}
+			custID = found
+			sub.SetCustomer(custID)
+		}
+		sub.Add(f.Topic)
+		return eventFrame{Type: "subscribed", Topic: f.Topic}
+	}
+
+	// Only this goroutine writes to conn; the reader hands it the replies to requests.
+	conn.SetWriteDeadline(time.Now().Add(eventsWriteWait))
+	for _, topic := range strings.Split(c.QueryParam("topics"), ",") {
+		if topic == "" {
+			continue
+		}
+		if err := conn.WriteJSON(subscribe(eventFrame{Type: "subscribe", Topic: topic})); err != nil {
+			return nil
+		}
+	}
+
+	replies := make(chan eventFrame)
+	done := make(chan struct{})   // closed when the reader stops
+	closed := make(chan struct{}) // closed when the writer stops
+	defer close(closed)
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(1024)
+		conn.SetReadDeadline(time.Now().Add(eventsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(eventsPongWait))
+		})
+		for {
+			_, frame, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(eventsPongWait))
+			var f eventFrame
+			if err := json.Unmarshal(frame, &f); err != nil {
+				f = eventFrame{} // answered with an error like an unknown type
+			}
+			select {
+			case replies <- subscribe(f):
+			case <-closed:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(eventsPingPeriod)
+	defer ping.Stop()
+	for {
+		var frame any
+		select {
+		case e, ok := <-sub.C:
+			if !ok { // dropped for falling behind
+				conn.SetWriteDeadline(time.Now().Add(eventsWriteWait))
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"))
+				return nil
+			}
+			frame = eventFrame{Type: "event", Topic: e.Topic, Time: e.Time, Data: e.Data}
+		case f := <-replies:
+			frame = f
+		case <-ping.C:
+		case <-done:
+			return nil
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(eventsWriteWait))
+		var err error
+		if frame == nil {
+			err = conn.WriteMessage(websocket.PingMessage, nil)
+		} else {
+			err = conn.WriteJSON(frame)
+		}
+		if err != nil {
+			log.Printf("events: %s: %v", id.Username, err)
+			return nil
+		}
+	}
+}
+
+// eventsCustomer returns the customer ID of userID, or 0 if the user has no profile.
+func (h *Handler) eventsCustomer(c echo.Context, userID int64) (int64, error) {
+	custID, err := h.customerID(c, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return 0, nil
+	}
+	return custID, err
+}
@@ -15,6 +15,11 @@ func Form(c echo.Context) error {
 	// Load form.html from embedded templates
 	tmpl := template.Must(template.ParseFS(assets.Templates, "templates/form.html"))
 
+	token, err := csrfToken(c)
+	if err != nil {
+		return err
+	}
+
 	if c.Request().Method == http.MethodPost {
 		// Parse submitted form fields
 		if err := c.Request().ParseForm(); err != nil {
@@ -33,11 +38,13 @@ func Form(c echo.Context) error {
 			Email:   email,
 			Subject: subject,
 			Message: message,
+
+			CSRFToken: token,
 		}
 
 		return tmpl.Execute(c.Response(), data)
 	}
 
 	// Render empty form on GET
-	return tmpl.Execute(c.Response(), nil)
+	return tmpl.Execute(c.Response(), models.FormResponse{CSRFToken: token})
 }
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"github.com/labstack/echo/v4"
-	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
 )
 
 // Number is a generic constraint for numeric types (~ allows custom types with same underlying type)
@@ -11,8 +10,11 @@ type Number interface {
 }
 
 // GetTotalBookPrice calculates and returns the total price of all books as JSON.
-func GetTotalBookPrice(c echo.Context) error {
-	books := data.GetAllBooks()
+func (h *Handler) GetTotalBookPrice(c echo.Context) error {
+	books, err := h.Books.All(c.Request().Context())
+	if err != nil {
+		return c.JSON(500, map[string]string{"message": err.Error()})
+	}
 
 	// Build a map of ID -> Price
 	priceMap := make(map[int]float64)
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/chat"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/events"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/loginguard"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/mail"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/token"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/wikiindex"
+)
+
+// Handler holds the repositories used by the DB-backed handlers (albums, orders, users, customers, books).
+// Build it with SQL repositories in main and with in-memory ones in tests.
+type Handler struct {
+	Albums    data.AlbumRepository
+	Orders    data.OrderRepository
+	Users     data.UserRepository
+	Customers data.CustomerRepository
+	Books     data.BookRepository
+	Tokens    *token.Service // bearer tokens for /auth/*
+	Sessions  data.SessionRepository
+	Guard     *loginguard.Guard // throttles password and 2FA code guessing, and keeps the audit trail
+	TwoFactor data.TwoFactorRepository
+
+	// Self-service account flows (/register, /verify-email, /forgot-password, /reset-password).
+	UserTokens data.UserTokenRepository
+	Mailer     mail.Mailer
+	BaseURL    string // prefix of links in emails, e.g. https://example.com
+
+	// Wiki pages (/view, /edit, /history, ...) and their page list, search and backlinks.
+	Pages     data.PageStore
+	WikiIndex *wikiindex.Index // kept up to date by savePage
+
+	Chat   *chat.Hub   // rooms of /ws/chat
+	Events *events.Bus // what /ws/events streams; the repositories publish to it
+}
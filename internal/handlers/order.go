@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+)
+
+// ordersCacheKey is the bigcache key holding a user's last 10 orders.
+func ordersCacheKey(userID int64) string {
+	return fmt.Sprintf("orders:user:%d:last10", userID)
+}
+
+// currentUserID returns the ID of the user the authentication middleware identified.
+func currentUserID(c echo.Context) (int64, bool) {
+	id, ok := middleware.IdentityFrom(c)
+	return id.UserID, ok
+}
+
+// customerID resolves the customer record of the logged-in user.
+// Orders reference customer(id), which is not the users(id) kept in the session.
+func (h *Handler) customerID(c echo.Context, userID int64) (int64, error) {
+	cust, err := h.Customers.ByUser(c.Request().Context(), userID)
+	return cust.ID, err
+}
+
+// profileRequired is the response for ordering without a customer profile.
+func profileRequired(c echo.Context) error {
+	return c.JSON(409, map[string]string{"error": "No customer profile for this account; create one with POST /profile first"})
+}
+
+// parseOrderID reads the :id path parameter, writing a 400 response when it is invalid.
+func parseOrderID(c echo.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		_ = c.JSON(400, map[string]string{"error": "Invalid order ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetOrderByID returns one of the logged-in user's orders.
+// Other users' orders are reported as not found so their IDs aren't revealed.
+func (h *Handler) GetOrderByID(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	id, ok := parseOrderID(c)
+	if !ok {
+		return nil
+	}
+
+	custID, err := h.customerID(c, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(404, map[string]string{"error": "Order not found"})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	order, err := h.Orders.ByID(c.Request().Context(), id)
+	if errors.Is(err, data.ErrNotFound) || (err == nil && order.Customer != custID) {
+		return c.JSON(404, map[string]string{"error": "Order not found"})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, order)
+}
+
+// CancelOrder cancels a pending or paid order of the logged-in user and restocks the album.
+func (h *Handler) CancelOrder(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	id, ok := parseOrderID(c)
+	if !ok {
+		return nil
+	}
+
+	custID, err := h.customerID(c, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(404, map[string]string{"error": "Order not found"})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	order, err := h.Orders.Cancel(c.Request().Context(), id, custID)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return c.JSON(404, map[string]string{"error": "Order not found"})
+	case errors.Is(err, data.ErrOrderNotCancellable):
+		return c.JSON(409, map[string]string{"error": fmt.Sprintf("Order is %s and can no longer be cancelled", order.Status)})
+	case err != nil:
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	// The cached list still shows the old status; drop it rather than patching it.
+	_ = data.OrderCache.Delete(ordersCacheKey(userID))
+	log.Printf("[CACHE INVALIDATE] User %d cache cleared after cancelling order %d", userID, id)
+
+	return c.JSON(200, map[string]any{
+		"order":   order,
+		"message": "Order cancelled",
+	})
+}
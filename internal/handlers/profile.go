@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// bindProfile reads and validates a customer profile body, writing a 400 response when it is invalid.
+func bindProfile(c echo.Context) (models.Customer, bool) {
+	var cust models.Customer
+	if err := c.Bind(&cust); err != nil {
+		_ = c.JSON(400, map[string]string{"error": "Invalid JSON"})
+		return cust, false
+	}
+	cust.FullName = strings.TrimSpace(cust.FullName)
+	cust.Address = strings.TrimSpace(cust.Address)
+	cust.Phone = strings.TrimSpace(cust.Phone)
+
+	var msg string
+	switch {
+	case cust.FullName == "" || cust.Address == "" || cust.Phone == "":
+		msg = "fullName, address and phone are required"
+	case len(cust.FullName) > 255:
+		msg = "fullName must be 1-255 characters"
+	case len(cust.Address) > 255:
+		msg = "address must be 1-255 characters"
+	case len(cust.Phone) > 20:
+		msg = "phone must be 1-20 characters"
+	}
+	if msg != "" {
+		_ = c.JSON(400, map[string]string{"error": msg})
+		return cust, false
+	}
+	return cust, true
+}
+
+// GetProfile returns the customer profile linked to the logged-in user.
+func (h *Handler) GetProfile(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+
+	cust, err := h.Customers.ByUser(c.Request().Context(), userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(404, map[string]string{"error": "No customer profile for this account; create one with POST /profile"})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, cust)
+}
+
+// CreateProfile creates the customer profile for the logged-in user (409 if one exists).
+func (h *Handler) CreateProfile(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	cust, ok := bindProfile(c)
+	if !ok {
+		return nil
+	}
+
+	cust, err := h.Customers.CreateForUser(c.Request().Context(), userID, cust)
+	if errors.Is(err, data.ErrProfileExists) {
+		return c.JSON(409, map[string]string{"error": "Customer profile already exists; use PUT /profile to edit it"})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(201, cust)
+}
+
+// UpdateProfile replaces name, address and phone of the logged-in user's customer profile.
+func (h *Handler) UpdateProfile(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	cust, ok := bindProfile(c)
+	if !ok {
+		return nil
+	}
+
+	cust, err := h.Customers.UpdateForUser(c.Request().Context(), userID, cust)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(404, map[string]string{"error": "No customer profile for this account; create one with POST /profile"})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, cust)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/sessionstore"
+)
+
+// currentSession returns the request's stored session and its public ID.
+// Bearer-token requests have none (ok is false).
+func currentSession(c echo.Context) (*sessions.Session, string, bool) {
+	session, err := store.Get(c.Request(), "session")
+	if err != nil || session.IsNew || session.ID == "" {
+		return nil, "", false
+	}
+	return session, sessionstore.PublicID(session.ID), true
+}
+
+// endSession deletes the current session and clears its cookie.
+func endSession(c echo.Context, session *sessions.Session) error {
+	session.Options.MaxAge = -1
+	return session.Save(c.Request(), c.Response())
+}
+
+// GetSessions lists the logged-in user's sessions, marking the one making the request.
+func (h *Handler) GetSessions(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+
+	list, err := h.Sessions.ByUser(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	if list == nil {
+		list = []models.Session{}
+	}
+	if _, current, ok := currentSession(c); ok {
+		for i := range list {
+			list[i].Current = list[i].ID == current
+		}
+	}
+	return c.JSON(200, list)
+}
+
+// RevokeSession logs out one of the logged-in user's sessions, e.g. a lost device.
+// Other users' sessions are reported as not found.
+func (h *Handler) RevokeSession(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	id := c.Param("id")
+
+	err := h.Sessions.DeleteForUser(c.Request().Context(), userID, id)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(404, map[string]string{"error": "Session not found"})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	if session, current, ok := currentSession(c); ok && current == id {
+		if err := endSession(c, session); err != nil {
+			return c.JSON(500, map[string]string{"error": "Failed to clear session"})
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// RevokeAllSessions logs out every session of the logged-in user, including this one.
+func (h *Handler) RevokeAllSessions(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+
+	n, err := h.Sessions.DeleteAllForUser(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	if session, _, ok := currentSession(c); ok {
+		if err := endSession(c, session); err != nil {
+			return c.JSON(500, map[string]string{"error": "Failed to clear session"})
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "All sessions revoked",
+		"revoked": n,
+	})
+}
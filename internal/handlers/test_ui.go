@@ -12,6 +12,12 @@ import (
 func TestUI(c echo.Context) error {
 	tmpl := template.Must(template.ParseFS(assets.Templates, "templates/test_ui.html"))
 
+	// The page's fetch() calls send this back in the X-CSRF-Token header.
+	token, err := csrfToken(c)
+	if err != nil {
+		return err
+	}
+
 	// Render template to response
-	return tmpl.ExecuteTemplate(c.Response().Writer, "test_ui.html", nil)
+	return tmpl.ExecuteTemplate(c.Response().Writer, "test_ui.html", map[string]string{"CSRFToken": token})
 }
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/token"
+)
+
+// IssueToken exchanges a username and password (JSON or form) for an access/refresh token pair.
+// It is the bearer-token counterpart of Login for clients that can't keep the session cookie.
+// Accounts with 2FA on must also send "otp": a TOTP or recovery code.
+func (h *Handler) IssueToken(c echo.Context) error {
+	var req struct {
+		Username string `json:"username" form:"username"`
+		Password string `json:"password" form:"password"`
+		OTP      string `json:"otp" form:"otp"`
+	}
+	if err := c.Bind(&req); err != nil || req.Username == "" || req.Password == "" {
+		return c.JSON(400, map[string]string{"error": "username and password are required"})
+	}
+
+	userID, wait, err := h.checkCredentials(c, req.Username, req.Password)
+	if errors.Is(err, errSecondFactor) {
+		if req.OTP == "" {
+			return c.JSON(401, map[string]any{"error": "Two-factor code required", "twoFactorRequired": true})
+		}
+		wait, err = h.checkSecondFactor(c, req.Username, userID, req.OTP)
+		if errors.Is(err, errBadCredentials) {
+			return c.JSON(401, map[string]string{"error": "Invalid two-factor code"})
+		}
+		if err == nil && wait == 0 {
+			err = h.Guard.Succeed(c.Request().Context(), req.Username, c.RealIP())
+		}
+	}
+	switch {
+	case wait > 0:
+		secs := retryAfter(c, wait)
+		return c.JSON(429, map[string]any{"error": "Too many failed login attempts; try again later", "retryAfter": secs})
+	case errors.Is(err, errBadCredentials):
+		return c.JSON(401, map[string]string{"error": "Invalid username or password"})
+	case err != nil:
+		return c.JSON(500, map[string]string{"error": "Failed to verify credentials"})
+	}
+
+	pair, err := h.Tokens.Issue(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(200, pair)
+}
+
+// RefreshToken exchanges a refresh token for a new pair. Each refresh token works once;
+// presenting a used one revokes every token of its grant.
+func (h *Handler) RefreshToken(c echo.Context) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	}
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(400, map[string]string{"error": "refresh_token is required"})
+	}
+
+	pair, err := h.Tokens.Refresh(c.Request().Context(), req.RefreshToken)
+	if errors.Is(err, token.ErrInvalidToken) {
+		return c.JSON(401, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(200, pair)
+}
+
+// RevokeToken revokes the grant behind an access or refresh token. With "all": true it
+// revokes every grant of the token's user (or of the caller, when no token is given).
+// Unknown or already revoked tokens are not an error, so the call is safe to retry.
+func (h *Handler) RevokeToken(c echo.Context) error {
+	var req struct {
+		Token string `json:"token" form:"token"`
+		All   bool   `json:"all" form:"all"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid JSON"})
+	}
+
+	ctx := c.Request().Context()
+	var userID int64
+	switch {
+	case req.Token != "":
+		id, err := h.Tokens.Revoke(ctx, req.Token)
+		if errors.Is(err, token.ErrInvalidToken) {
+			return c.NoContent(http.StatusNoContent)
+		}
+		if err != nil {
+			return c.JSON(500, map[string]string{"error": err.Error()})
+		}
+		userID = id
+	case req.All:
+		id, ok := currentUserID(c)
+		if !ok {
+			return middleware.Unauthenticated(c)
+		}
+		userID = id
+	default:
+		return c.JSON(400, map[string]string{"error": `token or "all": true is required`})
+	}
+
+	if req.All {
+		if err := h.Tokens.RevokeAll(ctx, userID); err != nil {
+			return c.JSON(500, map[string]string{"error": err.Error()})
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
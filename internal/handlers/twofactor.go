@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+	qrcode "github.com/skip2/go-qrcode"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/totp"
+)
+
+// totpIssuer names the account in authenticator apps.
+const totpIssuer = "Go JumpStart Echo"
+
+// recoveryCodeCount is how many recovery codes are issued at a time.
+const recoveryCodeCount = 10
+
+// Session keys of a login waiting for its second factor. The session is not
+// authenticated until LoginTwoFactor checks the code.
+const (
+	pendingUserKey      = "2fa_user_id"
+	pendingUsernameKey  = "2fa_username"
+	pendingExpiresKey   = "2fa_expires"
+	pendingRedirectKey  = "2fa_redirect"
+	secondFactorTimeout = 5 * time.Minute
+)
+
+// verifySecondFactor checks a TOTP code or an unused recovery code of userID and
+// uses it up. It reports false for wrong or replayed codes and users without 2FA.
+func (h *Handler) verifySecondFactor(ctx context.Context, userID int64, code string) (bool, error) {
+	tf, err := h.TwoFactor.ByUser(ctx, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil || !tf.Enabled() {
+		return false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(tf.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		err = h.TwoFactor.UseStep(ctx, userID, step)
+	} else {
+		err = h.TwoFactor.UseRecoveryCode(ctx, userID, totp.HashRecoveryCode(code))
+	}
+	if errors.Is(err, data.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// pendingLogin returns the login waiting for a code in session, if it hasn't timed out.
+func pendingLogin(session *sessions.Session) (userID int64, username string, ok bool) {
+	userID, ok = session.Values[pendingUserKey].(int64)
+	username, _ = session.Values[pendingUsernameKey].(string)
+	expires, _ := session.Values[pendingExpiresKey].(int64)
+	if !ok || time.Now().Unix() > expires {
+		return 0, "", false
+	}
+	return userID, username, true
+}
+
+// clearPendingLogin removes the pending login from session.
+func clearPendingLogin(session *sessions.Session) {
+	for _, k := range []string{pendingUserKey, pendingUsernameKey, pendingExpiresKey, pendingRedirectKey} {
+		delete(session.Values, k)
+	}
+}
+
+// LoginTwoFactorForm asks for the code of a login whose password was accepted.
+func LoginTwoFactorForm(c echo.Context) error {
+	session, _ := store.Get(c.Request(), "session")
+	if _, _, ok := pendingLogin(session); !ok {
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+	return renderAccountForm(c, http.StatusOK, "two_factor_login.html", accountPage{})
+}
+
+// LoginTwoFactor completes a pending login with a TOTP or recovery code.
+func (h *Handler) LoginTwoFactor(c echo.Context) error {
+	session, _ := store.Get(c.Request(), "session")
+	userID, username, ok := pendingLogin(session)
+	if !ok {
+		clearPendingLogin(session)
+		if err := session.Save(c.Request(), c.Response()); err != nil {
+			return c.String(http.StatusInternalServerError, "Failed to save session")
+		}
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	wait, err := h.checkSecondFactor(c, username, userID, c.FormValue("code"))
+	switch {
+	case wait > 0:
+		return tooManyAttempts(c, wait)
+	case errors.Is(err, errBadCredentials):
+		return renderAccountForm(c, http.StatusUnauthorized, "two_factor_login.html", accountPage{Error: "Invalid code"})
+	case err != nil:
+		return c.String(http.StatusInternalServerError, "Failed to verify code")
+	}
+	if err := h.Guard.Succeed(c.Request().Context(), username, c.RealIP()); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to record login")
+	}
+
+	redirectPath, _ := session.Values[pendingRedirectKey].(string)
+	if redirectPath == "" {
+		redirectPath = "/dashboard"
+	}
+	clearPendingLogin(session)
+	if err := store.Renew(c.Request(), session); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to renew session")
+	}
+	session.Values["authenticated"] = true
+	session.Values["user_id"] = userID
+	if err := session.Save(c.Request(), c.Response()); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to save session")
+	}
+	return c.Redirect(http.StatusSeeOther, redirectPath)
+}
+
+// twoFactorPage is the data of two_factor.html.
+type twoFactorPage struct {
+	CSRFToken     string
+	Error         string
+	Enabled       bool
+	EnabledAt     time.Time
+	CodesLeft     int
+	Pending       bool     // enrollment started; show the QR code and confirm form
+	Secret        string   // for manual entry while Pending
+	RecoveryCodes []string // shown once, right after they are generated
+}
+
+// renderTwoFactor renders the 2FA settings page of userID; page may carry an error or new recovery codes.
+func (h *Handler) renderTwoFactor(c echo.Context, status int, userID int64, page twoFactorPage) error {
+	ctx := c.Request().Context()
+	tf, err := h.TwoFactor.ByUser(ctx, userID)
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		return c.String(http.StatusInternalServerError, "Failed to load 2FA settings")
+	}
+	switch {
+	case tf.Enabled():
+		page.Enabled, page.EnabledAt = true, *tf.EnabledAt
+		if page.CodesLeft, err = h.TwoFactor.RecoveryCodesLeft(ctx, userID); err != nil {
+			return c.String(http.StatusInternalServerError, "Failed to load 2FA settings")
+		}
+	case tf != nil:
+		page.Pending, page.Secret = true, tf.Secret
+	}
+
+	if page.CSRFToken, err = csrfToken(c); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to save session")
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return renderAccount(c, status, "two_factor.html", page)
+}
+
+// newRecoveryCodes returns fresh recovery codes and their hashes.
+func newRecoveryCodes() (codes, hashes []string) {
+	codes = totp.NewRecoveryCodes(recoveryCodeCount)
+	hashes = make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	return codes, hashes
+}
+
+// GetTwoFactor shows the caller's 2FA status and enrollment steps.
+func (h *Handler) GetTwoFactor(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	return h.renderTwoFactor(c, http.StatusOK, userID, twoFactorPage{})
+}
+
+// SetupTwoFactor starts an enrollment with a new secret, replacing an unconfirmed one.
+func (h *Handler) SetupTwoFactor(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	err := h.TwoFactor.Begin(c.Request().Context(), userID, totp.NewSecret())
+	if errors.Is(err, data.ErrTwoFactorEnabled) {
+		return h.renderTwoFactor(c, http.StatusConflict, userID, twoFactorPage{Error: "Two-factor authentication is already on"})
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to start 2FA setup")
+	}
+	return c.Redirect(http.StatusSeeOther, "/me/2fa")
+}
+
+// TwoFactorQR returns the QR code of the caller's unconfirmed enrollment as a PNG.
+func (h *Handler) TwoFactorQR(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	ctx := c.Request().Context()
+	tf, err := h.TwoFactor.ByUser(ctx, userID)
+	if errors.Is(err, data.ErrNotFound) || (err == nil && tf.Enabled()) {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	user, err := h.Users.ByID(ctx, int(userID))
+	if err != nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	png, err := qrcode.Encode(totp.URI(totpIssuer, user.Username, tf.Secret), qrcode.Medium, 256)
+	if err != nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, "image/png", png)
+}
+
+// EnableTwoFactor confirms the enrollment with a code from the app and shows the recovery codes.
+func (h *Handler) EnableTwoFactor(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	ctx := c.Request().Context()
+	tf, err := h.TwoFactor.ByUser(ctx, userID)
+	if errors.Is(err, data.ErrNotFound) || (err == nil && tf.Enabled()) {
+		return c.Redirect(http.StatusSeeOther, "/me/2fa")
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to load 2FA settings")
+	}
+
+	step, ok := totp.Validate(tf.Secret, c.FormValue("code"), time.Now())
+	if !ok {
+		return h.renderTwoFactor(c, http.StatusBadRequest, userID, twoFactorPage{Error: "That code didn't match; check the app and try again"})
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := h.TwoFactor.Enable(ctx, userID, step, hashes); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to enable 2FA")
+	}
+	return h.renderTwoFactor(c, http.StatusOK, userID, twoFactorPage{RecoveryCodes: codes})
+}
+
+// confirmSecondFactor checks a code the caller entered to change their 2FA settings,
+// throttled like a login. It writes the error response itself when ok is false.
+func (h *Handler) confirmSecondFactor(c echo.Context, userID int64) (ok bool, err error) {
+	user, err := h.Users.ByID(c.Request().Context(), int(userID))
+	if err != nil {
+		return false, c.String(http.StatusInternalServerError, "Failed to load user")
+	}
+	wait, err := h.checkSecondFactor(c, user.Username, userID, c.FormValue("code"))
+	switch {
+	case wait > 0:
+		return false, tooManyAttempts(c, wait)
+	case errors.Is(err, errBadCredentials):
+		return false, h.renderTwoFactor(c, http.StatusBadRequest, userID, twoFactorPage{Error: "Invalid code"})
+	case err != nil:
+		return false, c.String(http.StatusInternalServerError, "Failed to verify code")
+	}
+	return true, nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking a current code.
+func (h *Handler) RegenerateRecoveryCodes(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	if ok, err := h.confirmSecondFactor(c, userID); !ok {
+		return err
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := h.TwoFactor.ReplaceRecoveryCodes(c.Request().Context(), userID, hashes); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to create recovery codes")
+	}
+	return h.renderTwoFactor(c, http.StatusOK, userID, twoFactorPage{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns the caller's 2FA off after checking a current code.
+func (h *Handler) DisableTwoFactor(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return middleware.Unauthenticated(c)
+	}
+	if ok, err := h.confirmSecondFactor(c, userID); !ok {
+		return err
+	}
+	if err := h.TwoFactor.Delete(c.Request().Context(), userID); err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to disable 2FA")
+	}
+	return c.Redirect(http.StatusSeeOther, "/me/2fa")
+}
+
+// ResetTwoFactor turns off 2FA for a user who lost their device and recovery codes (admin only).
+func (h *Handler) ResetTwoFactor(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	user, err := h.Users.ByID(c.Request().Context(), id)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error fetching user"})
+	}
+
+	if err := h.TwoFactor.Delete(c.Request().Context(), int64(id)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error resetting 2FA"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"status":  "success",
+		"message": "Two-factor authentication turned off for " + user.Username,
+	})
+}
@@ -1,35 +1,45 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/loginguard"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/passhash"
 )
 
 // UserResponse defines the JSON output for API clients (hides password)
 type UserResponse struct {
-	ID        int    `json:"id"`
-	Username  string `json:"username"`
-	CreatedAt string `json:"created_at"`
+	ID            int    `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	Role          string `json:"role"`
+	CreatedAt     string `json:"created_at"`
 }
 
 // mapUser converts internal User model to UserResponse
 func mapUser(u models.User) UserResponse {
 	return UserResponse{
-		ID:        u.ID,
-		Username:  u.Username,
-		CreatedAt: u.CreatedAt.Format(time.RFC3339),
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		Role:          u.Role,
+		CreatedAt:     u.CreatedAt.Format(time.RFC3339),
 	}
 }
 
 // GetUsers returns a list of all users
-func GetUsers(c echo.Context) error {
-	users, err := data.GetAllUsers()
+func (h *Handler) GetUsers(c echo.Context) error {
+	users, err := h.Users.All(c.Request().Context())
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error fetching users"})
 	}
@@ -44,14 +54,14 @@ func GetUsers(c echo.Context) error {
 }
 
 // GetUserByID returns a single user by ID
-func GetUserByID(c echo.Context) error {
+func (h *Handler) GetUserByID(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
 	}
 
-	user, err := data.GetUserByID(id)
+	user, err := h.Users.ByID(c.Request().Context(), id)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
 	}
@@ -60,7 +70,7 @@ func GetUserByID(c echo.Context) error {
 }
 
 // CreateUser adds a new user to the database
-func CreateUser(c echo.Context) error {
+func (h *Handler) CreateUser(c echo.Context) error {
 	var input struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -74,22 +84,16 @@ func CreateUser(c echo.Context) error {
 	input.Password = strings.TrimSpace(input.Password)
 
 	// Validation
-	if input.Username == "" || input.Password == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Username and password are required"})
-	}
-	if len(input.Username) < 3 || len(input.Username) > 50 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Username must be between 3 and 50 characters"})
-	}
-	if len(input.Password) < 6 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Password must be at least 6 characters"})
+	if msg := validateCredentials(input.Username, input.Password); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
 	}
 
-	id, err := data.CreateUser(input.Username, input.Password)
+	id, err := h.Users.Create(c.Request().Context(), input.Username, input.Password)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error creating user"})
 	}
 
-	user, _ := data.GetUserByID(int(id))
+	user, _ := h.Users.ByID(c.Request().Context(), int(id))
 	return c.JSON(http.StatusCreated, map[string]any{
 		"status":  "success",
 		"message": "User created successfully",
@@ -97,8 +101,20 @@ func CreateUser(c echo.Context) error {
 	})
 }
 
+// validateCredentials checks a new account's username and password and returns
+// the message to show, or "" when both are acceptable.
+func validateCredentials(username, password string) string {
+	if username == "" || password == "" {
+		return "Username and password are required"
+	}
+	if len(username) < 3 || len(username) > 50 {
+		return "Username must be between 3 and 50 characters"
+	}
+	return passhash.CheckStrength(password, username)
+}
+
 // UpdateUser updates username and/or password for a given user
-func UpdateUser(c echo.Context) error {
+func (h *Handler) UpdateUser(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -122,15 +138,27 @@ func UpdateUser(c echo.Context) error {
 	if input.Username != "" && (len(input.Username) < 3 || len(input.Username) > 50) {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Username must be between 3 and 50 characters"})
 	}
-	if input.Password != "" && len(input.Password) < 6 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Password must be at least 6 characters"})
+	if input.Password != "" {
+		username := input.Username
+		if username == "" {
+			user, err := h.Users.ByID(c.Request().Context(), id)
+			if errors.Is(err, data.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+			} else if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error fetching user"})
+			}
+			username = user.Username
+		}
+		if msg := passhash.CheckStrength(input.Password, username); msg != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+		}
 	}
 
-	if err := data.UpdateUserByID(id, input.Username, input.Password); err != nil {
+	if err := h.Users.Update(c.Request().Context(), id, input.Username, input.Password); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error updating user"})
 	}
 
-	updatedUser, err := data.GetUserByID(id)
+	updatedUser, err := h.Users.ByID(c.Request().Context(), id)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error fetching updated user"})
 	}
@@ -142,14 +170,14 @@ func UpdateUser(c echo.Context) error {
 }
 
 // DeleteUser removes a user by ID
-func DeleteUser(c echo.Context) error {
+func (h *Handler) DeleteUser(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
 	}
 
-	if err := data.DeleteUserByID(id); err != nil {
+	if err := h.Users.Delete(c.Request().Context(), id); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error deleting user"})
 	}
 
@@ -158,3 +186,81 @@ func DeleteUser(c echo.Context) error {
 		"id":     id,
 	})
 }
+
+// SetUserRole changes a user's role (admin only).
+func (h *Handler) SetUserRole(c echo.Context) error {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+	if !slices.Contains([]string{models.RoleAdmin, models.RoleStaff, models.RoleCustomer}, input.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Role must be admin, staff or customer"})
+	}
+
+	if err := h.Users.SetRole(c.Request().Context(), id, input.Role); errors.Is(err, data.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error updating role"})
+	}
+
+	user, err := h.Users.ByID(c.Request().Context(), id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error fetching updated user"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"status": "success",
+		"user":   mapUser(*user),
+	})
+}
+
+// UnlockUser clears the failed-login count of a locked-out user (admin only).
+func (h *Handler) UnlockUser(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	user, err := h.Users.ByID(c.Request().Context(), id)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error fetching user"})
+	}
+
+	if err := h.Guard.Unlock(c.Request().Context(), user.Username, c.RealIP()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error unlocking user"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"status":  "success",
+		"message": "Failed login attempts cleared for " + user.Username,
+	})
+}
+
+// GetLoginAttempts returns the login audit trail, newest first, filtered by ?username= and ?ip= (admin only).
+func (h *Handler) GetLoginAttempts(c echo.Context) error {
+	q := data.LoginAttemptQuery{Username: loginguard.Normalize(c.QueryParam("username")), IP: c.QueryParam("ip")}
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > 1000 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be 1-1000"})
+		}
+		q.Limit = n
+	}
+
+	attempts, err := h.Guard.Attempts.List(c.Request().Context(), q)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error fetching login attempts"})
+	}
+	if attempts == nil {
+		attempts = []models.LoginAttempt{}
+	}
+	return c.JSON(http.StatusOK, attempts)
+}
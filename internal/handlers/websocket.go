@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"html/template"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
 )
 
 // upgrader upgrades HTTP requests to WebSocket connections
@@ -42,6 +46,31 @@ func Echo(c echo.Context) error {
 	return nil
 }
 
+// sameOriginUpgrader upgrades /ws/chat and /ws/events requests. Unlike upgrader
+// it keeps gorilla's same-origin check: these sockets act as the session's user,
+// and other sites' pages would otherwise be able to open them with its cookie.
+var sameOriginUpgrader = websocket.Upgrader{}
+
+// ChatSocket handles GET /ws/chat, a WebSocket session with the chat hub. Logged-in
+// users chat under their username, visitors as guest-xxxx.
+func (h *Handler) ChatSocket(c echo.Context) error {
+	name := ""
+	if id, ok := middleware.IdentityFrom(c); ok {
+		name = id.Username
+	} else {
+		b := make([]byte, 2)
+		_, _ = rand.Read(b)
+		name = "guest-" + hex.EncodeToString(b)
+	}
+
+	conn, err := sameOriginUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return nil // Upgrade has already written the error response
+	}
+	h.Chat.Serve(conn, name)
+	return nil
+}
+
 // WebsocketPage serves the HTML page for the WebSocket frontend
 func WebsocketPage(c echo.Context) error {
 	tmpl, err := template.ParseFiles("templates/websockets.html")
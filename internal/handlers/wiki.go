@@ -1,36 +1,50 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"html/template"
 	"net/url"
-	"os"
-	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/markup"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/wikiindex"
 )
 
 // Page represents a wiki page with a title and body
 type Page struct {
-	Title string
-	Body  []byte // Page content as raw bytes
+	Title    string
+	Body     []byte // Page content as raw bytes
+	Revision int    // set when showing an old revision; 0 for the current text
 }
 
-// save writes the Page's body to a file in data/<Title>.txt
-func (p *Page) save() error {
-	if err := os.MkdirAll("data", 0700); err != nil {
-		return err
+// wikiTitle returns the normalised :title parameter; see data.NormalizeTitle.
+func wikiTitle(c echo.Context) (string, error) {
+	title, err := url.PathUnescape(c.Param("title"))
+	if err != nil {
+		return "", data.ErrInvalidTitle
 	}
-	return os.WriteFile("data/"+p.Title+".txt", p.Body, 0600)
+	return data.NormalizeTitle(title)
 }
 
-// loadPage reads a page from disk and returns a Page struct
-func loadPage(title string) (*Page, error) {
-	body, err := os.ReadFile("data/" + title + ".txt")
+// savePage records body as a new revision of title by the request's user and
+// updates the index. It returns data.ErrVersionConflict if the page is no longer at
+// revision base.
+func (h *Handler) savePage(c echo.Context, title string, base int, body, summary string) error {
+	rev, err := h.Pages.Save(c.Request().Context(), title, base, models.WikiRevision{
+		Author:  wikiAuthor(c),
+		Summary: summary,
+		Body:    body,
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &Page{Title: title, Body: body}, nil
+	h.WikiIndex.Update(title, rev)
+	return nil
 }
 
 // wikiTemplates is loaded lazily to avoid panics during init/tests
@@ -39,77 +53,155 @@ var wikiTemplates *template.Template
 // LoadWikiTemplates parses the edit and view templates
 func LoadWikiTemplates() error {
 	var err error
-	wikiTemplates, err = template.ParseFiles("templates/edit.html", "templates/view.html")
+	wikiTemplates, err = template.ParseFiles("templates/edit.html", "templates/view.html",
+		"templates/history.html", "templates/diff.html", "templates/wiki_index.html", "templates/wiki_search.html")
 	return err
 }
 
-// validLink matches [[PageName]] style links in wiki text
-var validLink = regexp.MustCompile(`\[(.+?)\]`)
-
-// renderTemplate processes links and renders a wiki template
-func renderTemplate(c echo.Context, tmpl string, p *Page) error {
-	bodyStr := string(p.Body)
-
-	// Convert [[PageName]] into <a href="/view/PageName">PageName</a>
-	processed := validLink.ReplaceAllStringFunc(bodyStr, func(s string) string {
-		m := validLink.FindStringSubmatch(s)
-		link := m[1]
-		escaped := url.PathEscape(link)
-		return fmt.Sprintf(`<a href="/view/%s">%s</a>`, escaped, link)
+// renderTemplate renders a wiki template with the page body converted to
+// sanitised HTML (Markdown plus [[Page]] links; see package markup).
+func (h *Handler) renderTemplate(c echo.Context, tmpl string, p *Page) error {
+	ctx := c.Request().Context()
+	body, err := markup.Render(p.Body, func(title string) bool {
+		ok, err := h.Pages.Exists(ctx, title)
+		return ok && err == nil
 	})
+	if err != nil {
+		return err
+	}
+
+	settings, err := h.pageSettings(ctx, p.Title)
+	if err != nil {
+		return err
+	}
 
 	return wikiTemplates.ExecuteTemplate(c.Response(), tmpl+".html", struct {
-		Title string
-		Body  template.HTML
+		Title     string
+		Body      template.HTML
+		Revision  int
+		Backlinks []string
+		Protected bool
+		CanEdit   bool
 	}{
-		Title: p.Title,
-		Body:  template.HTML(processed),
+		Title:     p.Title,
+		Body:      body,
+		Revision:  p.Revision,
+		Backlinks: h.WikiIndex.Backlinks(p.Title),
+		Protected: settings.Protected,
+		CanEdit:   canEditPage(c, settings),
 	})
 }
 
-// ViewWiki handles GET /view/:title
-func ViewWiki(c echo.Context) error {
-	title := c.Param("title")
-	decodedTitle, _ := url.PathUnescape(title)
+// ViewWiki handles GET /view/:title; ?rev=N shows an old revision.
+func (h *Handler) ViewWiki(c echo.Context) error {
+	title, err := wikiTitle(c)
+	if err != nil {
+		return c.String(400, "Invalid page title")
+	}
 
-	p, err := loadPage(decodedTitle)
+	if rev := c.QueryParam("rev"); rev != "" {
+		return h.viewRevision(c, title, rev)
+	}
+	r, err := h.Pages.Latest(c.Request().Context(), title)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.Redirect(302, "/edit/"+url.PathEscape(title))
+	}
 	if err != nil {
-		return c.Redirect(302, "/edit/"+decodedTitle)
+		return c.String(500, err.Error())
 	}
-	if err := renderTemplate(c, "view", p); err != nil {
+	if err := h.renderTemplate(c, "view", &Page{Title: title, Body: []byte(r.Body)}); err != nil {
 		return c.String(500, err.Error())
 	}
 	return nil
 }
 
-// EditWiki handles GET /edit/:title
-func EditWiki(c echo.Context) error {
-	title := c.Param("title")
-	decodedTitle, _ := url.PathUnescape(title)
-
-	p, err := loadPage(decodedTitle)
+// viewRevision renders revision rev of title.
+func (h *Handler) viewRevision(c echo.Context, title, rev string) error {
+	id, err := strconv.Atoi(rev)
 	if err != nil {
-		p = &Page{Title: decodedTitle} // new empty page
+		return c.String(400, "Invalid revision")
 	}
-
-	tmpl := template.Must(template.ParseFiles("templates/edit.html"))
-	if err := tmpl.Execute(c.Response(), p); err != nil {
+	revs, err := h.Pages.History(c.Request().Context(), title)
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		return c.String(500, err.Error())
+	}
+	r, ok := findRevision(revs, id)
+	if !ok {
+		return c.String(404, "No such revision")
+	}
+	if err := h.renderTemplate(c, "view", &Page{Title: title, Body: []byte(r.Body), Revision: r.ID}); err != nil {
 		return c.String(500, err.Error())
 	}
 	return nil
 }
 
-// SaveWiki handles POST /save/:title
-func SaveWiki(c echo.Context) error {
-	title := c.Param("title")
-	decodedTitle, _ := url.PathUnescape(title)
+// EditWiki handles GET /edit/:title
+func (h *Handler) EditWiki(c echo.Context) error {
+	title, err := wikiTitle(c)
+	if err != nil {
+		return c.String(400, "Invalid page title")
+	}
+	if ok, err := h.checkEditable(c, title); !ok {
+		return err
+	}
+
+	p := editPage{Title: title} // new empty page
+	r, err := h.Pages.Latest(c.Request().Context(), title)
+	if err == nil {
+		p.Body, p.Base = r.Body, r.ID
+	} else if !errors.Is(err, data.ErrNotFound) {
+		return c.String(500, err.Error())
+	}
+	return h.renderEdit(c, 200, p)
+}
 
-	body := c.FormValue("body")
-	p := &Page{Title: decodedTitle, Body: []byte(body)}
+// SaveWiki handles POST /save/:title. The form's base revision must still be the
+// current one; otherwise the save is rejected with the merge view.
+func (h *Handler) SaveWiki(c echo.Context) error {
+	title, err := wikiTitle(c)
+	if err != nil {
+		return c.String(400, "Invalid page title")
+	}
+	if ok, err := h.checkEditable(c, title); !ok {
+		return err
+	}
+	base, err := strconv.Atoi(c.FormValue("base"))
+	if err != nil || base < 0 {
+		return c.String(400, "Invalid base revision")
+	}
 
-	if err := p.save(); err != nil {
+	body, summary := c.FormValue("body"), editSummary(c)
+	err = h.savePage(c, title, base, body, summary)
+	if errors.Is(err, data.ErrVersionConflict) {
+		return h.mergeView(c, title, base, body, summary)
+	}
+	if err != nil {
 		return c.String(500, err.Error())
 	}
 
-	return c.Redirect(302, "/view/"+decodedTitle)
+	return c.Redirect(302, "/view/"+url.PathEscape(title))
+}
+
+// maxSearchResults caps the results shown by SearchWiki.
+const maxSearchResults = 50
+
+// IndexWiki handles GET /wiki/index, listing every page with when it last changed.
+func (h *Handler) IndexWiki(c echo.Context) error {
+	return wikiTemplates.ExecuteTemplate(c.Response(), "wiki_index.html", struct {
+		Pages []wikiindex.Entry
+	}{h.WikiIndex.Pages()})
+}
+
+// SearchWiki handles GET /wiki/search?q=, showing the best matching pages with
+// snippets of their text.
+func (h *Handler) SearchWiki(c echo.Context) error {
+	q := strings.TrimSpace(c.QueryParam("q"))
+	var results []wikiindex.Result
+	if q != "" {
+		results = h.WikiIndex.Search(q, maxSearchResults)
+	}
+	return wikiTemplates.ExecuteTemplate(c.Response(), "wiki_search.html", struct {
+		Query   string
+		Results []wikiindex.Result
+	}{q, results})
 }
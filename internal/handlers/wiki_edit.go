@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/diff"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// editPage is the data of edit.html.
+type editPage struct {
+	Title     string
+	Body      string
+	Base      int // revision the edit starts from; 0 for a new page
+	Summary   string
+	CSRFToken string
+	Settings  models.WikiPageSettings
+	CanManage bool          // show the settings form (admins, existing pages)
+	Conflict  *editConflict // set when a save was rejected
+}
+
+// editConflict explains a save rejected because the page changed during the edit.
+type editConflict struct {
+	BaseID    int                 // revision the editor started from
+	Theirs    models.WikiRevision // the current revision
+	Rows      []diffRow           // what changed between the two
+	Conflicts int                 // places in Body marked for manual merging
+}
+
+// pageSettings returns a page's settings; a page that doesn't exist yet has the defaults.
+func (h *Handler) pageSettings(ctx context.Context, title string) (models.WikiPageSettings, error) {
+	s, err := h.Pages.Settings(ctx, title)
+	if errors.Is(err, data.ErrNotFound) {
+		return s, nil
+	}
+	return s, err
+}
+
+// canEditPage reports whether the request's user may edit a page with settings s:
+// admins only if it is protected, otherwise any logged-in user, and visitors too
+// if it allows anonymous edits.
+func canEditPage(c echo.Context, s models.WikiPageSettings) bool {
+	id, ok := middleware.IdentityFrom(c)
+	switch {
+	case s.Protected:
+		return ok && id.HasRole(models.RoleAdmin)
+	case !ok:
+		return s.AnonymousEdits
+	}
+	return true
+}
+
+// isAdmin reports whether the request's user is an admin.
+func isAdmin(c echo.Context) bool {
+	id, ok := middleware.IdentityFrom(c)
+	return ok && id.HasRole(models.RoleAdmin)
+}
+
+// checkEditable reports whether the request's user may edit title. If not, it has
+// written the response (the login page for visitors, 403 for everyone else) and
+// returns its error.
+func (h *Handler) checkEditable(c echo.Context, title string) (bool, error) {
+	s, err := h.pageSettings(c.Request().Context(), title)
+	if err != nil {
+		return false, c.String(500, err.Error())
+	}
+	if canEditPage(c, s) {
+		return true, nil
+	}
+	if _, ok := middleware.IdentityFrom(c); !ok {
+		return false, middleware.Unauthenticated(c)
+	}
+	return false, middleware.Forbidden(c)
+}
+
+// renderEdit renders edit.html with the given status.
+func (h *Handler) renderEdit(c echo.Context, status int, p editPage) error {
+	token, err := csrfToken(c)
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+	p.CSRFToken = token
+	if p.Settings, err = h.pageSettings(c.Request().Context(), p.Title); err != nil {
+		return c.String(500, err.Error())
+	}
+	p.CanManage = p.Base > 0 && isAdmin(c)
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(status)
+	return wikiTemplates.ExecuteTemplate(c.Response(), "edit.html", p)
+}
+
+// mergeView answers a save from base that lost the race to another edit with a
+// 409 and the edit form again: based on the current revision, with the editor's
+// changes merged into it and overlapping ones marked for manual merging.
+func (h *Handler) mergeView(c echo.Context, title string, base int, body, summary string) error {
+	revs, err := h.Pages.History(c.Request().Context(), title)
+	if errors.Is(err, data.ErrNotFound) { // base names a revision of a page that doesn't exist
+		return c.String(400, "Invalid base revision")
+	}
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+	theirs := revs[len(revs)-1]
+	baseRev, _ := findRevision(revs, base) // base 0: the page was created meanwhile
+
+	merged, n := diff.Merge(diff.SplitLines(baseRev.Body), diff.SplitLines(body), diff.SplitLines(theirs.Body),
+		"your edit", fmt.Sprintf("revision %d", theirs.ID))
+	return h.renderEdit(c, 409, editPage{
+		Title:   title,
+		Body:    strings.Join(merged, "\n"),
+		Base:    theirs.ID,
+		Summary: summary,
+		Conflict: &editConflict{
+			BaseID:    base,
+			Theirs:    theirs,
+			Rows:      diffRows(baseRev.Body, theirs.Body),
+			Conflicts: n,
+		},
+	})
+}
+
+// SetWikiSettings handles POST /wiki/settings/:title (admins), changing who may edit the page.
+func (h *Handler) SetWikiSettings(c echo.Context) error {
+	title, err := wikiTitle(c)
+	if err != nil {
+		return c.String(400, "Invalid page title")
+	}
+
+	s := models.WikiPageSettings{
+		Protected:      c.FormValue("protected") != "",
+		AnonymousEdits: c.FormValue("anonymous_edits") != "",
+	}
+	err = h.Pages.SetSettings(c.Request().Context(), title, s)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.String(404, "No such page")
+	}
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+	return c.Redirect(302, "/edit/"+url.PathEscape(title))
+}
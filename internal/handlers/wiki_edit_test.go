@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+func TestCanEditPage(t *testing.T) {
+	var (
+		open      = models.WikiPageSettings{}
+		anonymous = models.WikiPageSettings{AnonymousEdits: true}
+		protected = models.WikiPageSettings{Protected: true, AnonymousEdits: true}
+	)
+	for _, tc := range []struct {
+		name     string
+		role     string // "" for a visitor without a session
+		settings models.WikiPageSettings
+		want     bool
+	}{
+		{"visitor, default page", "", open, false},
+		{"visitor, anonymous edits allowed", "", anonymous, true},
+		{"customer, default page", models.RoleCustomer, open, true},
+		{"customer, protected page", models.RoleCustomer, protected, false},
+		{"staff, protected page", models.RoleStaff, protected, false},
+		{"visitor, protected page", "", protected, false},
+		{"admin, protected page", models.RoleAdmin, protected, true},
+	} {
+		c := echo.New().NewContext(httptest.NewRequest("GET", "/edit/Home", nil), httptest.NewRecorder())
+		if tc.role != "" {
+			middleware.SetIdentity(c, middleware.Identity{UserID: 1, Username: "ann", Role: tc.role})
+		}
+		if got := canEditPage(c, tc.settings); got != tc.want {
+			t.Errorf("%s: canEditPage = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
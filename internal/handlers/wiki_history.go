@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/diff"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// maxSummaryLen caps edit summaries, in characters.
+const maxSummaryLen = 200
+
+// findRevision returns revision id of revs.
+func findRevision(revs []models.WikiRevision, id int) (models.WikiRevision, bool) {
+	if id < 1 || id > len(revs) {
+		return models.WikiRevision{}, false
+	}
+	return revs[id-1], true
+}
+
+// wikiAuthor returns the username recorded for an edit by the request's user.
+func wikiAuthor(c echo.Context) string {
+	id, _ := middleware.IdentityFrom(c)
+	return id.Username
+}
+
+// editSummary reads the optional summary field, trimmed to maxSummaryLen characters.
+func editSummary(c echo.Context) string {
+	s := strings.Join(strings.Fields(c.FormValue("summary")), " ")
+	if r := []rune(s); len(r) > maxSummaryLen {
+		s = string(r[:maxSummaryLen])
+	}
+	return s
+}
+
+// HistoryWiki handles GET /history/:title, listing revisions newest first.
+func (h *Handler) HistoryWiki(c echo.Context) error {
+	title, err := wikiTitle(c)
+	if err != nil {
+		return c.String(400, "Invalid page title")
+	}
+
+	revs, err := h.Pages.History(c.Request().Context(), title)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.String(404, "No such page")
+	}
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+	token, err := csrfToken(c)
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+
+	settings, err := h.pageSettings(c.Request().Context(), title)
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+
+	slices.Reverse(revs)
+	return wikiTemplates.ExecuteTemplate(c.Response(), "history.html", struct {
+		Title     string
+		Revisions []models.WikiRevision
+		Latest    int
+		CSRFToken string
+		CanEdit   bool
+	}{title, revs, revs[0].ID, token, canEditPage(c, settings)})
+}
+
+// diffRow is one line of diff.html.
+type diffRow struct {
+	Class string // "eq", "del" or "ins"
+	Sign  string
+	Text  string
+}
+
+// diffRows returns the line diff of two texts for display.
+func diffRows(oldText, newText string) []diffRow {
+	lines := diff.Lines(diff.SplitLines(oldText), diff.SplitLines(newText))
+	rows := make([]diffRow, len(lines))
+	for i, l := range lines {
+		switch l.Kind {
+		case diff.Delete:
+			rows[i] = diffRow{"del", "-", l.Text}
+		case diff.Insert:
+			rows[i] = diffRow{"ins", "+", l.Text}
+		default:
+			rows[i] = diffRow{"eq", " ", l.Text}
+		}
+	}
+	return rows
+}
+
+// DiffWiki handles GET /diff/:title?from=N&to=M. Both default to the latest revision
+// and the one before it.
+func (h *Handler) DiffWiki(c echo.Context) error {
+	title, err := wikiTitle(c)
+	if err != nil {
+		return c.String(400, "Invalid page title")
+	}
+
+	revs, err := h.Pages.History(c.Request().Context(), title)
+	if errors.Is(err, data.ErrNotFound) {
+		return c.String(404, "No such page")
+	}
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+
+	to, from := len(revs), len(revs)-1
+	if v := c.QueryParam("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			return c.String(400, "Invalid revision")
+		}
+		from = to - 1
+	}
+	if v := c.QueryParam("from"); v != "" {
+		if from, err = strconv.Atoi(v); err != nil {
+			return c.String(400, "Invalid revision")
+		}
+	}
+	newRev, ok := findRevision(revs, to)
+	if !ok {
+		return c.String(404, "No such revision")
+	}
+	oldRev, ok := findRevision(revs, from)
+	if !ok && from != 0 { // from=0 compares with the empty page
+		return c.String(404, "No such revision")
+	}
+
+	return wikiTemplates.ExecuteTemplate(c.Response(), "diff.html", struct {
+		Title    string
+		From, To models.WikiRevision
+		Rows     []diffRow
+	}{title, oldRev, newRev, diffRows(oldRev.Body, newRev.Body)})
+}
+
+// RevertWiki handles POST /revert/:title, saving the text of revision "rev" as a new
+// revision. Like SaveWiki it needs the revision the history page showed as current, "base".
+func (h *Handler) RevertWiki(c echo.Context) error {
+	title, err := wikiTitle(c)
+	if err != nil {
+		return c.String(400, "Invalid page title")
+	}
+	if ok, err := h.checkEditable(c, title); !ok {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.FormValue("rev"))
+	if err != nil {
+		return c.String(400, "Invalid revision")
+	}
+	base, err := strconv.Atoi(c.FormValue("base"))
+	if err != nil || base < 0 {
+		return c.String(400, "Invalid base revision")
+	}
+	revs, err := h.Pages.History(c.Request().Context(), title)
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		return c.String(500, err.Error())
+	}
+	rev, ok := findRevision(revs, id)
+	if !ok {
+		return c.String(404, "No such revision")
+	}
+
+	summary := fmt.Sprintf("Reverted to revision %d", id)
+	if s := editSummary(c); s != "" {
+		summary += ": " + s
+	}
+	err = h.savePage(c, title, base, rev.Body, summary)
+	if errors.Is(err, data.ErrVersionConflict) {
+		return c.String(409, "The page has changed since its history was loaded; reload the history and try again")
+	}
+	if err != nil {
+		return c.String(500, err.Error())
+	}
+	return c.Redirect(302, "/history/"+url.PathEscape(title))
+}
@@ -0,0 +1,189 @@
+// Package loginguard throttles password guessing. Every login attempt is
+// recorded in a data.LoginAttemptRepository (the audit trail), and recent
+// failures per username and per client IP decide whether the next attempt may
+// even reach bcrypt: first with growing delays, then with a temporary lockout.
+//
+// Usernames are compared case-insensitively, like users.username under MySQL's
+// default collation: the Guard records and throttles them in Normalize form.
+package loginguard
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// Policy sets the throttling thresholds.
+type Policy struct {
+	Window time.Duration // how far back failures count
+
+	// FreeAttempts failures per username are allowed back to back; each further one
+	// must wait BaseDelay, doubling per failure up to MaxDelay.
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+
+	// UserLockout failures lock the username, and IPLockout failures (any usernames)
+	// block the IP, for LockDuration after the last failure.
+	UserLockout  int
+	IPLockout    int
+	LockDuration time.Duration
+}
+
+// DefaultPolicy allows 3 quick retries, then 1s, 2s, 4s... and locks after 10
+// failures per account or 30 per IP within 15 minutes.
+var DefaultPolicy = Policy{
+	Window:       15 * time.Minute,
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	UserLockout:  10,
+	IPLockout:    30,
+	LockDuration: 15 * time.Minute,
+}
+
+// Guard applies a Policy using the recorded attempts.
+type Guard struct {
+	Attempts data.LoginAttemptRepository
+	Policy   Policy
+	Now      func() time.Time // for tests; time.Now when nil
+
+	mu    sync.Mutex
+	locks map[string]*userLock // held by Attempt, per username
+}
+
+// userLock serialises the attempts on one username; refs counts the holders and
+// waiters, so the entry can go once nobody needs it.
+type userLock struct {
+	sync.Mutex
+	refs int
+}
+
+// New creates a Guard with DefaultPolicy.
+func New(attempts data.LoginAttemptRepository) *Guard {
+	return &Guard{Attempts: attempts, Policy: DefaultPolicy}
+}
+
+// Normalize returns the form of username that attempts are recorded and locked under,
+// so "Admin" and "admin " share one failure count.
+func Normalize(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func (g *Guard) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+// Check reports how long the caller must wait before username may be tried from ip;
+// 0 means go ahead. A blocked attempt is recorded, so callers just return the wait.
+func (g *Guard) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	username = Normalize(username)
+	now := g.now()
+	since := now.Add(-g.Policy.Window)
+
+	userFailures, err := g.Attempts.UserFailures(ctx, username, since, g.Policy.UserLockout)
+	if err != nil {
+		return 0, err
+	}
+	ipFailures, err := g.Attempts.IPFailures(ctx, ip, since, g.Policy.IPLockout)
+	if err != nil {
+		return 0, err
+	}
+
+	var until time.Time
+	switch n := len(userFailures); {
+	case n >= g.Policy.UserLockout:
+		until = userFailures[0].Add(g.Policy.LockDuration)
+	case n >= g.Policy.FreeAttempts:
+		delay := g.Policy.BaseDelay << (n - g.Policy.FreeAttempts)
+		if delay > g.Policy.MaxDelay || delay <= 0 {
+			delay = g.Policy.MaxDelay
+		}
+		until = userFailures[0].Add(delay)
+	}
+	if len(ipFailures) >= g.Policy.IPLockout {
+		if t := ipFailures[0].Add(g.Policy.LockDuration); t.After(until) {
+			until = t
+		}
+	}
+
+	wait := until.Sub(now)
+	if wait <= 0 {
+		return 0, nil
+	}
+	return wait, g.record(ctx, username, ip, models.LoginBlocked)
+}
+
+// Attempt runs verify (a password or code check) for username from ip behind the
+// guard and records a failure if it reports false. A positive wait means the attempt
+// was throttled and verify wasn't called. Successes are left to the caller to record
+// with Succeed, since a login may still need a second factor.
+//
+// Attempts on the same username are serialised from Check until the failure is
+// recorded, so a burst of parallel guesses can't all pass Check on the same count.
+// The lock is per process; it doesn't cover several servers sharing one database.
+func (g *Guard) Attempt(ctx context.Context, username, ip string, verify func() (bool, error)) (ok bool, wait time.Duration, err error) {
+	username = Normalize(username)
+	unlock := g.lock(username)
+	defer unlock()
+
+	if wait, err := g.Check(ctx, username, ip); err != nil || wait > 0 {
+		return false, wait, err
+	}
+	if ok, err = verify(); err != nil || ok {
+		return ok, 0, err
+	}
+	return false, 0, g.Fail(ctx, username, ip)
+}
+
+// lock takes the lock of username and returns its release.
+func (g *Guard) lock(username string) (unlock func()) {
+	g.mu.Lock()
+	if g.locks == nil {
+		g.locks = make(map[string]*userLock)
+	}
+	l := g.locks[username]
+	if l == nil {
+		l = &userLock{}
+		g.locks[username] = l
+	}
+	l.refs++
+	g.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(g.locks, username)
+		}
+	}
+}
+
+// Fail records a wrong password (or unknown username).
+func (g *Guard) Fail(ctx context.Context, username, ip string) error {
+	return g.record(ctx, Normalize(username), ip, models.LoginFailure)
+}
+
+// Succeed records a successful login, which clears the username's failures.
+func (g *Guard) Succeed(ctx context.Context, username, ip string) error {
+	return g.record(ctx, Normalize(username), ip, models.LoginSuccess)
+}
+
+// Unlock clears the username's failures on behalf of an admin at ip.
+// IP blocks are not lifted; they expire on their own.
+func (g *Guard) Unlock(ctx context.Context, username, ip string) error {
+	return g.record(ctx, Normalize(username), ip, models.LoginUnlock)
+}
+
+func (g *Guard) record(ctx context.Context, username, ip, outcome string) error {
+	return g.Attempts.Record(ctx, models.LoginAttempt{Username: username, IP: ip, Outcome: outcome, CreatedAt: g.now()})
+}
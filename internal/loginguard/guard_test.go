@@ -0,0 +1,136 @@
+package loginguard
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+)
+
+// newTestGuard returns a guard on a fake clock that advance moves forward.
+func newTestGuard() (*Guard, func(time.Duration)) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := New(data.NewMemoryLoginAttemptRepo())
+	g.Now = func() time.Time { return now }
+	return g, func(d time.Duration) { now = now.Add(d) }
+}
+
+func mustCheck(t *testing.T, g *Guard, username, ip string) time.Duration {
+	t.Helper()
+	wait, err := g.Check(context.Background(), username, ip)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	return wait
+}
+
+func TestProgressiveDelayAndLockout(t *testing.T) {
+	ctx := context.Background()
+	g, advance := newTestGuard()
+
+	for i := 0; i < DefaultPolicy.FreeAttempts; i++ {
+		if wait := mustCheck(t, g, "alice", "1.1.1.1"); wait != 0 {
+			t.Fatalf("attempt %d throttled by %v", i+1, wait)
+		}
+		_ = g.Fail(ctx, "alice", "1.1.1.1")
+	}
+	if wait := mustCheck(t, g, "alice", "1.1.1.1"); wait != time.Second {
+		t.Fatalf("after %d failures: wait %v, want 1s", DefaultPolicy.FreeAttempts, wait)
+	}
+	advance(time.Second)
+	_ = g.Fail(ctx, "alice", "1.1.1.1")
+	if wait := mustCheck(t, g, "alice", "1.1.1.1"); wait != 2*time.Second {
+		t.Fatalf("delay should double: wait %v, want 2s", wait)
+	}
+
+	// Keep failing (waiting out each delay) until the account locks.
+	for i := DefaultPolicy.FreeAttempts + 1; i < DefaultPolicy.UserLockout; i++ {
+		advance(DefaultPolicy.MaxDelay)
+		_ = g.Fail(ctx, "alice", "1.1.1.1")
+	}
+	if wait := mustCheck(t, g, "alice", "2.2.2.2"); wait != DefaultPolicy.LockDuration {
+		t.Fatalf("locked account: wait %v, want %v from any IP", wait, DefaultPolicy.LockDuration)
+	}
+	if wait := mustCheck(t, g, "bob", "1.1.1.1"); wait != 0 {
+		t.Fatalf("other accounts from the same IP should not be locked: wait %v", wait)
+	}
+
+	if err := g.Unlock(ctx, "alice", "9.9.9.9"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if wait := mustCheck(t, g, "alice", "1.1.1.1"); wait != 0 {
+		t.Fatalf("after unlock: wait %v, want 0", wait)
+	}
+}
+
+func TestSuccessResetsUserFailures(t *testing.T) {
+	ctx := context.Background()
+	g, _ := newTestGuard()
+
+	for i := 0; i < DefaultPolicy.FreeAttempts; i++ {
+		_ = g.Fail(ctx, "alice", "1.1.1.1")
+	}
+	_ = g.Succeed(ctx, "alice", "1.1.1.1")
+	if wait := mustCheck(t, g, "alice", "1.1.1.1"); wait != 0 {
+		t.Fatalf("after a successful login: wait %v, want 0", wait)
+	}
+}
+
+func TestIPLockoutAcrossUsernames(t *testing.T) {
+	ctx := context.Background()
+	g, advance := newTestGuard()
+
+	for i := 0; i < DefaultPolicy.IPLockout; i++ {
+		_ = g.Fail(ctx, fmt.Sprintf("user%d", i), "6.6.6.6")
+	}
+	if wait := mustCheck(t, g, "fresh", "6.6.6.6"); wait != DefaultPolicy.LockDuration {
+		t.Fatalf("sprayed IP: wait %v, want %v", wait, DefaultPolicy.LockDuration)
+	}
+	if wait := mustCheck(t, g, "fresh", "7.7.7.7"); wait != 0 {
+		t.Fatalf("other IPs should not be blocked: wait %v", wait)
+	}
+
+	advance(DefaultPolicy.LockDuration)
+	if wait := mustCheck(t, g, "fresh", "6.6.6.6"); wait != 0 {
+		t.Fatalf("IP block should expire: wait %v", wait)
+	}
+}
+
+func TestConcurrentAttemptsCantOutrunLockout(t *testing.T) {
+	g, _ := newTestGuard()
+	ctx := context.Background()
+
+	// The variants are one account wherever usernames compare case-insensitively.
+	variants := []string{"alice", "Alice", "ALICE", " alice", "aLiCe "}
+	var verified atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := g.Attempt(ctx, variants[i%len(variants)], fmt.Sprintf("10.0.0.%d", i), func() (bool, error) {
+				verified.Add(1)
+				time.Sleep(10 * time.Millisecond) // as slow as bcrypt, so the guesses overlap
+				return false, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The clock doesn't move, so only the free attempts get through; without the
+	// per-username lock most of the burst would see zero failures.
+	if n := int(verified.Load()); n > DefaultPolicy.UserLockout || n != DefaultPolicy.FreeAttempts {
+		t.Fatalf("%d of 50 parallel guesses reached verification, want %d (and never more than %d)",
+			n, DefaultPolicy.FreeAttempts, DefaultPolicy.UserLockout)
+	}
+	if len(g.locks) != 0 {
+		t.Errorf("%d per-username locks left behind", len(g.locks))
+	}
+}
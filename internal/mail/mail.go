@@ -0,0 +1,106 @@
+// Package mail sends the account emails (verification, password reset).
+// SMTPMailer delivers them; FileMailer writes them to disk and the log so the
+// flows can be followed locally without a mail server.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// validHeader rejects values that could inject extra headers.
+func validHeader(values ...string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("mail: header value %q contains a line break", v)
+		}
+	}
+	return nil
+}
+
+// SMTPMailer sends through an SMTP server. Username may be empty for servers
+// without authentication; with it, PLAIN auth is used (STARTTLS when offered).
+type SMTPMailer struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+// Send delivers msg. net/smtp has no context support, so ctx is only checked up front.
+func (m SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := validHeader(msg.To, msg.Subject); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, format(m.From, msg, time.Now()))
+}
+
+// FileMailer writes each message to Dir as a .eml file and logs it.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send writes msg to a new file in Dir.
+func (m FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := validHeader(msg.To, msg.Subject); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return err
+	}
+	now := time.Now()
+	f, err := os.CreateTemp(m.Dir, now.Format("20060102-150405")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(format(m.From, msg, now)); err != nil {
+		return err
+	}
+	log.Printf("[MAIL] %q to %s written to %s", msg.Subject, msg.To, filepath.ToSlash(f.Name()))
+	return nil
+}
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := FileMailer{Dir: dir, From: "app@example.com"}
+
+	err := m.Send(context.Background(), Message{To: "ann@example.com", Subject: "Hi", Body: "line 1\nline 2\n"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("got %d .eml files, want 1", len(files))
+	}
+	raw, _ := os.ReadFile(files[0])
+	for _, want := range []string{"From: app@example.com\r\n", "To: ann@example.com\r\n", "Subject: Hi\r\n", "\r\n\r\nline 1\r\nline 2\r\n"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("message lacks %q:\n%s", want, raw)
+		}
+	}
+}
+
+func TestSendRejectsHeaderInjection(t *testing.T) {
+	m := FileMailer{Dir: t.TempDir(), From: "app@example.com"}
+	for _, msg := range []Message{
+		{To: "ann@example.com\r\nBcc: eve@example.com", Subject: "Hi"},
+		{To: "ann@example.com", Subject: "Hi\nBcc: eve@example.com"},
+	} {
+		if err := m.Send(context.Background(), msg); err == nil {
+			t.Errorf("Send(%q, %q) succeeded, want an error", msg.To, msg.Subject)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package markup renders wiki page text to safe HTML: GitHub-flavoured Markdown
+// plus [[Page Name]] and [[Page Name|label]] links between pages. Raw HTML in
+// the source is dropped by the Markdown renderer, and the output is sanitised
+// again with a bluemonday allow-list, so saved text can't inject script.
+package markup
+
+import (
+	"bytes"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// PageExists reports whether a wiki page exists, so links to missing pages can be marked.
+type PageExists func(title string) bool
+
+// Render converts wiki text to sanitised HTML. Links to pages for which exists
+// returns false get the "missing" class and point at the page's editor.
+func Render(src []byte, exists PageExists) (template.HTML, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM, &wikiLinks{exists: exists}),
+	)
+	var buf bytes.Buffer
+	if err := md.Convert(src, &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// policy allows the HTML Markdown produces and nothing else of note: no script,
+// style, event handlers or javascript: URLs.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(false)
+	p.RequireNoFollowOnFullyQualifiedLinks(true) // external links only
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^wikilink( missing)?$`)).OnElements("a")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	return p
+}()
+
+// PageURL is the path of a page's view handler.
+func PageURL(title string) string {
+	return "/view/" + url.PathEscape(title)
+}
+
+// kindWikiLink is the AST node kind of a [[...]] link.
+var kindWikiLink = ast.NewNodeKind("WikiLink")
+
+// wikiLink is a [[Target]] or [[Target|Label]] link.
+type wikiLink struct {
+	ast.BaseInline
+	Target string
+	Label  string
+}
+
+func (n *wikiLink) Kind() ast.NodeKind { return kindWikiLink }
+
+func (n *wikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target, "Label": n.Label}, nil)
+}
+
+// wikiLinks is the goldmark extension for [[...]] links.
+type wikiLinks struct {
+	exists PageExists
+}
+
+func (e *wikiLinks) Extend(m goldmark.Markdown) {
+	// Ahead of the standard link parser (priority 200), which would otherwise claim the '['.
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(wikiLinkParser{}, 199)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&wikiLinkRenderer{exists: e.exists}, 199)))
+}
+
+type wikiLinkParser struct{}
+
+func (wikiLinkParser) Trigger() []byte { return []byte{'['} }
+
+func (wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := string(line[2 : 2+end])
+	target, label, hasLabel := strings.Cut(inner, "|")
+	target = strings.TrimSpace(target)
+	if target == "" || strings.ContainsAny(target, "[]") {
+		return nil
+	}
+	if label = strings.TrimSpace(label); !hasLabel || label == "" {
+		label = target
+	}
+	block.Advance(2 + end + 2)
+	return &wikiLink{Target: target, Label: label}
+}
+
+type wikiLinkRenderer struct {
+	exists PageExists
+}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindWikiLink, r.render)
+}
+
+func (r *wikiLinkRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*wikiLink)
+	href, class := PageURL(n.Target), "wikilink"
+	if r.exists != nil && !r.exists(n.Target) {
+		href, class = "/edit/"+url.PathEscape(n.Target), "wikilink missing"
+	}
+	_, _ = w.WriteString(`<a class="` + class + `" href="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(href)))
+	_, _ = w.WriteString(`">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.Label)))
+	_, _ = w.WriteString(`</a>`)
+	return ast.WalkSkipChildren, nil
+}
+
+// Links returns the targets of the [[...]] links in src, in order of appearance,
+// skipping any inside code.
+func Links(src []byte) []string {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, &wikiLinks{}))
+	doc := md.Parser().Parse(text.NewReader(src))
+	var targets []string
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if l, ok := n.(*wikiLink); ok && entering {
+			targets = append(targets, l.Target)
+		}
+		return ast.WalkContinue, nil
+	})
+	return targets
+}
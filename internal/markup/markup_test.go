@@ -0,0 +1,110 @@
+package markup
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func render(t *testing.T, src string) string {
+	t.Helper()
+	out, err := Render([]byte(src), func(title string) bool { return title == "Home" || title == "Page Name" })
+	if err != nil {
+		t.Fatalf("Render(%q): %v", src, err)
+	}
+	return string(out)
+}
+
+func TestMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, want string
+	}{
+		{"heading", "# Title", "<h1>Title</h1>"},
+		{"emphasis", "some *text*", "<em>text</em>"},
+		{"fenced code", "```go\nfmt.Println(\"<b>\")\n```", `<code class="language-go">fmt.Println(&#34;&lt;b&gt;&#34;)`},
+		{"markdown link", "[Go](https://go.dev)", `<a href="https://go.dev" rel="nofollow">Go</a>`},
+		{"table", "| a |\n|---|\n| b |", "<td>b</td>"},
+	} {
+		if got := render(t, tc.src); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: got %q, want it to contain %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWikiLinks(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, want string
+	}{
+		{"existing page", "see [[Home]]", `<a class="wikilink" href="/view/Home">Home</a>`},
+		{"spaces", "[[Page Name]]", `<a class="wikilink" href="/view/Page%20Name">Page Name</a>`},
+		{"label", "[[Home|the start]]", `<a class="wikilink" href="/view/Home">the start</a>`},
+		{"missing page", "[[Nowhere]]", `<a class="wikilink missing" href="/edit/Nowhere">Nowhere</a>`},
+		{"single brackets stay text", "a [note] here", "<p>a [note] here</p>"},
+		{"not inside code", "`[[Home]]`", "<code>[[Home]]</code>"},
+	} {
+		if got := render(t, tc.src); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: got %q, want it to contain %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	got := Links([]byte("[[Home]] and [[Page Name|label]]\n\n`[[Code]]`\n\n    [[Indented]]\n\n[[Home]]"))
+	if want := []string{"Home", "Page Name", "Home"}; !slices.Equal(got, want) {
+		t.Errorf("Links = %q, want %q", got, want)
+	}
+}
+
+func TestXSSPayloads(t *testing.T) {
+	for _, src := range []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`<svg onload=alert(1)>`,
+		`<iframe src="javascript:alert(1)"></iframe>`,
+		`<a href="javascript:alert(1)">x</a>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`,
+		`![img](x" onerror="alert(1))`,
+		`<div style="background:url(javascript:alert(1))">x</div>`,
+		`[[<script>alert(1)</script>]]`,
+		`[[Home|<img src=x onerror=alert(1)>]]`,
+		`[[x" onmouseover="alert(1)]]`,
+		"```\n</code><script>alert(1)</script>\n```",
+		`<details open ontoggle=alert(1)>`,
+	} {
+		if bad := unsafeMarkup(render(t, src)); bad != "" {
+			t.Errorf("Render(%q): %s", src, bad)
+		}
+	}
+}
+
+// unsafeMarkup parses rendered HTML and describes the first element or attribute
+// that could run script, or returns "". Escaped text is fine, so it looks at
+// tokens rather than substrings.
+func unsafeMarkup(out string) string {
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "script", "iframe", "svg", "object", "embed", "style", "details":
+				return "element <" + tok.Data + ">"
+			}
+			for _, a := range tok.Attr {
+				v := strings.ToLower(strings.TrimSpace(a.Val))
+				switch {
+				case strings.HasPrefix(a.Key, "on"), a.Key == "style":
+					return "attribute " + a.Key
+				case (a.Key == "href" || a.Key == "src") && (strings.HasPrefix(v, "javascript:") || strings.HasPrefix(v, "data:")):
+					return a.Key + "=" + a.Val
+				}
+			}
+		}
+	}
+}
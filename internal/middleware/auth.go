@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+
+	assets "github.com/shahinzaman102/Go_JumpStart_Echo"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// identityKey is the echo.Context key holding the request's Identity.
+const identityKey = "identity"
+
+// Identity is the authenticated user behind a request.
+type Identity struct {
+	UserID   int64
+	Username string
+	Role     string
+}
+
+// HasRole reports whether the identity has one of roles. Admins have every role.
+func (id Identity) HasRole(roles ...string) bool {
+	return id.Role == models.RoleAdmin || slices.Contains(roles, id.Role)
+}
+
+// SetIdentity attaches id to the request context.
+func SetIdentity(c echo.Context, id Identity) {
+	c.Set(identityKey, id)
+}
+
+// IdentityFrom returns the request's identity, if an authentication middleware set one.
+func IdentityFrom(c echo.Context) (Identity, bool) {
+	id, ok := c.Get(identityKey).(Identity)
+	return id, ok
+}
+
+// UserLookup is the part of data.UserRepository the session middleware needs.
+type UserLookup interface {
+	ByID(ctx context.Context, id int) (*models.User, error)
+}
+
+// Session sets the request identity from a logged-in gorilla session.
+// The role is read from the users table on every request, so role changes and
+// deleted users take effect immediately instead of at the next login.
+func Session(store sessions.Store, users UserLookup) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			session, _ := store.Get(c.Request(), "session")
+			auth, authOk := session.Values["authenticated"].(bool)
+			userID, idOk := session.Values["user_id"].(int64)
+			if authOk && auth && idOk {
+				if u, err := users.ByID(c.Request().Context(), int(userID)); err == nil {
+					SetIdentity(c, Identity{UserID: userID, Username: u.Username, Role: u.Role})
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// TokenVerifier is the part of token.Service the bearer middleware needs.
+type TokenVerifier interface {
+	VerifyAccess(ctx context.Context, raw string) (int64, error)
+}
+
+// Bearer sets the request identity from an "Authorization: Bearer" access token.
+// Requests without the header pass through untouched; an invalid token is a 401
+// rather than a silent fallback to anonymous, so API clients notice expiry.
+func Bearer(tokens TokenVerifier, users UserLookup) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			raw, ok := bearerToken(c)
+			if !ok {
+				return next(c)
+			}
+
+			userID, err := tokens.VerifyAccess(c.Request().Context(), raw)
+			var u *models.User
+			if err == nil {
+				u, err = users.ByID(c.Request().Context(), int(userID))
+			}
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid or expired token"})
+			}
+			SetIdentity(c, Identity{UserID: userID, Username: u.Username, Role: u.Role})
+			return next(c)
+		}
+	}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(c echo.Context) (string, bool) {
+	scheme, raw, ok := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(raw) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(raw), true
+}
+
+// RequireRole rejects requests without an identity (401) or without one of roles (403).
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id, ok := IdentityFrom(c)
+			if !ok {
+				return Unauthenticated(c)
+			}
+			if !id.HasRole(roles...) {
+				return Forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireLogin rejects requests without an identity (401), whatever the role.
+func RequireLogin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := IdentityFrom(c); !ok {
+			return Unauthenticated(c)
+		}
+		return next(c)
+	}
+}
+
+// wantsHTML reports whether the client is a browser navigating to a page rather than an API call.
+func wantsHTML(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML)
+}
+
+// Unauthenticated writes the 401 response: the login-required page for browsers, JSON otherwise.
+func Unauthenticated(c echo.Context) error {
+	if !wantsHTML(c) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+	}
+
+	redirect := ""
+	if c.Request().Method == http.MethodGet {
+		redirect = c.Request().URL.RequestURI()
+	}
+	return renderStatus(c, http.StatusUnauthorized, "templates/login_required.html", map[string]string{
+		"Resource": c.Request().URL.Path,
+		"Redirect": redirect,
+	})
+}
+
+// Forbidden writes the 403 response for a logged-in user lacking the required role.
+func Forbidden(c echo.Context) error {
+	if !wantsHTML(c) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "insufficient permissions"})
+	}
+	return renderStatus(c, http.StatusForbidden, "templates/forbidden.html", map[string]string{
+		"Resource": c.Request().URL.Path,
+	})
+}
+
+// renderStatus renders an embedded template with the given status code.
+func renderStatus(c echo.Context, status int, name string, data any) error {
+	tmpl, err := template.ParseFS(assets.Templates, name)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(status)
+	return tmpl.Execute(c.Response(), data)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+func TestRequireRole(t *testing.T) {
+	t.Parallel()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	staffOnly := RequireRole(models.RoleStaff)(ok)
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		accept   string
+		want     int
+		wantBody string
+	}{
+		{"anonymous JSON", nil, "application/json", http.StatusUnauthorized, `"authentication required"`},
+		{"anonymous browser", nil, "text/html,application/xhtml+xml", http.StatusUnauthorized, "/login?redirect="},
+		{"customer JSON", &Identity{UserID: 1, Role: models.RoleCustomer}, "*/*", http.StatusForbidden, `"insufficient permissions"`},
+		{"customer browser", &Identity{UserID: 1, Role: models.RoleCustomer}, "text/html", http.StatusForbidden, "Access Denied"},
+		{"staff", &Identity{UserID: 2, Role: models.RoleStaff}, "*/*", http.StatusNoContent, ""},
+		{"admin", &Identity{UserID: 3, Role: models.RoleAdmin}, "*/*", http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "/albums?x=1", nil)
+			req.Header.Set(echo.HeaderAccept, tt.accept)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			if tt.identity != nil {
+				SetIdentity(c, *tt.identity)
+			}
+
+			if err := staffOnly(c); err != nil {
+				t.Fatalf("middleware returned error: %v", err)
+			}
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// CSRFSessionKey is the session value holding the token.
+	CSRFSessionKey = "csrf_token"
+	// CSRFFormField is the hidden form field carrying the token.
+	CSRFFormField = "csrf_token"
+	// CSRFHeader is the request header JSON clients send the token in.
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// EnsureCSRFToken returns the session's CSRF token, adding one if it has none.
+// created tells the caller the session must be saved before the token is used.
+func EnsureCSRFToken(session *sessions.Session) (token string, created bool) {
+	if t, ok := session.Values[CSRFSessionKey].(string); ok && t != "" {
+		return t, false
+	}
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	session.Values[CSRFSessionKey] = token
+	return token, true
+}
+
+// CSRF rejects state-changing requests that a third-party page could forge: those
+// riding on the session cookie, and HTML form posts (which also covers login CSRF).
+// They must echo the session's token in the csrf_token field or the X-CSRF-Token header.
+//
+// Bearer-token requests are exempt (a forged request can't set Authorization), as are
+// JSON requests without a session cookie, which carry no ambient credentials.
+// exempt lists path prefixes that authenticate with credentials in the body, like /auth/.
+func CSRF(store sessions.Store, exempt ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			switch req.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				return next(c)
+			}
+			if _, ok := bearerToken(c); ok {
+				return next(c)
+			}
+			for _, prefix := range exempt {
+				if strings.HasPrefix(req.URL.Path, prefix) {
+					return next(c)
+				}
+			}
+			if _, err := req.Cookie("session"); err != nil && !isFormPost(req) {
+				return next(c)
+			}
+
+			session, _ := store.Get(req, "session")
+			want, _ := session.Values[CSRFSessionKey].(string)
+			got := req.Header.Get(CSRFHeader)
+			if got == "" {
+				got = c.FormValue(CSRFFormField)
+			}
+			if want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+				return csrfFailed(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// isFormPost reports whether the body has a content type an HTML form can send cross-site without a preflight.
+func isFormPost(req *http.Request) bool {
+	ct := req.Header.Get(echo.HeaderContentType)
+	return strings.HasPrefix(ct, echo.MIMEApplicationForm) ||
+		strings.HasPrefix(ct, echo.MIMEMultipartForm) ||
+		strings.HasPrefix(ct, echo.MIMETextPlain)
+}
+
+// csrfFailed writes the 403 response for a missing or mismatched token.
+func csrfFailed(c echo.Context) error {
+	if !wantsHTML(c) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "missing or invalid CSRF token"})
+	}
+	return renderStatus(c, http.StatusForbidden, "templates/forbidden.html", map[string]string{
+		"Resource": c.Request().URL.Path,
+		"Reason":   "The form has expired or was submitted from another site. Reload the page and try again.",
+	})
+}
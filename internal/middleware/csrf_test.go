@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+)
+
+func TestCSRF(t *testing.T) {
+	t.Parallel()
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+
+	// A session cookie whose token is "good".
+	setup := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	session, _ := store.Get(setup, "session")
+	session.Values[CSRFSessionKey] = "good"
+	if err := session.Save(setup, rec); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	cookie := rec.Result().Cookies()[0]
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	guarded := CSRF(store, "/auth/token")(ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		cookie      bool
+		contentType string
+		body        string
+		header      map[string]string
+		want        int
+	}{
+		{"GET is never checked", http.MethodGet, "/orders", true, "", "", nil, http.StatusNoContent},
+		{"cookie JSON without token", http.MethodPost, "/orders", true, echo.MIMEApplicationJSON, `{}`, nil, http.StatusForbidden},
+		{"cookie JSON with header", http.MethodPost, "/orders", true, echo.MIMEApplicationJSON, `{}`, map[string]string{CSRFHeader: "good"}, http.StatusNoContent},
+		{"cookie JSON with wrong header", http.MethodPost, "/orders", true, echo.MIMEApplicationJSON, `{}`, map[string]string{CSRFHeader: "bad"}, http.StatusForbidden},
+		{"form with field", http.MethodPost, "/form", true, echo.MIMEApplicationForm, "csrf_token=good&email=a", nil, http.StatusNoContent},
+		{"form without field", http.MethodPost, "/form", true, echo.MIMEApplicationForm, "email=a", nil, http.StatusForbidden},
+		{"login form without session", http.MethodPost, "/login", false, echo.MIMEApplicationForm, "username=a&password=b", nil, http.StatusForbidden},
+		{"JSON without session", http.MethodPost, "/users", false, echo.MIMEApplicationJSON, `{}`, nil, http.StatusNoContent},
+		{"bearer request", http.MethodPost, "/orders", true, echo.MIMEApplicationJSON, `{}`, map[string]string{echo.HeaderAuthorization: "Bearer abc"}, http.StatusNoContent},
+		{"exempt path", http.MethodPost, "/auth/token", false, echo.MIMEApplicationForm, "username=a", nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set(echo.HeaderContentType, tt.contentType)
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			if err := guarded(c); err != nil {
+				t.Fatalf("middleware returned error: %v", err)
+			}
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d (%s)", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
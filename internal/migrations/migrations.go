@@ -0,0 +1,369 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	assets "github.com/shahinzaman102/Go_JumpStart_Echo"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/dialect"
+)
+
+// Migration is a single numbered schema change with its up and down SQL.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of the up SQL
+}
+
+// Status describes whether a known migration has been applied.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// ErrChecksumMismatch is returned when an applied migration was edited after it ran.
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// fileName matches <version>_<name>.<up|down>.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads all migrations from dir in fsys and returns them sorted by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", m[1], err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(content)
+			sum := sha256.Sum256(content)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Locker guards a migration run so that only one process applies migrations at a time.
+// The lock is taken on a dedicated connection and must be released on the same one.
+type Locker interface {
+	Lock(ctx context.Context, conn *sql.Conn) error
+	Unlock(ctx context.Context, conn *sql.Conn) error
+}
+
+// MySQLLocker uses MySQL's named advisory locks (GET_LOCK / RELEASE_LOCK).
+type MySQLLocker struct {
+	Name    string
+	Timeout time.Duration
+}
+
+// Lock waits up to Timeout for the named lock.
+func (l MySQLLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	var got sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.Name, int(l.Timeout.Seconds())).Scan(&got)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for migration lock %q", l.Name)
+	}
+	return nil
+}
+
+// Unlock releases the named lock.
+func (l MySQLLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.Name)
+	return err
+}
+
+// TableLocker is a portable lock for databases without advisory locks (SQLite).
+// It holds the lock by owning the single row of schema_migrations_lock; a lock older
+// than StaleAfter is assumed to belong to a crashed process and is taken over.
+type TableLocker struct {
+	Timeout    time.Duration
+	StaleAfter time.Duration
+}
+
+// Lock polls until it can insert the lock row or Timeout passes.
+func (l TableLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+    id        INT NOT NULL PRIMARY KEY,
+    locked_at DATETIME NOT NULL
+)`); err != nil {
+		return fmt.Errorf("failed to create migration lock table: %w", err)
+	}
+
+	deadline := time.Now().Add(l.Timeout)
+	for {
+		now := time.Now().UTC()
+		if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE locked_at < ?", now.Add(-l.StaleAfter)); err != nil {
+			return fmt.Errorf("failed to clear stale migration lock: %w", err)
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", now); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for migration lock")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// Unlock removes the lock row.
+func (l TableLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1")
+	return err
+}
+
+// Migrator applies and rolls back migrations, tracking them in schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	locker     Locker
+	migrations []Migration
+}
+
+// New creates a Migrator for the given migrations.
+func New(db *sql.DB, locker Locker, migrations []Migration) *Migrator {
+	return &Migrator{db: db, locker: locker, migrations: migrations}
+}
+
+const createTrackingTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    BIGINT NOT NULL PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    checksum   CHAR(64) NOT NULL,
+    applied_at DATETIME NOT NULL
+)`
+
+// applied is a row from schema_migrations.
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// withLock runs fn on a single connection while holding the migration lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.locker.Lock(ctx, conn); err != nil {
+		return err
+	}
+	defer m.locker.Unlock(context.Background(), conn)
+
+	if _, err := conn.ExecContext(ctx, createTrackingTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// loadApplied reads schema_migrations and verifies checksums against the known migrations.
+func (m *Migrator) loadApplied(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int64]applied)
+	for rows.Next() {
+		var version int64
+		var a applied
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+	for version, a := range done {
+		mig, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("database has migration %d_%s which is not known to this binary", version, a.name)
+		}
+		if mig.Checksum != a.checksum {
+			return nil, fmt.Errorf("%w: %d_%s was modified after it was applied", ErrChecksumMismatch, version, mig.Name)
+		}
+	}
+	return done, nil
+}
+
+// Up applies all pending migrations in order and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, mig.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+					mig.Version, mig.Name, mig.Checksum, time.Now().UTC())
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down rolls back the most recent steps applied migrations and returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+			if err := apply(ctx, conn, mig.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status reports every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			a, ok := done[mig.Version]
+			statuses = append(statuses, Status{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				Applied:   ok,
+				AppliedAt: a.appliedAt,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// apply runs the migration SQL and the bookkeeping statement in one transaction.
+// Note: MySQL commits DDL implicitly, so only the bookkeeping is truly atomic there.
+func apply(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NewEmbedded builds a Migrator for the migrations compiled into the binary (assets.Migrations),
+// picking the directory and lock strategy that match the dialect.
+func NewEmbedded(db *sql.DB, d dialect.Dialect) (*Migrator, error) {
+	migrations, err := Load(assets.Migrations, path.Join("migrations", d.Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	var locker Locker = MySQLLocker{Name: "schema_migrations", Timeout: 30 * time.Second}
+	if d == dialect.SQLite {
+		locker = TableLocker{Timeout: 30 * time.Second, StaleAfter: 10 * time.Minute}
+	}
+	return New(db, locker, migrations), nil
+}
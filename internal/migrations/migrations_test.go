@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+// nopLocker skips locking; an in-memory SQLite DB is never shared between processes.
+type nopLocker struct{}
+
+func (nopLocker) Lock(context.Context, *sql.Conn) error   { return nil }
+func (nopLocker) Unlock(context.Context, *sql.Conn) error { return nil }
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"m/0001_widgets.up.sql":   {Data: []byte("CREATE TABLE widget (id INTEGER PRIMARY KEY, name TEXT);")},
+		"m/0001_widgets.down.sql": {Data: []byte("DROP TABLE widget;")},
+		"m/0002_color.up.sql":     {Data: []byte("ALTER TABLE widget ADD COLUMN color TEXT;")},
+		"m/0002_color.down.sql":   {Data: []byte("ALTER TABLE widget DROP COLUMN color;")},
+	}
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // every connection to :memory: is a separate database
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestUpDownAndChecksum(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	migs, err := Load(testFS(), "m")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(migs) != 2 || migs[0].Version != 1 || migs[1].Name != "color" {
+		t.Fatalf("unexpected migrations: %+v", migs)
+	}
+
+	m := New(db, nopLocker{}, migs)
+	if n, err := m.Up(ctx); err != nil || n != 2 {
+		t.Fatalf("up: n=%d err=%v", n, err)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Fatalf("second up should be a no-op: n=%d err=%v", n, err)
+	}
+	if _, err := db.Exec("INSERT INTO widget (name, color) VALUES ('a', 'red')"); err != nil {
+		t.Fatalf("schema not applied: %v", err)
+	}
+
+	if n, err := m.Down(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("down: n=%d err=%v", n, err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("unexpected status after down: %+v", statuses)
+	}
+
+	// Editing an applied migration must be detected.
+	edited := testFS()
+	edited["m/0001_widgets.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE widget (id INTEGER PRIMARY KEY);")}
+	migs, _ = Load(edited, "m")
+	if _, err := New(db, nopLocker{}, migs).Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
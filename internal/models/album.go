@@ -6,4 +6,5 @@ type Album struct {
 	Artist   string  `json:"artist"`
 	Price    float32 `json:"price"`
 	Quantity int64   `json:"quantity"`
+	Version  int64   `json:"version"` // bumped on every change; exposed as the ETag
 }
@@ -1,8 +1,9 @@
 package models
 
 type Book struct {
-	ID     int     `json:"id"`
-	Title  string  `json:"title"`
-	Author string  `json:"author"`
-	Price  float64 `json:"price"`
+	ID      int     `json:"id"`
+	Title   string  `json:"title"`
+	Author  string  `json:"author"`
+	Price   float64 `json:"price"`
+	Version int     `json:"version"` // bumped on every change; exposed as the ETag
 }
@@ -0,0 +1,23 @@
+package models
+
+// CartLine is one album in the session cart.
+type CartLine struct {
+	AlbumID  int64 `json:"album_id"`
+	Quantity int64 `json:"quantity"`
+}
+
+// CartView is the cart as shown by GET /cart, with current titles and prices.
+type CartView struct {
+	Lines []CartViewLine `json:"lines"`
+	Total float32        `json:"total"`
+}
+
+// CartViewLine is a CartLine joined with its album.
+type CartViewLine struct {
+	CartLine
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist"`
+	Price    float32 `json:"price"`
+	Subtotal float32 `json:"subtotal"`
+	InStock  int64   `json:"in_stock"`
+}
@@ -3,5 +3,12 @@ package models
 type OrderRequest struct {
 	AlbumID  int64 `json:"album_id"`
 	Quantity int64 `json:"quantity"`
-	Customer int64 `json:"customer_id"`
+	Customer int64 `json:"customer_id"` // resolved from the session user's customer profile; client values are ignored
+}
+
+// OrderItem is one line of an order; Price is the album price at checkout.
+type OrderItem struct {
+	AlbumID  int64   `json:"album_id"`
+	Quantity int64   `json:"quantity"`
+	Price    float32 `json:"price"`
 }
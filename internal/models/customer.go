@@ -0,0 +1,9 @@
+package models
+
+type Customer struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"fullName"`
+	Address  string `json:"address"`
+	Phone    string `json:"phone"`
+	UserID   int64  `json:"userId,omitempty"` // login that owns this record; 0 when unlinked
+}
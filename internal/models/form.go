@@ -5,4 +5,6 @@ type FormResponse struct {
 	Email   string
 	Subject string
 	Message string
+
+	CSRFToken string
 }
@@ -2,10 +2,20 @@ package models
 
 import "time"
 
+// Order statuses; an order starts out pending.
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderCancelled = "cancelled"
+)
+
 type GetOrder struct {
-	ID       int64     `json:"id"`
-	AlbumID  int64     `json:"album_id"`
-	Customer int64     `json:"customer_id"`
-	Quantity int64     `json:"quantity"`
-	Date     time.Time `json:"date"`
+	ID       int64       `json:"id"`
+	AlbumID  int64       `json:"album_id"`
+	Customer int64       `json:"customer_id"`
+	Quantity int64       `json:"quantity"`
+	Date     time.Time   `json:"date"`
+	Status   string      `json:"status"`
+	Items    []OrderItem `json:"items,omitempty"` // filled by single-order lookups
 }
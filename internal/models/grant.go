@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Grant is a bearer-token login: the refresh token and every access token issued from it share its ID.
+type Grant struct {
+	ID         string
+	UserID     int64
+	RefreshJTI string // ID of the only refresh token still accepted for this grant
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+// Active reports whether the grant can still be used at now.
+func (g Grant) Active(now time.Time) bool {
+	return g.RevokedAt == nil && now.Before(g.ExpiresAt)
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Login attempt outcomes.
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginBlocked = "blocked" // rejected by the throttle before the password was checked
+	LoginUnlock  = "unlock"  // an admin cleared the account's failures
+)
+
+// LoginAttempt is one row of the login audit trail.
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	Outcome   string    `json:"outcome"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Session is a server-side login session (see the user_session table).
+// ID is the SHA-256 of the cookie token, so it can be shown to the user and used to revoke the session.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"-"` // 0 until login
+	Data       []byte    `json:"-"` // gob-encoded session values
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"` // the session making the request; set by the handler
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TwoFactor is a user's TOTP enrollment (see the user_totp table).
+type TwoFactor struct {
+	UserID    int64
+	Secret    string // base32
+	CreatedAt time.Time
+	EnabledAt *time.Time // nil until the first code is confirmed
+	LastStep  int64      // time step of the last accepted code
+}
+
+// Enabled reports whether the enrollment was confirmed and is enforced at login.
+func (t *TwoFactor) Enabled() bool {
+	return t != nil && t.EnabledAt != nil
+}
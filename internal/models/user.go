@@ -2,9 +2,19 @@ package models
 
 import "time"
 
+// User roles. Admins may do everything staff can.
+const (
+	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
+	RoleCustomer = "customer"
+)
+
 type User struct {
-	ID        int
-	Username  string
-	Password  string
-	CreatedAt time.Time
+	ID              int
+	Username        string
+	Password        string
+	Role            string
+	CreatedAt       time.Time
+	Email           string     // empty for accounts created without one
+	EmailVerifiedAt *time.Time // nil until the emailed link is followed
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// WikiRevision is one saved version of a wiki page. Revisions are numbered from 1;
+// a page's current text is its latest revision.
+type WikiRevision struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Author  string    `json:"author,omitempty"` // username; empty for anonymous edits
+	Summary string    `json:"summary,omitempty"`
+	Body    string    `json:"body"`
+}
+
+// WikiPageSettings controls who may edit a wiki page. By default any logged-in user may.
+type WikiPageSettings struct {
+	Protected      bool `json:"protected,omitempty"`       // only admins may edit
+	AnonymousEdits bool `json:"anonymous_edits,omitempty"` // visitors without a session may edit too
+}
@@ -0,0 +1,149 @@
+// Package passhash hashes and verifies passwords under a configurable Policy.
+// Hashes are self-describing (bcrypt's $2a$ format or the PHC $argon2id$ format),
+// so stored hashes keep verifying after the policy changes, and Policy.NeedsRehash
+// tells whether one should be replaced at the next successful login.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm names accepted in Policy.Algorithm.
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// Argon2Params are the argon2id cost parameters.
+type Argon2Params struct {
+	Time    uint32 // passes over memory
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// Policy selects the algorithm and cost of new hashes.
+type Policy struct {
+	Algorithm  string // Bcrypt or Argon2id
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// DefaultPolicy is bcrypt at cost 12 (roughly 250ms per hash on a typical server).
+// The argon2id parameters are the RFC 9106 "second recommended option", used when
+// Algorithm is switched to Argon2id without tuning them.
+var DefaultPolicy = Policy{
+	Algorithm:  Bcrypt,
+	BcryptCost: 12,
+	Argon2:     Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 4, KeyLen: 32, SaltLen: 16},
+}
+
+// ErrUnknownHash is returned for a stored hash in neither supported format.
+var ErrUnknownHash = errors.New("passhash: unrecognized hash format")
+
+// Validate reports a policy that can't produce hashes.
+func (p Policy) Validate() error {
+	switch p.Algorithm {
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("passhash: bcrypt cost must be %d-%d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		a := p.Argon2
+		if a.Time < 1 || a.Memory < 8*uint32(a.Threads) || a.Threads < 1 || a.KeyLen < 16 || a.SaltLen < 8 {
+			return errors.New("passhash: argon2id needs time >= 1, threads >= 1, memory >= 8 KiB per thread, key >= 16 and salt >= 8 bytes")
+		}
+	default:
+		return fmt.Errorf("passhash: unknown algorithm %q", p.Algorithm)
+	}
+	return nil
+}
+
+// Hash returns a new hash of password.
+func (p Policy) Hash(password string) (string, error) {
+	if p.Algorithm == Argon2id {
+		a := p.Argon2
+		salt := make([]byte, a.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Time, a.Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+	return string(b), err
+}
+
+// Verify reports whether password matches hash, whatever policy produced it.
+func Verify(password, hash string) (bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		a, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		got := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(got, key) == 1, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+// NeedsRehash reports whether hash is weaker than p: a bcrypt hash under an argon2id
+// policy, or a hash of the policy's algorithm with lower cost parameters. Stronger
+// hashes are kept, so lowering the policy never downgrades existing ones.
+func (p Policy) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		if p.Algorithm != Argon2id {
+			return false
+		}
+		a, _, key, err := parseArgon2(hash)
+		if err != nil {
+			return true
+		}
+		return a.Time < p.Argon2.Time || a.Memory < p.Argon2.Memory || uint32(len(key)) < p.Argon2.KeyLen
+	}
+	if p.Algorithm == Argon2id {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < p.BcryptCost
+}
+
+// parseArgon2 splits a PHC-format argon2id hash.
+func parseArgon2(hash string) (a Argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$") // "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	if len(parts) != 6 {
+		return a, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return a, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &a.Memory, &a.Time, &a.Threads); err != nil {
+		return a, nil, nil, ErrUnknownHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return a, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return a, nil, nil, ErrUnknownHash
+	}
+	return a, salt, key, nil
+}
@@ -0,0 +1,92 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+)
+
+// Cheap policies keep the tests fast.
+var (
+	fastBcrypt = Policy{Algorithm: Bcrypt, BcryptCost: 4}
+	fastArgon  = Policy{Algorithm: Argon2id, Argon2: Argon2Params{Time: 1, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16}}
+)
+
+func TestHashAndVerify(t *testing.T) {
+	for _, p := range []Policy{fastBcrypt, fastArgon} {
+		hash, err := p.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s: Hash: %v", p.Algorithm, err)
+		}
+		if ok, err := Verify("correct horse", hash); !ok || err != nil {
+			t.Errorf("%s: Verify(right password) = %v, %v", p.Algorithm, ok, err)
+		}
+		if ok, err := Verify("wrong horse", hash); ok || err != nil {
+			t.Errorf("%s: Verify(wrong password) = %v, %v", p.Algorithm, ok, err)
+		}
+		if p.NeedsRehash(hash) {
+			t.Errorf("%s: fresh hash should not need a rehash", p.Algorithm)
+		}
+	}
+	if _, err := Verify("x", "plaintext"); err != ErrUnknownHash {
+		t.Errorf("Verify(unknown format) error = %v, want ErrUnknownHash", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcrypt4, _ := fastBcrypt.Hash("pw")
+	argonWeak, _ := fastArgon.Hash("pw")
+
+	stronger := fastBcrypt
+	stronger.BcryptCost = 5
+	if !stronger.NeedsRehash(bcrypt4) {
+		t.Error("bcrypt hash below the policy cost should be upgraded")
+	}
+	if fastBcrypt.NeedsRehash(argonWeak) {
+		t.Error("argon2id hash should not be downgraded to bcrypt")
+	}
+	if !fastArgon.NeedsRehash(bcrypt4) {
+		t.Error("bcrypt hash should be upgraded under an argon2id policy")
+	}
+
+	moreMemory := fastArgon
+	moreMemory.Argon2.Memory = 128
+	if !moreMemory.NeedsRehash(argonWeak) {
+		t.Error("argon2id hash with less memory than the policy should be upgraded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultPolicy.Validate(); err != nil {
+		t.Errorf("DefaultPolicy: %v", err)
+	}
+	for _, p := range []Policy{
+		{Algorithm: "md5"},
+		{Algorithm: Bcrypt, BcryptCost: 40},
+		{Algorithm: Argon2id, Argon2: Argon2Params{Time: 0, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16}},
+	} {
+		if p.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", p)
+		}
+	}
+}
+
+func TestCheckStrength(t *testing.T) {
+	for _, tc := range []struct {
+		password, username string
+		ok                 bool
+	}{
+		{"short", "ann", false},
+		{strings.Repeat("x", 73), "ann", false},
+		{"Password1", "ann", false},
+		{"xannieblue9", "annie", false},
+		{"aaaaaaaa", "ann", false},
+		{"abcdefghij", "ann", false},
+		{"98765432", "ann", false},
+		{"purple-otter-42", "ann", true},
+		{"hätte gern Tee", "ann", true},
+	} {
+		if msg := CheckStrength(tc.password, tc.username); (msg == "") != tc.ok {
+			t.Errorf("CheckStrength(%q, %q) = %q, want ok=%v", tc.password, tc.username, msg, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package passhash
+
+import (
+	_ "embed"
+	"strings"
+	"unicode/utf8"
+)
+
+// Password length limits. MaxBytes is bcrypt's input limit; it applies under
+// every policy so a password keeps working if the policy moves back to bcrypt.
+const (
+	MinLength = 8
+	MaxBytes  = 72
+)
+
+//go:embed common_passwords.txt
+var commonList string
+
+var common = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, line := range strings.Split(commonList, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			m[line] = true
+		}
+	}
+	return m
+}()
+
+// CheckStrength returns why password is too weak for the account username, or ""
+// if it is acceptable. Following NIST SP 800-63B it checks length and guessability
+// (common passwords, the username, repeated or sequential characters) rather than
+// requiring particular character classes.
+func CheckStrength(password, username string) string {
+	lower := strings.ToLower(password)
+	switch {
+	case utf8.RuneCountInString(password) < MinLength:
+		return "Password must be at least 8 characters"
+	case len(password) > MaxBytes:
+		return "Password must be at most 72 bytes"
+	case common[lower]:
+		return "Password is too common"
+	case len(username) >= 3 && strings.Contains(lower, strings.ToLower(username)):
+		return "Password must not contain the username"
+	case distinctRunes(password) < 4 || sequential(lower):
+		return "Password is too easy to guess"
+	}
+	return ""
+}
+
+// distinctRunes counts the different characters in s.
+func distinctRunes(s string) int {
+	seen := make(map[rune]bool)
+	for _, r := range s {
+		seen[r] = true
+	}
+	return len(seen)
+}
+
+// sequential reports whether s is one run of consecutive characters, like "abcdefgh" or "98765432".
+func sequential(s string) bool {
+	r := []rune(s)
+	step := r[1] - r[0]
+	if step != 1 && step != -1 {
+		return false
+	}
+	for i := 2; i < len(r); i++ {
+		if r[i]-r[i-1] != step {
+			return false
+		}
+	}
+	return true
+}
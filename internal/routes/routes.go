@@ -8,22 +8,31 @@ import (
 
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/handlers"
 	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/middleware"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
 )
 
-// Register registers all routes with Echo
-func Register(e *echo.Echo) {
+// Register registers all routes with Echo.
+// mw runs after tracing, in order: authentication (middleware.Session, middleware.Bearer),
+// which RequireRole/RequireLogin below rely on, then request guards such as middleware.CSRF.
+func Register(e *echo.Echo, h *handlers.Handler, mw ...echo.MiddlewareFunc) {
 	// --- Middleware ---
 	e.Use(echomw.Logger())  // Echo logger
 	e.Use(echomw.Recover()) // Echo recover
 	e.Use(echomw.CORSWithConfig(echomw.CORSConfig{
 		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000"},
-		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposeHeaders:    []string{"Link"},
+		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Link", "X-Total-Count", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 	e.Use(middleware.Tracing) // your custom tracing middleware
+	e.Use(mw...)
+
+	// --- Access Control ---
+	// Admins pass every RequireRole check; staff manage the catalogue; customers only shop.
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+	staffOnly := middleware.RequireRole(models.RoleStaff)
 
 	// --- App Home ---
 	e.GET("/", handlers.TestUI)
@@ -37,54 +46,121 @@ func Register(e *echo.Echo) {
 
 	// --- Authentication Flow ---
 	e.GET("/login", handlers.LoginForm)
-	e.POST("/login", handlers.Login)
+	e.POST("/login", h.Login)
+	e.GET("/login/2fa", handlers.LoginTwoFactorForm)
+	e.POST("/login/2fa", h.LoginTwoFactor)
 	e.GET("/logout", handlers.Logout)
 
+	// --- Account (sign-up, email verification, password reset) ---
+	e.GET("/register", h.RegisterForm)
+	e.POST("/register", h.Register)
+	e.GET("/verify-email", h.VerifyEmail)
+	e.GET("/verify-email/resend", h.ResendVerificationForm)
+	e.POST("/verify-email/resend", h.ResendVerification)
+	e.GET("/forgot-password", h.ForgotPasswordForm)
+	e.POST("/forgot-password", h.ForgotPassword)
+	e.GET("/reset-password", h.ResetPasswordForm)
+	e.POST("/reset-password", h.ResetPassword)
+
+	// --- Bearer Tokens (API clients) ---
+	auth := e.Group("/auth")
+	auth.POST("/token", h.IssueToken)
+	auth.POST("/refresh", h.RefreshToken)
+	auth.POST("/revoke", h.RevokeToken)
+
 	// --- Dashboard ---
-	e.GET("/dashboard", handlers.Dashboard)
+	e.GET("/dashboard", handlers.Dashboard, middleware.RequireLogin)
 
 	// --- Users API ---
+	// Registration (POST) is open; managing accounts is admin-only.
 	users := e.Group("/users")
-	users.GET("", handlers.GetUsers)
-	users.POST("", handlers.CreateUser)
-	users.GET("/:id", handlers.GetUserByID)
-	users.PUT("/:id", handlers.UpdateUser)
-	users.DELETE("/:id", handlers.DeleteUser)
+	users.GET("", h.GetUsers, adminOnly)
+	users.POST("", h.CreateUser)
+	users.GET("/:id", h.GetUserByID, adminOnly)
+	users.PUT("/:id", h.UpdateUser, adminOnly)
+	users.DELETE("/:id", h.DeleteUser, adminOnly)
 
 	// --- Books API ---
 	books := e.Group("/books")
-	books.GET("", handlers.GetBooks)
-	books.POST("", handlers.PostBook)
-	books.GET("/total", handlers.GetTotalBookPrice)
-	books.GET("/:id", handlers.GetBookByID)
-	books.PUT("/:id", handlers.UpdateBook)
-	books.DELETE("/:id", handlers.DeleteBook)
+	books.GET("", h.GetBooks)
+	books.POST("", h.PostBook, staffOnly)
+	books.GET("/total", h.GetTotalBookPrice)
+	books.GET("/:id", h.GetBookByID)
+	books.PUT("/:id", h.UpdateBook, staffOnly)
+	books.DELETE("/:id", h.DeleteBook, staffOnly)
 
 	// --- Albums API ---
 	albums := e.Group("/albums")
-	albums.GET("", handlers.GetAllAlbums)
-	albums.POST("", handlers.CreateAlbum)
-	albums.GET("/artist/:name", handlers.GetAlbumsByArtist)
-	albums.GET("/timeout", handlers.QueryWithTimeout)
-	albums.GET("/:id/can-purchase", handlers.CanPurchaseAlbum)
-	albums.GET("/:id", handlers.GetAlbumByID)
+	albums.GET("", h.GetAllAlbums)
+	albums.POST("", h.CreateAlbum, staffOnly)
+	albums.GET("/artist/:name", h.GetAlbumsByArtist)
+	albums.GET("/timeout", h.QueryWithTimeout)
+	albums.GET("/:id/can-purchase", h.CanPurchaseAlbum)
+	albums.GET("/:id", h.GetAlbumByID)
+	albums.PUT("/:id", h.UpdateAlbum, staffOnly)
+	albums.PATCH("/:id", h.PatchAlbum, staffOnly)
+	albums.DELETE("/:id", h.DeleteAlbum, staffOnly)
+	albums.POST("/:id/stock", h.AdjustAlbumStock, staffOnly)
 
 	// --- Orders API ---
-	orders := e.Group("/orders")
-	orders.GET("", handlers.GetOrdersByUser)
-	orders.POST("", handlers.CreateOrderByUser)
+	orders := e.Group("/orders", middleware.RequireLogin)
+	orders.GET("", h.GetOrdersByUser)
+	orders.POST("", h.CreateOrderByUser)
+	orders.GET("/:id", h.GetOrderByID)
+	orders.POST("/:id/cancel", h.CancelOrder)
+
+	// --- Customer Profile ---
+	profile := e.Group("/profile", middleware.RequireLogin)
+	profile.GET("", h.GetProfile)
+	profile.POST("", h.CreateProfile)
+	profile.PUT("", h.UpdateProfile)
+
+	// --- Login Sessions ---
+	me := e.Group("/me", middleware.RequireLogin)
+	me.GET("/sessions", h.GetSessions)
+	me.DELETE("/sessions", h.RevokeAllSessions)
+	me.DELETE("/sessions/:id", h.RevokeSession)
+
+	// --- Two-Factor Authentication ---
+	me.GET("/2fa", h.GetTwoFactor)
+	me.POST("/2fa/setup", h.SetupTwoFactor)
+	me.GET("/2fa/qr.png", h.TwoFactorQR)
+	me.POST("/2fa/enable", h.EnableTwoFactor)
+	me.POST("/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+	me.POST("/2fa/disable", h.DisableTwoFactor)
+
+	// --- Cart ---
+	cart := e.Group("/cart")
+	cart.GET("", h.GetCart)
+	cart.POST("/items", h.AddCartItem)
+	cart.PUT("/items/:album_id", h.UpdateCartItem)
+	cart.DELETE("/items/:album_id", h.RemoveCartItem)
+	cart.POST("/checkout", h.Checkout, middleware.RequireLogin)
 
 	// --- Misc Handlers ---
-	e.GET("/customer-name", handlers.GetCustomerName)
-	e.GET("/admin/multi-query", handlers.HandleMultipleResultSets)
+	e.GET("/customer-name", h.GetCustomerName, staffOnly)
+
+	// --- Admin ---
+	admin := e.Group("/admin", adminOnly)
+	admin.GET("/multi-query", h.HandleMultipleResultSets)
+	admin.PUT("/users/:id/role", h.SetUserRole)
+	admin.POST("/users/:id/unlock", h.UnlockUser)
+	admin.DELETE("/users/:id/2fa", h.ResetTwoFactor)
+	admin.GET("/login-attempts", h.GetLoginAttempts)
 
 	// --- Wiki Pages ---
 	e.GET("/view", func(c echo.Context) error {
 		return c.Redirect(http.StatusFound, "/view/FrontPage")
 	})
-	e.GET("/view/:title", handlers.ViewWiki)
-	e.GET("/edit/:title", handlers.EditWiki)
-	e.POST("/save/:title", handlers.SaveWiki)
+	e.GET("/view/:title", h.ViewWiki)
+	e.GET("/edit/:title", h.EditWiki)
+	e.POST("/save/:title", h.SaveWiki)
+	e.GET("/history/:title", h.HistoryWiki)
+	e.GET("/diff/:title", h.DiffWiki)
+	e.POST("/revert/:title", h.RevertWiki)
+	e.GET("/wiki/index", h.IndexWiki)
+	e.GET("/wiki/search", h.SearchWiki)
+	e.POST("/wiki/settings/:title", h.SetWikiSettings, adminOnly)
 
 	// --- JSON Utilities ---
 	e.POST("/json/encode", handlers.JsonEncode)
@@ -92,6 +168,8 @@ func Register(e *echo.Echo) {
 
 	// --- WebSocket ---
 	e.GET("/ws", handlers.Echo) // WebSocket upgrade
+	e.GET("/ws/chat", h.ChatSocket)
+	e.GET("/ws/events", h.EventsSocket, middleware.RequireLogin)
 	e.GET("/websockets", handlers.WebsocketPage)
 
 	// --- Concurrency ---
@@ -0,0 +1,200 @@
+// Package sessionstore is a gorilla sessions.Store that keeps session values in
+// a data.SessionRepository instead of the cookie. The cookie only carries a
+// signed random token, so deleting the row logs that browser out immediately.
+package sessionstore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// ErrRevoked is returned by Save for a session that was revoked while the request was handled.
+var ErrRevoked = errors.New("session has been revoked")
+
+// defaultMaxAge is the server-side lifetime of sessions whose cookie has no MaxAge.
+const defaultMaxAge = 8 * time.Hour
+
+// Store implements sessions.Store on top of a SessionRepository.
+type Store struct {
+	Repo    data.SessionRepository
+	Codecs  []securecookie.Codec
+	Options *sessions.Options // defaults for new sessions
+	// TouchInterval limits how often a request's last-seen time is written back.
+	TouchInterval time.Duration
+}
+
+// NewStore creates a Store. keyPairs are passed to securecookie like for
+// sessions.NewCookieStore: hash key, optional block key, repeated for rotation.
+func NewStore(repo data.SessionRepository, keyPairs ...[]byte) *Store {
+	return &Store{
+		Repo:          repo,
+		Codecs:        securecookie.CodecsFromPairs(keyPairs...),
+		Options:       &sessions.Options{Path: "/", MaxAge: int(defaultMaxAge.Seconds()), HttpOnly: true},
+		TouchInterval: time.Minute,
+	}
+}
+
+// PublicID is the repository ID of the session with cookie token token.
+// It identifies the session in listings without revealing the token.
+func PublicID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the named session, loading it once per request.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request cookie, or returns a new empty one
+// when there is no cookie or its session was revoked or has expired.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	rec, err := s.Repo.ByID(r.Context(), PublicID(token))
+	if errors.Is(err, data.ErrNotFound) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := (securecookie.GobEncoder{}).Deserialize(rec.Data, &session.Values); err != nil {
+		return session, err
+	}
+	session.ID = token
+	session.IsNew = false
+
+	now := time.Now()
+	ip, ua := clientIP(r), userAgent(r)
+	if now.Sub(rec.LastSeenAt) >= s.TouchInterval || rec.IP != ip || rec.UserAgent != ua {
+		if err := s.Repo.Touch(r.Context(), rec.ID, now, ip, ua); err != nil {
+			return session, err
+		}
+	}
+	return session, nil
+}
+
+// Save writes the session to the repository and sets the cookie.
+// A negative MaxAge deletes the session, which is how Logout ends it.
+// A loaded session whose row has been deleted since is not saved again: Save
+// clears the cookie and returns ErrRevoked.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Repo.Delete(r.Context(), PublicID(session.ID)); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	isNew := session.ID == ""
+	if isNew {
+		session.ID = newToken()
+	}
+	values, err := (securecookie.GobEncoder{}).Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	maxAge := time.Duration(session.Options.MaxAge) * time.Second
+	if maxAge == 0 {
+		maxAge = defaultMaxAge
+	}
+	userID, _ := session.Values["user_id"].(int64)
+	rec := models.Session{
+		ID:         PublicID(session.ID),
+		UserID:     userID,
+		Data:       values,
+		IP:         clientIP(r),
+		UserAgent:  userAgent(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(maxAge),
+	}
+	if isNew {
+		err = s.Repo.Create(r.Context(), rec)
+	} else {
+		err = s.Repo.Update(r.Context(), rec)
+	}
+	if errors.Is(err, data.ErrNotFound) {
+		// Revoked while this request was running: don't bring the row back.
+		expired := *session.Options
+		expired.MaxAge = -1
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", &expired))
+		return ErrRevoked
+	}
+	if err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// Renew moves session to a new token and deletes the old row, keeping its values.
+// Call it at login so a token planted before authentication (session fixation) stays anonymous.
+func (s *Store) Renew(r *http.Request, session *sessions.Session) error {
+	if session.ID != "" {
+		if err := s.Repo.Delete(r.Context(), PublicID(session.ID)); err != nil {
+			return err
+		}
+	}
+	session.ID = ""
+	return nil
+}
+
+// newToken returns a random 256-bit cookie token.
+func newToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// clientIP returns the address of the connecting client (not X-Forwarded-For, which the client controls).
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// userAgent returns the User-Agent header cut to the column size.
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > 255 {
+		ua = strings.ToValidUTF8(ua[:255], "")
+	}
+	return ua
+}
@@ -0,0 +1,143 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+)
+
+// roundTrip saves values in a new session and returns the cookie that names it.
+func roundTrip(t *testing.T, s *Store, values map[any]any) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := s.Get(req, "session")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	rec := httptest.NewRecorder()
+	if err := session.Save(req, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected one cookie, got %d", len(cookies))
+	}
+	return cookies[0]
+}
+
+func load(t *testing.T, s *Store, cookie *http.Cookie) map[any]any {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	session, err := s.Get(req, "session")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	return session.Values
+}
+
+func TestStoreKeepsValuesServerSide(t *testing.T) {
+	repo := data.NewMemorySessionRepo()
+	s := NewStore(repo, []byte("0123456789abcdef0123456789abcdef"))
+	cookie := roundTrip(t, s, map[any]any{"authenticated": true, "user_id": int64(7)})
+
+	if got := load(t, s, cookie)["user_id"]; got != int64(7) {
+		t.Fatalf("user_id = %v, want 7", got)
+	}
+
+	list, _ := repo.ByUser(context.Background(), 7)
+	if len(list) != 1 {
+		t.Fatalf("expected 1 stored session for user 7, got %d", len(list))
+	}
+	if list[0].ID == cookie.Value {
+		t.Error("repository ID must not be the cookie value")
+	}
+}
+
+func TestRevokedSessionIsAnonymous(t *testing.T) {
+	repo := data.NewMemorySessionRepo()
+	s := NewStore(repo, []byte("0123456789abcdef0123456789abcdef"))
+	cookie := roundTrip(t, s, map[any]any{"authenticated": true, "user_id": int64(7)})
+
+	if n, _ := repo.DeleteAllForUser(context.Background(), 7); n != 1 {
+		t.Fatalf("DeleteAllForUser removed %d sessions, want 1", n)
+	}
+	if values := load(t, s, cookie); len(values) != 0 {
+		t.Fatalf("revoked cookie still carries values: %v", values)
+	}
+}
+
+func TestTamperedCookieIsRejected(t *testing.T) {
+	s := NewStore(data.NewMemorySessionRepo(), []byte("0123456789abcdef0123456789abcdef"))
+	cookie := roundTrip(t, s, map[any]any{"user_id": int64(7)})
+	cookie.Value = "x" + cookie.Value[1:]
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	session, err := s.Get(req, "session")
+	if err == nil || !session.IsNew || len(session.Values) != 0 {
+		t.Fatalf("tampered cookie accepted: err=%v values=%v", err, session.Values)
+	}
+}
+
+func TestRenewIssuesNewToken(t *testing.T) {
+	repo := data.NewMemorySessionRepo()
+	s := NewStore(repo, []byte("0123456789abcdef0123456789abcdef"))
+	before := roundTrip(t, s, map[any]any{"cart": "kept"})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.AddCookie(before)
+	session, _ := s.Get(req, "session")
+	if err := s.Renew(req, session); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	session.Values["user_id"] = int64(7)
+	rec := httptest.NewRecorder()
+	if err := session.Save(req, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	after := rec.Result().Cookies()[0]
+
+	if values := load(t, s, before); len(values) != 0 {
+		t.Errorf("pre-login cookie still valid: %v", values)
+	}
+	if got := load(t, s, after)["cart"]; got != "kept" {
+		t.Errorf("values lost on renew: cart = %v", got)
+	}
+}
+
+func TestSaveAfterRevokeDoesNotRecreate(t *testing.T) {
+	repo := data.NewMemorySessionRepo()
+	s := NewStore(repo, []byte("0123456789abcdef0123456789abcdef"))
+	cookie := roundTrip(t, s, map[any]any{"authenticated": true, "user_id": int64(7)})
+
+	// A request from this device loads the session, then the user revokes it elsewhere.
+	req := httptest.NewRequest(http.MethodPost, "/cart/items", nil)
+	req.AddCookie(cookie)
+	session, _ := s.Get(req, "session")
+	if n, _ := repo.DeleteAllForUser(context.Background(), 7); n != 1 {
+		t.Fatalf("DeleteAllForUser removed %d sessions, want 1", n)
+	}
+
+	session.Values["cart"] = "changed"
+	rec := httptest.NewRecorder()
+	if err := session.Save(req, rec); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("Save of a revoked session: err = %v, want ErrRevoked", err)
+	}
+	if c := rec.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("cookie not cleared: %v", c)
+	}
+	if list, _ := repo.ByUser(context.Background(), 7); len(list) != 0 {
+		t.Fatalf("revoked session was re-created: %+v", list)
+	}
+	if values := load(t, s, cookie); len(values) != 0 {
+		t.Errorf("revoked cookie carries values again: %v", values)
+	}
+}
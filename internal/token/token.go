@@ -0,0 +1,237 @@
+// Package token issues and verifies the JWT bearer tokens used by API clients
+// that can't keep the gorilla session cookie.
+//
+// A successful POST /auth/token creates a grant (see models.Grant) and returns
+// a short-lived access token and a long-lived refresh token, both naming the grant.
+// Refreshing rotates the refresh token; replaying an old one revokes the whole grant.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, of the wrong type, or revoked.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+const (
+	typeAccess  = "access"
+	typeRefresh = "refresh"
+)
+
+// Key is an HMAC signing key identified by the JWT "kid" header.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKeys parses "kid:secret,kid:secret". The first key signs new tokens;
+// all keys verify, so a rotated-out key keeps working until its tokens expire.
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, part := range strings.Split(spec, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || id == "" || len(secret) < 32 {
+			return nil, fmt.Errorf("invalid key %q: want kid:secret with a secret of at least 32 bytes", id)
+		}
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// Claims are the JWT claims of both token types.
+type Claims struct {
+	Type string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// Pair is the response of a token grant or refresh.
+type Pair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// Service issues, refreshes, verifies and revokes tokens.
+type Service struct {
+	Keys       []Key // Keys[0] signs
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	Grants     data.GrantRepository
+}
+
+// NewService creates a Service; keys must not be empty.
+func NewService(keys []Key, accessTTL, refreshTTL time.Duration, grants data.GrantRepository) (*Service, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("token: at least one signing key is required")
+	}
+	return &Service{Keys: keys, AccessTTL: accessTTL, RefreshTTL: refreshTTL, Grants: grants}, nil
+}
+
+// Issue creates a new grant for userID and returns its first token pair.
+func (s *Service) Issue(ctx context.Context, userID int64) (Pair, error) {
+	now := time.Now()
+	g := models.Grant{
+		ID:         newID(),
+		UserID:     userID,
+		RefreshJTI: newID(),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(s.RefreshTTL),
+	}
+	if err := s.Grants.Create(ctx, g); err != nil {
+		return Pair{}, err
+	}
+	return s.pair(g, now)
+}
+
+// Refresh exchanges a refresh token for a new pair and invalidates the old refresh token.
+func (s *Service) Refresh(ctx context.Context, raw string) (Pair, error) {
+	claims, err := s.parse(raw, typeRefresh)
+	if err != nil {
+		return Pair{}, err
+	}
+	g, err := s.activeGrant(ctx, claims)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	newJTI := newID()
+	ok, err := s.Grants.Rotate(ctx, g.ID, claims.ID, newJTI)
+	if err != nil {
+		return Pair{}, err
+	}
+	if !ok {
+		// The token was valid but already exchanged: someone else holds a copy.
+		if err := s.Grants.Revoke(ctx, g.ID); err != nil {
+			return Pair{}, err
+		}
+		return Pair{}, ErrInvalidToken
+	}
+	g.RefreshJTI = newJTI
+	return s.pair(g, time.Now())
+}
+
+// VerifyAccess checks an access token and returns its user ID.
+func (s *Service) VerifyAccess(ctx context.Context, raw string) (int64, error) {
+	claims, err := s.parse(raw, typeAccess)
+	if err != nil {
+		return 0, err
+	}
+	g, err := s.activeGrant(ctx, claims)
+	if err != nil {
+		return 0, err
+	}
+	return g.UserID, nil
+}
+
+// Revoke revokes the grant behind an access or refresh token, invalidating every token issued from it.
+// It returns the grant's user ID.
+func (s *Service) Revoke(ctx context.Context, raw string) (int64, error) {
+	claims, err := s.parse(raw, "")
+	if err != nil {
+		return 0, err
+	}
+	g, err := s.activeGrant(ctx, claims)
+	if err != nil {
+		return 0, err
+	}
+	return g.UserID, s.Grants.Revoke(ctx, g.ID)
+}
+
+// RevokeAll revokes every grant of userID.
+func (s *Service) RevokeAll(ctx context.Context, userID int64) error {
+	return s.Grants.RevokeAllForUser(ctx, userID)
+}
+
+// pair signs an access and a refresh token for g.
+func (s *Service) pair(g models.Grant, now time.Time) (Pair, error) {
+	access, err := s.sign(Claims{Type: typeAccess, RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(g.UserID, 10),
+		ID:        g.ID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.AccessTTL)),
+	}})
+	if err != nil {
+		return Pair{}, err
+	}
+	refresh, err := s.sign(Claims{Type: typeRefresh, RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(g.UserID, 10),
+		ID:        g.RefreshJTI,
+		Audience:  jwt.ClaimStrings{g.ID}, // the grant; access tokens carry it as their ID
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(g.ExpiresAt),
+	}})
+	if err != nil {
+		return Pair{}, err
+	}
+	return Pair{AccessToken: access, RefreshToken: refresh, TokenType: "Bearer", ExpiresIn: int(s.AccessTTL.Seconds())}, nil
+}
+
+// grantID returns the grant a token belongs to.
+func grantID(c *Claims) string {
+	if c.Type == typeRefresh && len(c.Audience) == 1 {
+		return c.Audience[0]
+	}
+	return c.ID
+}
+
+// activeGrant loads the token's grant and checks it hasn't been revoked or expired.
+func (s *Service) activeGrant(ctx context.Context, c *Claims) (models.Grant, error) {
+	g, err := s.Grants.ByID(ctx, grantID(c))
+	if errors.Is(err, data.ErrNotFound) {
+		return g, ErrInvalidToken
+	}
+	if err != nil {
+		return g, err
+	}
+	if !g.Active(time.Now()) || strconv.FormatInt(g.UserID, 10) != c.Subject {
+		return g, ErrInvalidToken
+	}
+	return g, nil
+}
+
+func (s *Service) sign(c Claims) (string, error) {
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+	t.Header["kid"] = s.Keys[0].ID
+	return t.SignedString(s.Keys[0].Secret)
+}
+
+// parse verifies the signature and expiry of raw; typ "" accepts either token type.
+func (s *Service) parse(raw, typ string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		for _, k := range s.Keys {
+			if k.ID == kid {
+				return k.Secret, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if (typ != "" && claims.Type != typ) || (claims.Type != typeAccess && claims.Type != typeRefresh) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// newID returns a random 128-bit hex identifier.
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,122 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+)
+
+func newTestService(t *testing.T, spec string) *Service {
+	t.Helper()
+	keys, err := ParseKeys(spec)
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	s, err := NewService(keys, time.Minute, time.Hour, data.NewMemoryGrantRepo())
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+const (
+	oldKey = "k1:0123456789abcdef0123456789abcdef"
+	newKey = "k2:fedcba9876543210fedcba9876543210"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, oldKey)
+
+	pair, err := s.Issue(ctx, 7)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if got, err := s.VerifyAccess(ctx, pair.AccessToken); err != nil || got != 7 {
+		t.Fatalf("VerifyAccess = %d, %v; want 7, nil", got, err)
+	}
+	if _, err := s.VerifyAccess(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh token accepted as access token: %v", err)
+	}
+	tampered := pair.AccessToken[:len(pair.AccessToken)-2] + "xx"
+	if _, err := s.VerifyAccess(ctx, tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered token accepted: %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesGrant(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, oldKey)
+
+	first, _ := s.Issue(ctx, 7)
+	second, err := s.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// Replaying the used refresh token must fail and kill the whole grant.
+	if _, err := s.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reused refresh token: got %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.VerifyAccess(ctx, second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token still valid after refresh reuse: %v", err)
+	}
+	if _, err := s.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("newest refresh token still valid after reuse: %v", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, oldKey)
+
+	a, _ := s.Issue(ctx, 7)
+	b, _ := s.Issue(ctx, 7)
+	if _, err := s.Revoke(ctx, a.RefreshToken); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := s.VerifyAccess(ctx, a.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token valid after revoking its refresh token: %v", err)
+	}
+	if _, err := s.VerifyAccess(ctx, b.AccessToken); err != nil {
+		t.Errorf("other grant affected by Revoke: %v", err)
+	}
+
+	if err := s.RevokeAll(ctx, 7); err != nil {
+		t.Fatalf("RevokeAll: %v", err)
+	}
+	if _, err := s.VerifyAccess(ctx, b.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token valid after RevokeAll: %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	before := newTestService(t, oldKey)
+	pair, _ := before.Issue(ctx, 7)
+
+	// New key first: tokens signed with the old key still verify.
+	after := newTestService(t, newKey+","+oldKey)
+	after.Grants = before.Grants
+	if _, err := after.VerifyAccess(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("token signed with the previous key rejected: %v", err)
+	}
+
+	// Old key dropped: they no longer do.
+	retired := newTestService(t, newKey)
+	retired.Grants = before.Grants
+	if _, err := retired.VerifyAccess(ctx, pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with a retired key accepted: %v", err)
+	}
+}
+
+func TestParseKeysRejectsShortSecrets(t *testing.T) {
+	_, err := ParseKeys("k1:short")
+	if err == nil || !strings.Contains(err.Error(), "32 bytes") {
+		t.Fatalf("expected short secret error, got %v", err)
+	}
+}
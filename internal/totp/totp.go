@@ -0,0 +1,113 @@
+// Package totp implements RFC 6238 time-based one-time passwords (the 6-digit
+// codes of authenticator apps) and the recovery codes that stand in for them
+// when the device is lost.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parameters shared with authenticator apps; most only support these values.
+const (
+	Digits = 6
+	Period = 30 * time.Second
+)
+
+// Skew is how many periods before or after the current one a code is accepted,
+// to allow for clock drift and slow typing.
+const Skew = 1
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160-bit secret, base32-encoded as authenticator apps expect.
+func NewSecret() string {
+	b := make([]byte, 20)
+	_, _ = rand.Read(b)
+	return b32.EncodeToString(b)
+}
+
+// Step returns the time step t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code for secret at the given time step.
+func Code(secret string, step int64) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("totp: invalid secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3).
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, bin%1_000_000), nil
+}
+
+// Validate checks code against secret at time t, allowing Skew steps either way.
+// It returns the matched step so callers can refuse to accept it twice.
+func Validate(secret, code string, t time.Time) (step int64, ok bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+	now := Step(t)
+	for s := now - Skew; s <= now+Skew; s++ {
+		want, err := Code(secret, s)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth:// URI that authenticator apps import (usually from a QR code).
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// NewRecoveryCodes returns n random recovery codes formatted as XXXXX-XXXXX.
+func NewRecoveryCodes(n int) []string {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		_, _ = rand.Read(b)
+		s := b32.EncodeToString(b)[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes
+}
+
+// HashRecoveryCode returns the stored form of a recovery code. Case, spaces and
+// dashes are ignored. The codes are random, so a fast hash is enough.
+func HashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test key ("12345678901234567890") and SHA-1 vectors,
+// truncated to 6 digits.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		got, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("Code at %d = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	prev, _ := Code(rfcSecret, Step(now)-1)
+	old, _ := Code(rfcSecret, Step(now)-2)
+
+	if step, ok := Validate(rfcSecret, prev, now); !ok || step != Step(now)-1 {
+		t.Errorf("previous period's code: step %d, ok %v", step, ok)
+	}
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Error("code from two periods ago should be rejected")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("short code should be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Shop Admin", "ann@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Shop%20Admin:ann@example.com?") {
+		t.Errorf("unexpected label: %s", uri)
+	}
+	for _, want := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=Shop+Admin", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("URI lacks %q: %s", want, uri)
+		}
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	codes := NewRecoveryCodes(2)
+	if codes[0] == codes[1] || len(codes[0]) != 11 {
+		t.Fatalf("unexpected codes %q", codes)
+	}
+	typed := strings.ToLower(strings.ReplaceAll(codes[0], "-", " "))
+	if HashRecoveryCode(typed) != HashRecoveryCode(codes[0]) {
+		t.Error("hash should ignore case, spaces and dashes")
+	}
+}
@@ -0,0 +1,299 @@
+// Package wikiindex keeps an in-memory index of the wiki: the page list with
+// modification times, ranked full-text search with highlighted snippets, and
+// "what links here" lists computed from [[...]] links. It is built from a
+// data.PageStore at startup and updated with each saved revision.
+package wikiindex
+
+import (
+	"context"
+	"html"
+	"html/template"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/data"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/markup"
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+// BM25 parameters, and the extra weight of a query term found in a page's title.
+const (
+	k1          = 1.2
+	b           = 0.75
+	titleWeight = 3.0
+)
+
+// snippetLen is the length of a search snippet, in characters.
+const snippetLen = 160
+
+// Entry is a page in the index.
+type Entry struct {
+	Title    string
+	Revision int
+	Modified time.Time
+}
+
+// Result is a page matching a search, with a snippet of its text around the
+// matches. Snippet is escaped HTML with the matched words in <mark>.
+type Result struct {
+	Entry
+	Score   float64
+	Snippet template.HTML
+}
+
+// page is an indexed page.
+type page struct {
+	Entry
+	body   string
+	terms  map[string]int // term frequencies of the body
+	title  map[string]bool
+	length int      // number of terms in the body
+	links  []string // normalised [[...]] targets, without duplicates
+}
+
+// Index is the wiki index. It is safe for concurrent use.
+type Index struct {
+	mu    sync.RWMutex
+	pages map[string]*page
+	total int // sum of page lengths
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{pages: make(map[string]*page)}
+}
+
+// Build returns an index of the current revision of every page in store.
+func Build(ctx context.Context, store data.PageStore) (*Index, error) {
+	titles, err := store.Titles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := New()
+	for _, title := range titles {
+		rev, err := store.Latest(ctx, title)
+		if err != nil {
+			return nil, err
+		}
+		idx.Update(title, rev)
+	}
+	return idx, nil
+}
+
+// Update indexes rev as the current text of title. A revision older than the
+// indexed one is ignored, so concurrent saves may report in any order.
+func (idx *Index) Update(title string, rev models.WikiRevision) {
+	p := &page{
+		Entry: Entry{Title: title, Revision: rev.ID, Modified: rev.Time},
+		body:  rev.Body,
+		terms: make(map[string]int),
+		title: make(map[string]bool),
+	}
+	for _, t := range tokenize(rev.Body) {
+		p.terms[t.term]++
+		p.length++
+	}
+	for _, t := range tokenize(title) {
+		p.title[t.term] = true
+	}
+	for _, target := range markup.Links([]byte(rev.Body)) {
+		if target, err := data.NormalizeTitle(target); err == nil && !slices.Contains(p.links, target) {
+			p.links = append(p.links, target)
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	old := idx.pages[title]
+	if old != nil {
+		if old.Revision > rev.ID {
+			return
+		}
+		idx.total -= old.length
+	}
+	idx.pages[title] = p
+	idx.total += p.length
+}
+
+// Pages lists the indexed pages by title.
+func (idx *Index) Pages() []Entry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(idx.pages))
+	for _, p := range idx.pages {
+		entries = append(entries, p.Entry)
+	}
+	slices.SortFunc(entries, func(x, y Entry) int { return strings.Compare(x.Title, y.Title) })
+	return entries
+}
+
+// Backlinks lists the pages that link to title, by title.
+func (idx *Index) Backlinks(title string) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var from []string
+	for _, p := range idx.pages {
+		if p.Title != title && slices.Contains(p.links, title) {
+			from = append(from, p.Title)
+		}
+	}
+	slices.Sort(from)
+	return from
+}
+
+// Search returns up to limit pages matching any word of q, best first. Pages are
+// ranked by BM25 over their text, with a bonus for words in the title.
+func (idx *Index) Search(q string, limit int) []Result {
+	var terms []string
+	for _, t := range tokenize(q) {
+		if !slices.Contains(terms, t.term) {
+			terms = append(terms, t.term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.pages))
+	avgLen := 1.0
+	if idx.total > 0 {
+		avgLen = float64(idx.total) / n
+	}
+	idfs := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		df := 0
+		for _, p := range idx.pages {
+			if p.terms[term] > 0 || p.title[term] {
+				df++
+			}
+		}
+		idfs[term] = math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	}
+
+	var results []Result
+	for _, p := range idx.pages {
+		score := 0.0
+		for _, term := range terms {
+			idf := idfs[term]
+			if tf := float64(p.terms[term]); tf > 0 {
+				score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(p.length)/avgLen))
+			}
+			if p.title[term] {
+				score += titleWeight * idf
+			}
+		}
+		if score > 0 {
+			results = append(results, Result{Entry: p.Entry, Score: score, Snippet: snippet(p.body, terms)})
+		}
+	}
+	slices.SortFunc(results, func(x, y Result) int {
+		if x.Score != y.Score {
+			if x.Score > y.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(x.Title, y.Title)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// token is a word of text: its lower-cased term and byte offsets.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits s into runs of letters and digits.
+func tokenize(s string) []token {
+	var toks []token
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			toks = append(toks, token{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, token{strings.ToLower(s[start:]), start, len(s)})
+	}
+	return toks
+}
+
+// snippet returns about snippetLen characters of body around the place where
+// the most distinct terms occur close together, escaped, with those terms marked.
+func snippet(body string, terms []string) template.HTML {
+	toks := tokenize(body)
+	var hits []token
+	for _, t := range toks {
+		if slices.Contains(terms, t.term) {
+			hits = append(hits, t)
+		}
+	}
+
+	// Start at the hit followed by the most distinct terms within snippetLen bytes.
+	start, best := 0, 0
+	for i, h := range hits {
+		seen := map[string]bool{}
+		for _, o := range hits[i:] {
+			if o.end-h.start > snippetLen {
+				break
+			}
+			seen[o.term] = true
+		}
+		if len(seen) > best {
+			start, best = h.start, len(seen)
+		}
+	}
+	// Back up a little for context (more near the end of the page), to the start of a word.
+	from := max(0, min(start-snippetLen/4, len(body)-snippetLen))
+	for from > 0 && from < start && !isSpace(body, from-1) {
+		from++
+	}
+	runes := 0
+	to := from
+	for to < len(body) && runes < snippetLen {
+		_, size := utf8.DecodeRuneInString(body[to:])
+		to += size
+		runes++
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("… ")
+	}
+	pos := from
+	for _, h := range hits {
+		if h.start < from || h.end > to {
+			continue
+		}
+		sb.WriteString(html.EscapeString(body[pos:h.start]))
+		sb.WriteString("<mark>" + html.EscapeString(body[h.start:h.end]) + "</mark>")
+		pos = h.end
+	}
+	sb.WriteString(html.EscapeString(body[pos:to]))
+	if to < len(body) {
+		sb.WriteString(" …")
+	}
+	return template.HTML(strings.Join(strings.Fields(sb.String()), " "))
+}
+
+func isSpace(s string, i int) bool {
+	return s[i] == ' ' || s[i] == '\n' || s[i] == '\t' || s[i] == '\r'
+}
@@ -0,0 +1,86 @@
+package wikiindex
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shahinzaman102/Go_JumpStart_Echo/internal/models"
+)
+
+func testIndex() *Index {
+	idx := New()
+	now := time.Now()
+	idx.Update("Gophers", models.WikiRevision{ID: 1, Time: now, Body: "All about the Go mascot. See [[Go Tips]]."})
+	idx.Update("Go Tips", models.WikiRevision{ID: 2, Time: now, Body: "Use gofmt. Channels and goroutines make concurrency easy; channels are typed."})
+	idx.Update("Cooking", models.WikiRevision{ID: 1, Time: now, Body: "Recipes. Nothing about code. [[Gophers]] [[Go Tips]] <script>x</script> channels"})
+	return idx
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := testIndex()
+
+	var titles []string
+	for _, r := range idx.Search("channels", 10) {
+		titles = append(titles, r.Title)
+	}
+	if want := []string{"Go Tips", "Cooking"}; !slices.Equal(titles, want) {
+		t.Errorf("Search(channels) = %q, want %q (more occurrences first)", titles, want)
+	}
+
+	if res := idx.Search("gophers", 10); len(res) == 0 || res[0].Title != "Gophers" {
+		t.Errorf("Search(gophers) = %+v, want the page titled Gophers first", res)
+	}
+	if res := idx.Search("  ", 10); res != nil {
+		t.Errorf("Search(blank) = %+v, want nothing", res)
+	}
+	if res := idx.Search("channels", 1); len(res) != 1 {
+		t.Errorf("Search with limit 1 returned %d results", len(res))
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	res := testIndex().Search("script channels", 10)
+	var cooking Result
+	for _, r := range res {
+		if r.Title == "Cooking" {
+			cooking = r
+		}
+	}
+	got := string(cooking.Snippet)
+	if strings.Contains(got, "<script>") || !strings.Contains(got, "&lt;<mark>script</mark>&gt;") ||
+		!strings.Contains(got, "<mark>channels</mark>") {
+		t.Errorf("snippet = %q, want escaped text with the matches marked", got)
+	}
+
+	long := strings.Repeat("filler words ", 40) + "needle" + strings.Repeat(" more text", 40)
+	got = string(snippet(long, []string{"needle"}))
+	if !strings.HasPrefix(got, "… ") || !strings.HasSuffix(got, " …") || !strings.Contains(got, "<mark>needle</mark>") {
+		t.Errorf("snippet of a long page = %q, want an elided window around the match", got)
+	}
+}
+
+func TestBacklinksFollowUpdates(t *testing.T) {
+	idx := testIndex()
+	if got := idx.Backlinks("Go Tips"); !slices.Equal(got, []string{"Cooking", "Gophers"}) {
+		t.Errorf("Backlinks(Go Tips) = %q", got)
+	}
+
+	idx.Update("Cooking", models.WikiRevision{ID: 2, Body: "No links now."})
+	idx.Update("Cooking", models.WikiRevision{ID: 1, Body: "[[Go Tips]]"}) // stale, ignored
+	if got := idx.Backlinks("Go Tips"); !slices.Equal(got, []string{"Gophers"}) {
+		t.Errorf("Backlinks(Go Tips) after edit = %q", got)
+	}
+	if res := idx.Search("recipes", 10); len(res) != 0 {
+		t.Errorf("Search found text removed by an edit: %+v", res)
+	}
+
+	var titles []string
+	for _, e := range idx.Pages() {
+		titles = append(titles, e.Title)
+	}
+	if want := []string{"Cooking", "Go Tips", "Gophers"}; !slices.Equal(titles, want) {
+		t.Errorf("Pages = %q, want %q", titles, want)
+	}
+}
@@ -0,0 +1,10 @@
+package assets
+
+import "embed"
+
+//go:embed migrations/*/*.sql
+var Migrations embed.FS
+
+// - one directory per SQL dialect: migrations/mysql, migrations/sqlite
+// - files are named <version>_<name>.up.sql / <version>_<name>.down.sql
+// - internal/migrations applies them in version order
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS book (
+    id      INT AUTO_INCREMENT PRIMARY KEY,
+    title   VARCHAR(200) NOT NULL,
+    author  VARCHAR(100) NOT NULL,
+    price   DECIMAL(10,2) NOT NULL DEFAULT 0,
+    version INT NOT NULL DEFAULT 1
+);
+
+INSERT IGNORE INTO book (id, title, author, price)
+VALUES
+    (1, 'Blue Train', 'John Coltrane', 56.99),
+    (2, 'Jeru', 'Gerry Mulligan', 17.99),
+    (3, 'Sarah Vaughan and Clifford Brown', 'Sarah Vaughan', 39.99);
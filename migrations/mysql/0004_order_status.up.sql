@@ -0,0 +1 @@
+ALTER TABLE album_order ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'pending';
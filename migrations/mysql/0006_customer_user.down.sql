@@ -0,0 +1,3 @@
+ALTER TABLE customer DROP FOREIGN KEY fk_customer_user;
+DROP INDEX customer_user_id ON customer;
+ALTER TABLE customer DROP COLUMN user_id;
@@ -0,0 +1,4 @@
+-- Links a login (users) to at most one customer record.
+ALTER TABLE customer ADD COLUMN user_id INT NULL;
+CREATE UNIQUE INDEX customer_user_id ON customer (user_id);
+ALTER TABLE customer ADD CONSTRAINT fk_customer_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
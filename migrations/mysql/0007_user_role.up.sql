@@ -0,0 +1,2 @@
+-- admin, staff or customer; see models.Role*.
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'customer';
@@ -0,0 +1,12 @@
+-- Audit trail of login attempts (form login and POST /auth/token), also used to throttle guessing.
+-- username is what was typed, so attempts against unknown accounts are recorded too.
+-- outcome: success | failure | blocked (rejected by the throttle) | unlock (admin reset).
+CREATE TABLE IF NOT EXISTS login_attempt (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    outcome VARCHAR(16) NOT NULL,
+    created_at DATETIME(6) NOT NULL
+);
+CREATE INDEX login_attempt_username ON login_attempt (username, created_at);
+CREATE INDEX login_attempt_ip ON login_attempt (ip, created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wiki_revision;
+DROP TABLE IF EXISTS wiki_page;
@@ -0,0 +1,18 @@
+-- Wiki pages (when WIKI_STORE=db). Every save adds a wiki_revision row;
+-- wiki_page.revision names the current one. Titles compare case-sensitively,
+-- like the file names they replace.
+CREATE TABLE IF NOT EXISTS wiki_page (
+    title VARCHAR(200) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin PRIMARY KEY,
+    revision INT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS wiki_revision (
+    title VARCHAR(200) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+    id INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    summary VARCHAR(255) NOT NULL DEFAULT '',
+    body MEDIUMTEXT CHARACTER SET utf8mb4 NOT NULL,
+    PRIMARY KEY (title, id),
+    FOREIGN KEY (title) REFERENCES wiki_page(title) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE wiki_page DROP COLUMN anonymous_edits;
+ALTER TABLE wiki_page DROP COLUMN protected;
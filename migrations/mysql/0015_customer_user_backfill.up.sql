@@ -0,0 +1,9 @@
+-- Before 0006 orders were placed with the user's ID as cust_id, so customer N belonged to user N.
+-- Link those customers to their users so the orders stay visible. Users who have since
+-- created a profile keep it. This is a new migration rather than part of 0006 so that
+-- databases which already ran 0006 get it too.
+-- The DISTINCT derived table is materialised, which MySQL requires to read the table being updated.
+UPDATE customer SET user_id = id
+WHERE user_id IS NULL
+  AND id IN (SELECT id FROM users)
+  AND id NOT IN (SELECT user_id FROM (SELECT DISTINCT user_id FROM customer WHERE user_id IS NOT NULL) AS linked);
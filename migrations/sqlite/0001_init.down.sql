@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS album_order;
+DROP TABLE IF EXISTS customer;
+DROP TABLE IF EXISTS album;
+DROP TABLE IF EXISTS users;
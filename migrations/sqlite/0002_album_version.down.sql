@@ -0,0 +1 @@
+ALTER TABLE album DROP COLUMN version;
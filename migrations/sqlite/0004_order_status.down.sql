@@ -0,0 +1 @@
+ALTER TABLE album_order DROP COLUMN status;
@@ -0,0 +1,15 @@
+-- Order lines. album_order.album_id/quantity keep describing the first line and the
+-- total units so single-line clients keep working; order_item is the source of truth.
+CREATE TABLE IF NOT EXISTS order_item (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    album_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    price DECIMAL(5,2) NOT NULL,
+    FOREIGN KEY (order_id) REFERENCES album_order(id) ON DELETE CASCADE,
+    FOREIGN KEY (album_id) REFERENCES album(id)
+);
+
+INSERT INTO order_item (order_id, album_id, quantity, price)
+SELECT o.id, o.album_id, o.quantity, a.price
+FROM album_order o JOIN album a ON a.id = o.album_id;
@@ -0,0 +1,2 @@
+DROP INDEX customer_user_id;
+ALTER TABLE customer DROP COLUMN user_id;
@@ -0,0 +1,3 @@
+-- Links a login (users) to at most one customer record.
+ALTER TABLE customer ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX customer_user_id ON customer (user_id);
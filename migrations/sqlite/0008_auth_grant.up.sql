@@ -0,0 +1,12 @@
+-- One row per bearer-token login. Access tokens carry their grant ID as jti so revoking
+-- the grant invalidates them; refresh_jti is the only refresh token still accepted.
+CREATE TABLE IF NOT EXISTS auth_grant (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    refresh_jti VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX auth_grant_user ON auth_grant (user_id);
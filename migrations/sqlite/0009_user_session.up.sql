@@ -0,0 +1,16 @@
+-- Server-side gorilla sessions. The cookie holds a random token; id is its SHA-256,
+-- so neither this table nor GET /me/sessions reveals a usable cookie.
+-- user_id is NULL until login (anonymous sessions still carry the cart).
+CREATE TABLE IF NOT EXISTS user_session (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NULL,
+    data BLOB NOT NULL,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX user_session_user ON user_session (user_id);
+CREATE INDEX user_session_expires ON user_session (expires_at);
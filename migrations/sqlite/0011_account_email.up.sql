@@ -0,0 +1,18 @@
+-- Email address for self-service sign-up, verification and password reset.
+-- NULL for accounts created without one (POST /users); unique when present.
+ALTER TABLE users ADD COLUMN email VARCHAR(255) NULL;
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME NULL;
+CREATE UNIQUE INDEX users_email ON users (email);
+
+-- Single-use emailed tokens (verify_email, reset_password). id is the SHA-256 of
+-- the token in the link, so the table alone can't be used to take over an account.
+CREATE TABLE IF NOT EXISTS user_token (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    purpose VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX user_token_user ON user_token (user_id, purpose);
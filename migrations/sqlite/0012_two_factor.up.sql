@@ -0,0 +1,21 @@
+-- TOTP two-factor authentication. A row with enabled_at NULL is an enrollment
+-- that hasn't been confirmed with a code yet. last_step is the time step of the
+-- last accepted code, so a code can't be replayed.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id INTEGER PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    enabled_at DATETIME NULL,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One-time recovery codes, stored as SHA-256 hashes.
+CREATE TABLE IF NOT EXISTS user_recovery_code (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX user_recovery_code_user ON user_recovery_code (user_id, code_hash);
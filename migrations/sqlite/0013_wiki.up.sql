@@ -0,0 +1,17 @@
+-- Wiki pages (when WIKI_STORE=db). Every save adds a wiki_revision row;
+-- wiki_page.revision names the current one.
+CREATE TABLE IF NOT EXISTS wiki_page (
+    title VARCHAR(200) PRIMARY KEY,
+    revision INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS wiki_revision (
+    title VARCHAR(200) NOT NULL,
+    id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    summary VARCHAR(255) NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    PRIMARY KEY (title, id),
+    FOREIGN KEY (title) REFERENCES wiki_page(title) ON DELETE CASCADE
+);
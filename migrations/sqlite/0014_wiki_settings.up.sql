@@ -0,0 +1,3 @@
+-- Who may edit a wiki page; see models.WikiPageSettings.
+ALTER TABLE wiki_page ADD COLUMN protected BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE wiki_page ADD COLUMN anonymous_edits BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Also unlinks profiles created later whose ID happens to equal their user's.
+UPDATE customer SET user_id = NULL WHERE user_id = id;
@@ -21,6 +21,9 @@ function parseFormData(form) {
     return obj;
 }
 
+// CSRF token of this page's session, rendered into <meta name="csrf-token">
+const csrfToken = document.querySelector('meta[name="csrf-token"]')?.content || '';
+
 // Send an AJAX request based on form data
 async function sendRequest(form, urlTemplate, method) {
     const output = form.nextElementSibling; // element to show response
@@ -56,7 +59,10 @@ async function sendRequest(form, urlTemplate, method) {
     try {
         const res = await fetch(url, {
             method,
-            headers: { 'Content-Type': 'application/json' },
+            headers: {
+                'Content-Type': 'application/json',
+                'X-CSRF-Token': csrfToken // required with the session cookie (see middleware.CSRF)
+            },
             body: method === 'GET' ? null : body
         });
 
@@ -82,10 +88,18 @@ function bindForm(id, url, method) {
 }
 
 // Bind all forms
+bindForm('revoke-session-form', '/me/sessions/{id}', 'DELETE');
+bindForm('revoke-all-sessions-form', '/me/sessions', 'DELETE');
+bindForm('issue-token-form', '/auth/token', 'POST');
+bindForm('refresh-token-form', '/auth/refresh', 'POST');
+bindForm('revoke-token-form', '/auth/revoke', 'POST');
 bindForm('get-user-by-id-form', '/users/{id}', 'GET');
 bindForm('create-user-form', '/users', 'POST');
 bindForm('update-user-form', '/users/{id}', 'PUT');
 bindForm('delete-user-form', '/users/{id}', 'DELETE');
+bindForm('set-user-role-form', '/admin/users/{id}/role', 'PUT');
+bindForm('unlock-user-form', '/admin/users/{id}/unlock', 'POST');
+bindForm('reset-two-factor-form', '/admin/users/{id}/2fa', 'DELETE');
 bindForm('get-book-by-id-form', '/books/{id}', 'GET');
 bindForm('create-book-form', '/books', 'POST');
 bindForm('update-book-form', '/books/{id}', 'PUT');
@@ -93,7 +107,19 @@ bindForm('delete-book-form', '/books/{id}', 'DELETE');
 bindForm('get-album-by-id-form', '/albums/{id}', 'GET');
 bindForm('can-purchase-form', '/albums/{id}/can-purchase', 'GET');
 bindForm('create-album-form', '/albums', 'POST');
+bindForm('update-album-form', '/albums/{id}', 'PUT');
+bindForm('patch-album-form', '/albums/{id}', 'PATCH');
+bindForm('adjust-stock-form', '/albums/{id}/stock', 'POST');
+bindForm('delete-album-form', '/albums/{id}', 'DELETE');
 bindForm('create-order-form', '/orders', 'POST');
+bindForm('get-order-by-id-form', '/orders/{id}', 'GET');
+bindForm('cancel-order-form', '/orders/{id}/cancel', 'POST');
+bindForm('create-profile-form', '/profile', 'POST');
+bindForm('update-profile-form', '/profile', 'PUT');
+bindForm('add-cart-item-form', '/cart/items', 'POST');
+bindForm('update-cart-item-form', '/cart/items/{album_id}', 'PUT');
+bindForm('remove-cart-item-form', '/cart/items/{album_id}', 'DELETE');
+bindForm('checkout-form', '/cart/checkout', 'POST');
 bindForm('customer-name-form', '/customer-name', 'GET');
 bindForm('json-encode-form', '/json/encode', 'POST');
 bindForm('json-decode-form', '/json/decode', 'POST');